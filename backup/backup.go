@@ -0,0 +1,634 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+// Package backup saves the channels, notes, tags, links and rules in the db as
+// a json document and loads them back, so they can be moved between machines
+// or kept in git without depending on the sqlite file format.
+package backup
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/rule"
+	"repo.joyrex.net/ejstacey/ysm/tag"
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+// Version is the version of the document format written by Export. Bump it
+// when the format changes in a way older versions of ysm can't read.
+// Version 2 added the tag parent, version 3 the auto-tagging rules, version 4
+// archived channels, version 5 the tag aliases and version 6 the tag
+// visibility.
+const Version = 6
+
+// Document is the json backup. Tags are referred to by name, since ids are
+// local to a db.
+type Document struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+	Channels   []Channel `json:"channels"`
+	Tags       []Tag     `json:"tags"`
+	Rules      []Rule    `json:"rules,omitempty"`
+}
+
+type Channel struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Notes       string `json:"notes"`
+	// UnsubscribedAt is an RFC 3339 time, or empty for subscribed channels.
+	UnsubscribedAt string `json:"unsubscribedAt,omitempty"`
+	// Archived channels were unsubscribed by hand, so UnsubscribedAt is set
+	// too.
+	Archived bool     `json:"archived,omitempty"`
+	Tags     []string `json:"tags"`
+	// RuleTags are the tags in Tags that were added by an auto-tagging rule.
+	RuleTags []string `json:"ruleTags,omitempty"`
+	// RuleExceptions are tags that rules mustn't add, because they were
+	// removed by hand.
+	RuleExceptions []string `json:"ruleExceptions,omitempty"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	FgColour    string `json:"fgColour"`
+	BgColour    string `json:"bgColour"`
+	// Parent is the name of the tag this one is under, if any.
+	Parent string `json:"parent,omitempty"`
+	// Aliases are the tag's old names from renames and merges.
+	Aliases []string `json:"aliases,omitempty"`
+	// Visibility is public, unlisted or private. It's empty in backups from
+	// before version 6.
+	Visibility string `json:"visibility,omitempty"`
+}
+
+type Rule struct {
+	Tag     string `json:"tag"`
+	Field   string `json:"field"`
+	Match   string `json:"match"`
+	Pattern string `json:"pattern"`
+}
+
+type Mode string
+
+const (
+	// Replace throws away everything in the db and loads the document.
+	Replace Mode = "replace"
+	// MergePreferLocal adds what's only in the document and keeps the db's
+	// values where both have something different.
+	MergePreferLocal Mode = "merge-prefer-local"
+	// MergePreferFile adds what's only in the document and takes the
+	// document's values where both have something different.
+	MergePreferFile Mode = "merge-prefer-file"
+)
+
+var Modes = []Mode{Replace, MergePreferLocal, MergePreferFile}
+
+// Summary counts what an import did.
+type Summary struct {
+	ChannelsAdded   int
+	ChannelsUpdated int
+	TagsAdded       int
+	TagsUpdated     int
+	LinksAdded      int
+	RulesAdded      int
+	// NameClashes says which channels couldn't take their name from the
+	// document because another channel in the db already has it. New ones
+	// are skipped, along with their links, and ones already there keep
+	// their name.
+	NameClashes []string
+}
+
+func (s Summary) String() string {
+	summary := fmt.Sprintf("%d channels added, %d channels updated, %d tags added, %d tags updated, %d links added, %d rules added",
+		s.ChannelsAdded, s.ChannelsUpdated, s.TagsAdded, s.TagsUpdated, s.LinksAdded, s.RulesAdded)
+	if len(s.NameClashes) > 0 {
+		summary += fmt.Sprintf(", %d channel names clashed", len(s.NameClashes))
+	}
+	return summary
+}
+
+// Load reads the document from the db, sorted so that exports of the same
+// data are identical apart from ExportedAt.
+func Load() (Document, error) {
+	var doc = Document{Version: Version, ExportedAt: time.Now().UTC().Truncate(time.Second)}
+
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tagRows, err := utils.DbConn.QueryContext(ctx, "select id, ifnull(name, ''), ifnull(description, ''), ifnull(fgColour, ''), ifnull(bgColour, ''), ifnull(parentId, 0), visibility from tags")
+	if err != nil {
+		return doc, err
+	}
+	defer tagRows.Close()
+
+	var tagNames = make(map[int64]string)
+	var tagParents = make(map[int64]int64)
+	var tagIds []int64
+	for tagRows.Next() {
+		var id, parentId int64
+		var t Tag
+		err = tagRows.Scan(&id, &t.Name, &t.Description, &t.FgColour, &t.BgColour, &parentId, &t.Visibility)
+		if err != nil {
+			return doc, err
+		}
+		tagNames[id] = t.Name
+		tagParents[id] = parentId
+		tagIds = append(tagIds, id)
+		doc.Tags = append(doc.Tags, t)
+	}
+	if err = tagRows.Err(); err != nil {
+		return doc, err
+	}
+	for i, id := range tagIds {
+		doc.Tags[i].Parent = tagNames[tagParents[id]]
+	}
+
+	aliasRows, err := utils.DbConn.QueryContext(ctx, "select name, tagId from tag_aliases order by name")
+	if err != nil {
+		return doc, err
+	}
+	defer aliasRows.Close()
+
+	var tagAliases = make(map[int64][]string)
+	for aliasRows.Next() {
+		var name string
+		var tagId int64
+		err = aliasRows.Scan(&name, &tagId)
+		if err != nil {
+			return doc, err
+		}
+		tagAliases[tagId] = append(tagAliases[tagId], name)
+	}
+	if err = aliasRows.Err(); err != nil {
+		return doc, err
+	}
+	for i, id := range tagIds {
+		doc.Tags[i].Aliases = tagAliases[id]
+	}
+
+	linkRows, err := utils.DbConn.QueryContext(ctx, "select channelId, tagId, source from links")
+	if err != nil {
+		return doc, err
+	}
+	defer linkRows.Close()
+
+	var channelTags = make(map[string][]string)
+	var channelRuleTags = make(map[string][]string)
+	for linkRows.Next() {
+		var channelId, source string
+		var tagId int64
+		err = linkRows.Scan(&channelId, &tagId, &source)
+		if err != nil {
+			return doc, err
+		}
+		channelTags[channelId] = append(channelTags[channelId], tagNames[tagId])
+		if source == "rule" {
+			channelRuleTags[channelId] = append(channelRuleTags[channelId], tagNames[tagId])
+		}
+	}
+	if err = linkRows.Err(); err != nil {
+		return doc, err
+	}
+
+	exceptionRows, err := utils.DbConn.QueryContext(ctx, "select channelId, tagId from rule_exceptions")
+	if err != nil {
+		return doc, err
+	}
+	defer exceptionRows.Close()
+
+	var channelExceptions = make(map[string][]string)
+	for exceptionRows.Next() {
+		var channelId string
+		var tagId int64
+		err = exceptionRows.Scan(&channelId, &tagId)
+		if err != nil {
+			return doc, err
+		}
+		channelExceptions[channelId] = append(channelExceptions[channelId], tagNames[tagId])
+	}
+	if err = exceptionRows.Err(); err != nil {
+		return doc, err
+	}
+
+	ruleRows, err := utils.DbConn.QueryContext(ctx, "select tagId, field, matchType, pattern from rules order by id")
+	if err != nil {
+		return doc, err
+	}
+	defer ruleRows.Close()
+
+	for ruleRows.Next() {
+		var tagId int64
+		var r Rule
+		err = ruleRows.Scan(&tagId, &r.Field, &r.Match, &r.Pattern)
+		if err != nil {
+			return doc, err
+		}
+		r.Tag = tagNames[tagId]
+		doc.Rules = append(doc.Rules, r)
+	}
+	if err = ruleRows.Err(); err != nil {
+		return doc, err
+	}
+
+	channelRows, err := utils.DbConn.QueryContext(ctx, "select id, name, ifnull(description, ''), ifnull(notes, ''), ifnull(unsubscribedAt, ''), archived from channels")
+	if err != nil {
+		return doc, err
+	}
+	defer channelRows.Close()
+
+	for channelRows.Next() {
+		var c Channel
+		err = channelRows.Scan(&c.Id, &c.Name, &c.Description, &c.Notes, &c.UnsubscribedAt, &c.Archived)
+		if err != nil {
+			return doc, err
+		}
+		c.Tags = channelTags[c.Id]
+		if c.Tags == nil {
+			c.Tags = []string{}
+		}
+		slices.Sort(c.Tags)
+		c.RuleTags = channelRuleTags[c.Id]
+		slices.Sort(c.RuleTags)
+		c.RuleExceptions = channelExceptions[c.Id]
+		slices.Sort(c.RuleExceptions)
+		doc.Channels = append(doc.Channels, c)
+	}
+	if err = channelRows.Err(); err != nil {
+		return doc, err
+	}
+
+	slices.SortFunc(doc.Channels, func(a, b Channel) int { return cmp.Compare(a.Id, b.Id) })
+	slices.SortFunc(doc.Tags, func(a, b Tag) int { return cmp.Compare(a.Name, b.Name) })
+	if doc.Channels == nil {
+		doc.Channels = []Channel{}
+	}
+	if doc.Tags == nil {
+		doc.Tags = []Tag{}
+	}
+
+	return doc, nil
+}
+
+func Export(w io.Writer) error {
+	doc, err := Load()
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// Read decodes a document, checking it's a version this build understands.
+func Read(r io.Reader) (Document, error) {
+	var doc Document
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&doc)
+	if err != nil {
+		return doc, err
+	}
+
+	if doc.Version == 0 {
+		return doc, errors.New("not a ysm backup, there's no version")
+	}
+	if doc.Version > Version {
+		return doc, fmt.Errorf("the backup is version %d, but this version of ysm only understands up to version %d", doc.Version, Version)
+	}
+
+	var tagNames = make(map[string]bool)
+	for _, t := range doc.Tags {
+		if t.Name == "" {
+			return doc, errors.New("there's a tag without a name")
+		}
+		if tagNames[t.Name] {
+			return doc, fmt.Errorf("tag %q is in the backup more than once", t.Name)
+		}
+		tagNames[t.Name] = true
+	}
+	var aliases = make(map[string]bool)
+	for _, t := range doc.Tags {
+		if t.Parent != "" && !tagNames[t.Parent] {
+			return doc, fmt.Errorf("tag %q is under %q, which isn't in the backup's tags", t.Name, t.Parent)
+		}
+		if t.Visibility != "" && !slices.Contains(tag.Visibilities, t.Visibility) {
+			return doc, fmt.Errorf("tag %q has an unknown visibility %q", t.Name, t.Visibility)
+		}
+		for _, alias := range t.Aliases {
+			if alias == "" {
+				return doc, fmt.Errorf("tag %q has an empty alias", t.Name)
+			}
+			if aliases[strings.ToLower(alias)] || slices.ContainsFunc(doc.Tags, func(other Tag) bool { return strings.EqualFold(other.Name, alias) }) {
+				return doc, fmt.Errorf("tag %q has the alias %q, which is already another tag's name or alias", t.Name, alias)
+			}
+			aliases[strings.ToLower(alias)] = true
+		}
+	}
+	for _, c := range doc.Channels {
+		if c.Id == "" || c.Name == "" {
+			return doc, errors.New("there's a channel without an id or name")
+		}
+		if c.UnsubscribedAt != "" {
+			if _, err := time.Parse(time.RFC3339, c.UnsubscribedAt); err != nil {
+				return doc, fmt.Errorf("channel %s: %w", c.Id, err)
+			}
+		}
+		if c.Archived && c.UnsubscribedAt == "" {
+			return doc, fmt.Errorf("channel %s is archived but has no unsubscribedAt", c.Id)
+		}
+		for _, name := range c.Tags {
+			if !tagNames[name] {
+				return doc, fmt.Errorf("channel %s is tagged with %q, which isn't in the backup's tags", c.Id, name)
+			}
+		}
+		for _, name := range c.RuleTags {
+			if !slices.Contains(c.Tags, name) {
+				return doc, fmt.Errorf("channel %s has %q as a rule tag, but isn't tagged with it", c.Id, name)
+			}
+		}
+		for _, name := range c.RuleExceptions {
+			if !tagNames[name] {
+				return doc, fmt.Errorf("channel %s has a rule exception for %q, which isn't in the backup's tags", c.Id, name)
+			}
+		}
+	}
+	for _, r := range doc.Rules {
+		if !tagNames[r.Tag] {
+			return doc, fmt.Errorf("there's a rule for %q, which isn't in the backup's tags", r.Tag)
+		}
+		_, err = rule.New(0, rule.Field(r.Field), rule.MatchType(r.Match), r.Pattern)
+		if err != nil {
+			return doc, fmt.Errorf("rule for %q: %w", r.Tag, err)
+		}
+	}
+
+	return doc, nil
+}
+
+// Import loads doc into the db in a single transaction. With dryRun the
+// transaction is rolled back, so the summary says what would have been done.
+//
+// When merging, channels are matched by id and tags by name. Links are always
+// added, never removed. For anything on both sides, the preferred side's
+// values are used, except that an empty note, tag description or colour never
+// replaces one that's set.
+func Import(doc Document, mode Mode, dryRun bool) (Summary, error) {
+	var summary Summary
+
+	if !slices.Contains(Modes, mode) {
+		return summary, fmt.Errorf("unknown mode %q", mode)
+	}
+	preferFile := mode != MergePreferLocal
+
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	tx, err := utils.DbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return summary, err
+	}
+	defer tx.Rollback()
+
+	if mode == Replace {
+		// links go with them through the cascades. The journal's entries are
+		// for rows that are gone, so it goes too, and nothing from before can
+		// be undone.
+		_, err = tx.ExecContext(ctx, "delete from channels; delete from tags; delete from history;")
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	var tagIds = make(map[string]int64)
+	for _, t := range doc.Tags {
+		var local Tag
+		var id int64
+		err = tx.QueryRowContext(ctx, "select id, ifnull(description, ''), ifnull(fgColour, ''), ifnull(bgColour, ''), visibility from tags where name = :name", t.Name).Scan(&id, &local.Description, &local.FgColour, &local.BgColour, &local.Visibility)
+		if errors.Is(err, sql.ErrNoRows) {
+			res, err := tx.ExecContext(ctx, "insert into tags (name, description, fgColour, bgColour, visibility) values (:name, :description, :fgColour, :bgColour, :visibility)", t.Name, t.Description, t.FgColour, t.BgColour, cmp.Or(t.Visibility, tag.VisibilityPublic))
+			if err != nil {
+				return summary, fmt.Errorf("tag %q: %w", t.Name, err)
+			}
+			tagIds[t.Name], err = res.LastInsertId()
+			if err != nil {
+				return summary, err
+			}
+			summary.TagsAdded++
+			continue
+		}
+		if err != nil {
+			return summary, err
+		}
+		tagIds[t.Name] = id
+
+		merged := Tag{
+			Name:        t.Name,
+			Description: pick(local.Description, t.Description, preferFile),
+			FgColour:    pick(local.FgColour, t.FgColour, preferFile),
+			BgColour:    pick(local.BgColour, t.BgColour, preferFile),
+			Visibility:  pick(local.Visibility, t.Visibility, preferFile),
+		}
+		if merged.Description != local.Description || merged.FgColour != local.FgColour || merged.BgColour != local.BgColour || merged.Visibility != local.Visibility {
+			_, err = tx.ExecContext(ctx, "update tags set description = :description, fgColour = :fgColour, bgColour = :bgColour, visibility = :visibility where id = :id", merged.Description, merged.FgColour, merged.BgColour, merged.Visibility, id)
+			if err != nil {
+				return summary, fmt.Errorf("tag %q: %w", t.Name, err)
+			}
+			summary.TagsUpdated++
+		}
+	}
+
+	// parents are done once all the tags exist
+	for _, t := range doc.Tags {
+		var localParent string
+		err = tx.QueryRowContext(ctx, "select ifnull(parent.name, '') from tags left join tags parent on parent.id = tags.parentId where tags.id = :id", tagIds[t.Name]).Scan(&localParent)
+		if err != nil {
+			return summary, err
+		}
+
+		parent := pick(localParent, t.Parent, preferFile)
+		if parent == localParent {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, "update tags set parentId = :parentId where id = :id", tagIds[parent], tagIds[t.Name])
+		if err != nil {
+			return summary, fmt.Errorf("tag %q: %w", t.Name, err)
+		}
+		summary.TagsUpdated++
+	}
+	err = checkTagLoops(ctx, tx)
+	if err != nil {
+		return summary, err
+	}
+
+	// an alias never hides a tag's own name
+	var aliasSql = "insert or ignore into tag_aliases (name, tagId) select :name, :tagId where not exists (select 1 from tags where name = :name collate nocase)"
+	if preferFile {
+		aliasSql = "insert or replace into tag_aliases (name, tagId) select :name, :tagId where not exists (select 1 from tags where name = :name collate nocase)"
+	}
+	for _, t := range doc.Tags {
+		for _, alias := range t.Aliases {
+			_, err = tx.ExecContext(ctx, aliasSql, sql.Named("name", alias), sql.Named("tagId", tagIds[t.Name]))
+			if err != nil {
+				return summary, fmt.Errorf("tag %q alias %q: %w", t.Name, alias, err)
+			}
+		}
+	}
+
+	for _, c := range doc.Channels {
+		// channel names are unique, and one that's taken by another channel
+		// would fail the whole import
+		var clash string
+		err = tx.QueryRowContext(ctx, "select id from channels where name = :name and id != :id", c.Name, c.Id).Scan(&clash)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return summary, err
+		}
+
+		var local Channel
+		err = tx.QueryRowContext(ctx, "select name, ifnull(description, ''), ifnull(notes, ''), ifnull(unsubscribedAt, ''), archived from channels where id = :id", c.Id).Scan(&local.Name, &local.Description, &local.Notes, &local.UnsubscribedAt, &local.Archived)
+		if errors.Is(err, sql.ErrNoRows) {
+			if clash != "" {
+				summary.NameClashes = append(summary.NameClashes, fmt.Sprintf("channel %s (%s) wasn't added, %s already has that name", c.Id, c.Name, clash))
+				continue
+			}
+			_, err = tx.ExecContext(ctx, "insert into channels (id, name, description, notes, unsubscribedAt, archived) values (:id, :name, :description, :notes, :unsubscribedAt, :archived)", c.Id, c.Name, c.Description, c.Notes, nullIfEmpty(c.UnsubscribedAt), c.Archived)
+			if err != nil {
+				return summary, fmt.Errorf("channel %s: %w", c.Id, err)
+			}
+			summary.ChannelsAdded++
+		} else if err != nil {
+			return summary, err
+		} else {
+			merged := local
+			if preferFile {
+				merged.Name = c.Name
+				merged.Description = c.Description
+				merged.UnsubscribedAt = c.UnsubscribedAt
+				merged.Archived = c.Archived
+				if clash != "" && c.Name != local.Name {
+					merged.Name = local.Name
+					summary.NameClashes = append(summary.NameClashes, fmt.Sprintf("channel %s kept its name %s, %s already has %s", c.Id, local.Name, clash, c.Name))
+				}
+			}
+			merged.Notes = pick(local.Notes, c.Notes, preferFile)
+
+			if merged.Name != local.Name || merged.Description != local.Description || merged.Notes != local.Notes || merged.UnsubscribedAt != local.UnsubscribedAt || merged.Archived != local.Archived {
+				_, err = tx.ExecContext(ctx, "update channels set name = :name, description = :description, notes = :notes, unsubscribedAt = :unsubscribedAt, archived = :archived where id = :id", merged.Name, merged.Description, merged.Notes, nullIfEmpty(merged.UnsubscribedAt), merged.Archived, c.Id)
+				if err != nil {
+					return summary, fmt.Errorf("channel %s: %w", c.Id, err)
+				}
+				summary.ChannelsUpdated++
+			}
+		}
+
+		for _, name := range c.Tags {
+			var source = "manual"
+			if slices.Contains(c.RuleTags, name) {
+				source = "rule"
+			}
+			res, err := tx.ExecContext(ctx, "insert or ignore into links (channelId, tagId, source) values (:channelId, :tagId, :source)", c.Id, tagIds[name], source)
+			if err != nil {
+				return summary, fmt.Errorf("channel %s tag %q: %w", c.Id, name, err)
+			}
+			added, err := res.RowsAffected()
+			if err != nil {
+				return summary, err
+			}
+			summary.LinksAdded += int(added)
+		}
+
+		for _, name := range c.RuleExceptions {
+			_, err = tx.ExecContext(ctx, "insert or ignore into rule_exceptions (channelId, tagId) values (:channelId, :tagId)", c.Id, tagIds[name])
+			if err != nil {
+				return summary, fmt.Errorf("channel %s rule exception %q: %w", c.Id, name, err)
+			}
+		}
+	}
+
+	// rules that are already in the db aren't added again
+	for _, r := range doc.Rules {
+		res, err := tx.ExecContext(ctx, `
+			insert into rules (tagId, field, matchType, pattern, createdAt)
+			select :tagId, :field, :matchType, :pattern, :createdAt
+			where not exists (select 1 from rules where tagId = :tagId and field = :field and matchType = :matchType and pattern = :pattern)
+		`, sql.Named("tagId", tagIds[r.Tag]), sql.Named("field", r.Field), sql.Named("matchType", r.Match), sql.Named("pattern", r.Pattern), sql.Named("createdAt", time.Now().UTC().Format(time.RFC3339)))
+		if err != nil {
+			return summary, fmt.Errorf("rule for %q: %w", r.Tag, err)
+		}
+		added, err := res.RowsAffected()
+		if err != nil {
+			return summary, err
+		}
+		summary.RulesAdded += int(added)
+	}
+
+	if dryRun {
+		return summary, nil
+	}
+
+	return summary, tx.Commit()
+}
+
+// checkTagLoops returns an error if merging has left a tag under itself, which
+// can happen if the db and the backup have two tags the opposite way round.
+func checkTagLoops(ctx context.Context, tx *sql.Tx) error {
+	var checkSql = `
+		with recursive walk(start, id) as (
+			select id, parentId from tags where parentId is not null
+			union
+			select walk.start, tags.parentId from walk join tags on tags.id = walk.id where tags.parentId is not null
+		)
+		select tags.name from walk join tags on tags.id = walk.start where walk.start = walk.id limit 1
+	`
+	var name string
+	err := tx.QueryRowContext(ctx, checkSql).Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("tag %q would end up under itself, the tag parents in the backup and the database don't agree", name)
+}
+
+// pick returns the preferred one of local and file, unless it's empty and the
+// other isn't.
+func pick(local string, file string, preferFile bool) string {
+	if preferFile {
+		return cmp.Or(file, local)
+	}
+	return cmp.Or(local, file)
+}
+
+func nullIfEmpty(x string) any {
+	if x == "" {
+		return nil
+	}
+	return x
+}
@@ -0,0 +1,133 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package backup
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+// openTestDb opens a fresh db holding the channels, given as id and name.
+func openTestDb(t *testing.T, channels ...string) {
+	t.Helper()
+
+	utils.InitDb(filepath.Join(t.TempDir(), "ysm.db"), 1)
+	t.Cleanup(func() {
+		utils.DbConn.Close()
+		utils.DbConn = nil
+	})
+
+	for i := 0; i < len(channels); i += 2 {
+		_, err := utils.DbConn.Exec("insert into channels (id, name) values (?, ?)", channels[i], channels[i+1])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// channelName returns the name of the channel in the db, or "" if it isn't
+// there.
+func channelName(t *testing.T, id string) string {
+	t.Helper()
+
+	var name string
+	err := utils.DbConn.QueryRow("select name from channels where id = ?", id).Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestImportSkipsNewChannelWithTakenName(t *testing.T) {
+	openTestDb(t, "UC1", "Alpha")
+
+	doc := Document{
+		Version: Version,
+		Tags:    []Tag{{Name: "music"}},
+		Channels: []Channel{
+			{Id: "UC2", Name: "Alpha", Tags: []string{"music"}},
+			{Id: "UC3", Name: "Gamma", Tags: []string{"music"}},
+		},
+	}
+	summary, err := Import(doc, MergePreferLocal, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.ChannelsAdded != 1 || summary.LinksAdded != 1 || len(summary.NameClashes) != 1 {
+		t.Errorf("summary is %s with clashes %q, want Gamma added and Alpha clashing", summary, summary.NameClashes)
+	}
+	if got := channelName(t, "UC2"); got != "" {
+		t.Errorf("UC2 was added as %q, want it skipped", got)
+	}
+	if got := channelName(t, "UC3"); got != "Gamma" {
+		t.Errorf("UC3 is %q, want Gamma", got)
+	}
+}
+
+func TestImportKeepsNameTakenByAnotherChannel(t *testing.T) {
+	openTestDb(t, "UC1", "Alpha", "UC2", "Beta")
+
+	doc := Document{
+		Version: Version,
+		Channels: []Channel{
+			{Id: "UC2", Name: "Alpha", Description: "renamed"},
+		},
+	}
+	summary, err := Import(doc, MergePreferFile, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(summary.NameClashes) != 1 {
+		t.Errorf("clashes are %q, want one", summary.NameClashes)
+	}
+	if got := channelName(t, "UC2"); got != "Beta" {
+		t.Errorf("UC2 is %q, want it to keep Beta", got)
+	}
+	if got := channelName(t, "UC1"); got != "Alpha" {
+		t.Errorf("UC1 is %q, want Alpha", got)
+	}
+}
+
+func TestImportReplaceClearsHistory(t *testing.T) {
+	openTestDb(t, "UC1", "Alpha")
+
+	_, err := utils.DbConn.Exec("insert into history (at, description, changes) values ('2025-01-01T00:00:00Z', 'set notes', '[]')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Import(Document{Version: Version, Channels: []Channel{{Id: "UC1", Name: "Alpha"}}}, Replace, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entries int
+	err = utils.DbConn.QueryRow("select count(*) from history").Scan(&entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries != 0 {
+		t.Errorf("history has %d entries after a replace, want none", entries)
+	}
+}
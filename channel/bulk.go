@@ -0,0 +1,145 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package channel
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/history"
+)
+
+type BulkAction int
+
+const (
+	BulkAddTag BulkAction = iota
+	BulkRemoveTag
+	BulkClearNotes
+	BulkArchive
+)
+
+// BulkResult says how many of the channels a bulk action changed. The rest
+// were already how the action would leave them.
+type BulkResult struct {
+	Action    BulkAction
+	TagName   string
+	Changed   int
+	Unchanged int
+}
+
+func (r BulkResult) String() string {
+	var did string
+	switch r.Action {
+	case BulkAddTag:
+		did = fmt.Sprintf("Added %s to %d channels", r.TagName, r.Changed)
+	case BulkRemoveTag:
+		did = fmt.Sprintf("Removed %s from %d channels", r.TagName, r.Changed)
+	case BulkClearNotes:
+		did = fmt.Sprintf("Cleared the notes of %d channels", r.Changed)
+	case BulkArchive:
+		did = fmt.Sprintf("Archived %d channels", r.Changed)
+	}
+	if r.Unchanged > 0 {
+		did += fmt.Sprintf(", %d were already done", r.Unchanged)
+	}
+	return did
+}
+
+// Bulk does the action to all of the channels in a single transaction. tagId
+// and tagName are only used by BulkAddTag and BulkRemoveTag. Archiving marks
+// the channels as unsubscribed, the same as when they're gone from youtube,
+// except that a sync leaves them archived while they're still subscribed.
+func Bulk(channelIds []string, action BulkAction, tagId int64, tagName string) (BulkResult, error) {
+	var result = BulkResult{Action: action, TagName: tagName}
+
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	w, err := history.Watch()
+	if err != nil {
+		return result, err
+	}
+	defer w.Close()
+
+	for _, id := range channelIds {
+		switch action {
+		case BulkAddTag, BulkRemoveTag:
+			var key = map[string]any{"channelId": id, "tagId": tagId}
+			err = w.Row("links", key)
+			if err == nil {
+				err = w.Row("rule_exceptions", key)
+			}
+		default:
+			err = w.Row("channels", map[string]any{"id": id})
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+
+	tx := w.Tx()
+	var unsubscribedAt = time.Now().UTC().Format(time.RFC3339)
+	for _, id := range channelIds {
+		var changed int64
+		switch action {
+		case BulkAddTag:
+			changed, err = execCount(ctx, tx, "insert or ignore into links (channelId, tagId) values (:channelId, :tagId)", id, tagId)
+		case BulkRemoveTag:
+			// so the rule that made the link doesn't just make it again
+			_, err = tx.ExecContext(ctx, RuleExceptionSql, id, tagId)
+			if err == nil {
+				changed, err = execCount(ctx, tx, "delete from links where channelId = :channelId and tagId = :tagId", id, tagId)
+			}
+		case BulkClearNotes:
+			changed, err = execCount(ctx, tx, "update channels set notes = '' where id = :id and ifnull(notes, '') != ''", id)
+		case BulkArchive:
+			changed, err = execCount(ctx, tx, "update channels set unsubscribedAt = ifnull(unsubscribedAt, :unsubscribedAt), archived = 1 where id = :id and archived = 0", unsubscribedAt, id)
+		default:
+			err = fmt.Errorf("unknown bulk action %d", action)
+		}
+		if err != nil {
+			return BulkResult{Action: action, TagName: tagName}, fmt.Errorf("channel %s: %w", id, err)
+		}
+
+		if changed > 0 {
+			result.Changed++
+		} else {
+			result.Unchanged++
+		}
+	}
+
+	// the journal only cares about what changed
+	done := result
+	done.Unchanged = 0
+	description := done.String()
+	err = w.Record(strings.ToLower(description[:1]) + description[1:])
+	if err != nil {
+		return BulkResult{Action: action, TagName: tagName}, err
+	}
+
+	return result, nil
+}
+
+// execCount runs the statement and returns how many rows it changed.
+func execCount(ctx context.Context, tx *sql.Tx, query string, args ...any) (int64, error) {
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
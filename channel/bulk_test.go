@@ -0,0 +1,89 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package channel
+
+import (
+	"testing"
+
+	"repo.joyrex.net/ejstacey/ysm/history"
+)
+
+func TestArchivedChannelsStayArchived(t *testing.T) {
+	channels := openTestDb(t)
+	list := SubscriptionList{Channels: []Channel{
+		NewChannel("UC111", "Alpha", ""),
+		NewChannel("UC222", "Beta", ""),
+	}}
+
+	cs := channels.Diff(list)
+	cs.AcceptAll()
+	err := channels.ApplyChanges(cs)
+	if err != nil {
+		t.Fatalf("ApplyChanges: %v", err)
+	}
+
+	result, err := Bulk([]string{"UC111"}, BulkArchive, 0, "")
+	if err != nil || result.Changed != 1 {
+		t.Fatalf("Bulk archive = %v, %v", result, err)
+	}
+	err = channels.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archived := channels.ById()["UC111"]; !archived.Archived() || !archived.Unsubscribed() {
+		t.Fatalf("UC111 isn't archived and unsubscribed after archiving it")
+	}
+
+	// still subscribed on youtube, which mustn't undo the archive
+	cs = channels.Diff(list)
+	if len(cs.Changes) != 0 {
+		t.Errorf("changes = %v, want none for an archived channel that's still subscribed", cs.Changes)
+	}
+
+	_, err = history.Undo()
+	if err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	err = channels.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored := channels.ById()["UC111"]; restored.Archived() || restored.Unsubscribed() {
+		t.Errorf("UC111 is still archived after undoing the archive")
+	}
+}
+
+func TestUnsubscribedChannelsAreRestored(t *testing.T) {
+	channels := openTestDb(t)
+	both := SubscriptionList{Channels: []Channel{
+		NewChannel("UC111", "Alpha", ""),
+		NewChannel("UC222", "Beta", ""),
+	}}
+
+	for _, list := range []SubscriptionList{both, {Channels: both.Channels[1:]}} {
+		cs := channels.Diff(list)
+		cs.AcceptAll()
+		err := channels.ApplyChanges(cs)
+		if err != nil {
+			t.Fatalf("ApplyChanges: %v", err)
+		}
+	}
+	if !channels.ById()["UC111"].Unsubscribed() {
+		t.Fatalf("UC111 wasn't unsubscribed when it went from the list")
+	}
+
+	cs := channels.Diff(both)
+	if len(cs.Changes) != 1 || cs.Changes[0].Type != ChangeRestored {
+		t.Errorf("changes = %v, want UC111 resubscribed", cs.Changes)
+	}
+}
@@ -0,0 +1,239 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package channel
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+type ChangeType int
+
+const (
+	ChangeAdded ChangeType = iota
+	ChangeRenamed
+	ChangeDescription
+	ChangeRemoved
+	ChangeRestored
+)
+
+// Change is a single difference between the db and a freshly retrieved
+// subscription list. Old is empty for added channels, New is empty for removed
+// ones.
+type Change struct {
+	Type     ChangeType
+	Old      Channel
+	New      Channel
+	Accepted bool
+}
+
+func (ch Change) Name() string {
+	if ch.Type == ChangeAdded {
+		return ch.New.name
+	}
+	return ch.Old.name
+}
+
+func (ch Change) String() string {
+	switch ch.Type {
+	case ChangeAdded:
+		return fmt.Sprintf("added: %s", ch.New.name)
+	case ChangeRenamed:
+		return fmt.Sprintf("renamed: %s -> %s", ch.Old.name, ch.New.name)
+	case ChangeDescription:
+		return fmt.Sprintf("description changed: %s", ch.Old.name)
+	case ChangeRemoved:
+		return fmt.Sprintf("unsubscribed: %s", ch.Old.name)
+	case ChangeRestored:
+		return fmt.Sprintf("resubscribed: %s", ch.Old.name)
+	}
+	return fmt.Sprintf("unknown change: %s", ch.Name())
+}
+
+// Changeset is everything that would be done to the db to bring it in line
+// with a retrieved subscription list. Nothing is applied until it's passed to
+// ApplyChanges, and then only the accepted changes are.
+type Changeset struct {
+	Changes []Change
+	// Complete is false if the retrieved list looked truncated, in which case
+	// there are no ChangeRemoved entries and Incomplete says why.
+	Complete   bool
+	Incomplete string
+	// state and pages are recorded by ApplyChanges, see SubscriptionList.
+	state SyncState
+	pages []syncPage
+}
+
+func (cs *Changeset) AcceptAll() {
+	for i := range cs.Changes {
+		cs.Changes[i].Accepted = true
+	}
+}
+
+// Keep drops the changes that aren't one of types.
+func (cs *Changeset) Keep(types ...ChangeType) {
+	cs.Changes = slices.DeleteFunc(cs.Changes, func(ch Change) bool {
+		return !slices.Contains(types, ch.Type)
+	})
+}
+
+func (cs Changeset) Accepted() []Change {
+	var accepted []Change
+	for _, ch := range cs.Changes {
+		if ch.Accepted {
+			accepted = append(accepted, ch)
+		}
+	}
+	return accepted
+}
+
+// Diff works out the changeset between the channels in the db and the
+// retrieved list. Channels missing from the list are only included as
+// removed if it's complete.
+func (c Channels) Diff(list SubscriptionList) Changeset {
+	var newChannels = list.Channels
+	var incomplete = list.Incomplete
+	if incomplete == "" && len(newChannels) == 0 {
+		incomplete = "no subscriptions were retrieved"
+	}
+	var cs = Changeset{Complete: incomplete == "", Incomplete: incomplete, state: list.State, pages: list.pages}
+
+	var newIds = make(map[string]bool, len(newChannels))
+	for _, newEntry := range newChannels {
+		newIds[newEntry.id] = true
+
+		oldEntry, found := c.byId[newEntry.id]
+		if !found {
+			cs.Changes = append(cs.Changes, Change{Type: ChangeAdded, New: newEntry})
+			continue
+		}
+
+		// archived channels stay archived while they're still subscribed
+		if oldEntry.Unsubscribed() && !oldEntry.archived {
+			cs.Changes = append(cs.Changes, Change{Type: ChangeRestored, Old: oldEntry, New: newEntry})
+		}
+		if oldEntry.name != newEntry.name {
+			cs.Changes = append(cs.Changes, Change{Type: ChangeRenamed, Old: oldEntry, New: newEntry})
+		}
+		if oldEntry.description != newEntry.description && !newEntry.descriptionUnknown {
+			cs.Changes = append(cs.Changes, Change{Type: ChangeDescription, Old: oldEntry, New: newEntry})
+		}
+	}
+
+	if cs.Complete {
+		for _, id := range slices.Sorted(maps.Keys(c.byId)) {
+			dbEntry := c.byId[id]
+			if !newIds[id] && !dbEntry.Unsubscribed() {
+				cs.Changes = append(cs.Changes, Change{Type: ChangeRemoved, Old: dbEntry})
+			}
+		}
+	}
+
+	slices.SortStableFunc(cs.Changes, func(a, b Change) int {
+		if a.Type != b.Type {
+			return int(a.Type) - int(b.Type)
+		}
+		return strings.Compare(strings.ToLower(a.Name()), strings.ToLower(b.Name()))
+	})
+
+	return cs
+}
+
+// ApplyChanges writes the accepted changes in the changeset to the db in a
+// single transaction, along with the sync state of the list it came from,
+// then updates c to match.
+func (c *Channels) ApplyChanges(cs Changeset) error {
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := utils.DbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var unsubscribedAt = time.Now().UTC()
+	for _, ch := range cs.Accepted() {
+		var err error
+		switch ch.Type {
+		case ChangeAdded:
+			_, err = tx.ExecContext(ctx, "insert into channels (id, name, description) values (:id, :name, :description)", ch.New.id, ch.New.name, ch.New.description)
+		case ChangeRenamed:
+			_, err = tx.ExecContext(ctx, "update channels set name = :name where id = :id", ch.New.name, ch.Old.id)
+		case ChangeDescription:
+			_, err = tx.ExecContext(ctx, "update channels set description = :description where id = :id", ch.New.description, ch.Old.id)
+		case ChangeRemoved:
+			_, err = tx.ExecContext(ctx, "update channels set unsubscribedAt = :unsubscribedAt where id = :id", unsubscribedAt.Format(time.RFC3339), ch.Old.id)
+		case ChangeRestored:
+			_, err = tx.ExecContext(ctx, "update channels set unsubscribedAt = null where id = :id", ch.Old.id)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", ch, err)
+		}
+	}
+
+	if cs.state.Source != "" {
+		// the cached pages are only kept for a complete list, since they're
+		// reused as is when their etags haven't changed
+		if cs.Complete {
+			err = saveSyncPages(ctx, tx, cs.state.Source, cs.pages)
+			if err != nil {
+				return fmt.Errorf("unable to save subscription pages: %w", err)
+			}
+		}
+		err = cs.state.save(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("unable to save sync state: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	for _, ch := range cs.Accepted() {
+		var entry Channel
+		if ch.Type == ChangeAdded {
+			entry = ch.New
+		} else {
+			// pick up any earlier change to the same channel
+			entry = c.byId[ch.Old.id]
+			delete(c.byName, entry.name)
+		}
+
+		switch ch.Type {
+		case ChangeRenamed:
+			entry.name = ch.New.name
+		case ChangeDescription:
+			entry.description = ch.New.description
+		case ChangeRemoved:
+			entry.unsubscribedAt = unsubscribedAt
+		case ChangeRestored:
+			entry.unsubscribedAt = time.Time{}
+		}
+
+		c.byId[entry.id] = entry
+		c.byName[entry.name] = entry
+	}
+
+	return nil
+}
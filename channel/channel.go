@@ -14,13 +14,16 @@ package channel
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"html/template"
+	"maps"
 	"net/url"
 	"slices"
 	"time"
 
-	"golang.org/x/oauth2"
-	"google.golang.org/api/googleapi"
+	"repo.joyrex.net/ejstacey/ysm/feed"
+	"repo.joyrex.net/ejstacey/ysm/history"
 	"repo.joyrex.net/ejstacey/ysm/tag"
 	"repo.joyrex.net/ejstacey/ysm/utils"
 )
@@ -28,95 +31,90 @@ import (
 type ExportChannel struct {
 	Id          string
 	Name        string
+	Url         string
+	FeedUrl     string
 	Description string
 	Notes       string
-	Tags        []tag.ExportTag
+	// Page is where the channel's page is in a generated site, relative to
+	// the top of the site.
+	Page string
+	// NotesHTML is Notes rendered from markdown.
+	NotesHTML template.HTML
+	Tags      []tag.ExportTag
+	// TaggedAt is when the channel was last given one of Tags, zero if
+	// that's not known.
+	TaggedAt time.Time
+	// Details is empty for channels that haven't been enriched.
+	Details
+	// LatestVideo is the newest upload in the channel's feed, empty if it
+	// has none or FeedCheckedAt is zero because the feed hasn't been read.
+	LatestVideo   feed.Video
+	FeedCheckedAt time.Time
 }
 
 type Channel struct {
-	id          string
-	name        string
-	description string
-	notes       string
-	tags        []int64
+	id             string
+	name           string
+	description    string
+	notes          string
+	tags           []int64
+	unsubscribedAt time.Time
+	// archived channels were unsubscribed by hand, and stay that way even
+	// while they're still in the subscription list
+	archived bool
+	// ruleTags are the tags in tags that were added by an auto-tagging rule
+	ruleTags []int64
+	// descriptionUnknown is set on retrieved channels when the source doesn't
+	// provide descriptions, so an empty one isn't taken as a change.
+	descriptionUnknown bool
+	details            Details
+	// latestVideo is the newest upload in the channel's feed when it was
+	// checked at feedCheckedAt
+	latestVideo   feed.Video
+	feedCheckedAt time.Time
+	// taggedAt is when each tag was given to the channel, for the links
+	// made since that was recorded
+	taggedAt map[int64]time.Time
 }
 
-func (c Channel) Id() string               { return c.id }
-func (c Channel) FilterValue() string      { return c.name }
-func (c Channel) Title() string            { return c.name }
-func (c Channel) Name() string             { return c.name }
-func (c Channel) Description() string      { return c.description }
-func (c Channel) Notes() string            { return c.notes }
-func (c Channel) Tags() []int64            { return c.tags }
-func (c *Channel) SetDescription(x string) { c.description = x }
-
-func LoadChannelsYoutube() []Channel {
-	var service = utils.ConnectYoutube(false)
-
-	// Set the parameters for the request
-	var part []string
-	part = append(part, "snippet") // Specify the resource properties you want to include
-	var channels []Channel
-
-	call := service.Subscriptions.List(part)
-	call.Mine(true)
-
-	// Make the API call
-	for {
-		response, err := call.Do()
-		if err != nil {
-			urlErr, typeErr := err.(*url.Error)
-			if typeErr == true {
-				retrieveErr := urlErr.Err.(*oauth2.RetrieveError)
-				if retrieveErr.ErrorCode == "invalid_grant" {
-					service = utils.ConnectYoutube(true)
-					call = service.Subscriptions.List(part)
-					call.Mine(true)
-					continue
-				} else {
-					utils.HandleError(err, "Error retrieving channels")
-				}
-			} else {
-				googleErr, typeErr := err.(*googleapi.Error)
-				if typeErr == true {
-					if googleErr.Code == 401 {
-						service = utils.ConnectYoutube(true)
-						call = service.Subscriptions.List(part)
-						call.Mine(true)
-						continue
-					} else {
-						utils.HandleError(err, "Error retrieving channels")
-					}
-				}
-			}
-		}
-
-		for _, subInfo := range response.Items {
-			var channel Channel
-			channel.id = subInfo.Snippet.ResourceId.ChannelId
-			channel.name = subInfo.Snippet.Title
-			channel.description = subInfo.Snippet.Description
-
-			// fmt.Printf("%s %s %s\n", Id, Name, Description)
-			fmt.Printf(".")
-			channels = append(channels, channel)
-		}
-
-		if response.NextPageToken != "" {
-			call.PageToken(response.NextPageToken)
-		} else {
-			break
-		}
-
-		if len(channels) > 300 {
-			break
-		}
-	}
-	fmt.Printf("\n")
+func (c Channel) Id() string                { return c.id }
+func (c Channel) FilterValue() string       { return c.name }
+func (c Channel) Title() string             { return c.name }
+func (c Channel) Name() string              { return c.name }
+func (c Channel) Description() string       { return c.description }
+func (c Channel) Notes() string             { return c.notes }
+func (c Channel) Tags() []int64             { return c.tags }
+func (c Channel) RuleTags() []int64         { return c.ruleTags }
+func (c Channel) UnsubscribedAt() time.Time { return c.unsubscribedAt }
+func (c Channel) Unsubscribed() bool        { return !c.unsubscribedAt.IsZero() }
+func (c Channel) Archived() bool            { return c.archived }
+func (c Channel) Details() Details          { return c.details }
+func (c Channel) LatestVideo() feed.Video   { return c.latestVideo }
+func (c Channel) FeedCheckedAt() time.Time  { return c.feedCheckedAt }
+
+// TaggedAt is when the channel was given a tag, zero if it isn't tagged with
+// it or that's not known.
+func (c Channel) TaggedAt(tagId int64) time.Time { return c.taggedAt[tagId] }
+func (c *Channel) SetDescription(x string)       { c.description = x }
+
+// FeedUrl is the address of the channel's rss feed of uploads.
+func (c Channel) FeedUrl() string {
+	return "https://www.youtube.com/feeds/videos.xml?channel_id=" + url.QueryEscape(c.id)
+}
 
-	return channels
+// Url is the address of the channel's page on youtube.
+func (c Channel) Url() string {
+	return "https://www.youtube.com/channel/" + url.PathEscape(c.id)
 }
 
+// RuleExceptionSql records that a rule-made link between a channel and a tag
+// was removed by hand, so rules leave it alone from then on. It's run before
+// the link is deleted, with the channel id and tag id.
+const RuleExceptionSql = `
+	insert or ignore into rule_exceptions (channelId, tagId)
+	select channelId, tagId from links where channelId = :channelId and tagId = :tagId and source = 'rule'
+`
+
 func (c *Channel) SetTags(x []int64) error {
 	ctx := context.Background()
 
@@ -124,10 +122,30 @@ func (c *Channel) SetTags(x []int64) error {
 	defer cancel()
 
 	toAdd := utils.IntDifference(x, c.tags)
+	toDelete := utils.IntDifference(c.tags, x)
+
+	w, err := history.Watch()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	for _, tagId := range append(slices.Clone(toAdd), toDelete...) {
+		var key = map[string]any{"channelId": c.id, "tagId": tagId}
+		err := w.Row("links", key)
+		if err != nil {
+			return err
+		}
+		err = w.Row("rule_exceptions", key)
+		if err != nil {
+			return err
+		}
+	}
+
 	for _, tagId := range toAdd {
 		var insertSql = "insert into links (tagId, channelId) values (:tagId, :channelId)"
 
-		insertSth, err := utils.DbConn.PrepareContext(ctx, insertSql)
+		insertSth, err := w.Tx().PrepareContext(ctx, insertSql)
 		if err != nil {
 			return err
 		}
@@ -138,11 +156,16 @@ func (c *Channel) SetTags(x []int64) error {
 		}
 	}
 
-	toDelete := utils.IntDifference(c.tags, x)
 	for _, tagId := range toDelete {
+		// so the rule that made the link doesn't just make it again
+		_, err := w.Tx().ExecContext(ctx, RuleExceptionSql, c.id, tagId)
+		if err != nil {
+			return err
+		}
+
 		var deleteSql = "delete from links where tagId=:tagId and channelId=:channelId"
 
-		deleteSth, err := utils.DbConn.PrepareContext(ctx, deleteSql)
+		deleteSth, err := w.Tx().PrepareContext(ctx, deleteSql)
 		if err != nil {
 			return err
 		}
@@ -153,7 +176,24 @@ func (c *Channel) SetTags(x []int64) error {
 		}
 	}
 
+	err = w.Record(fmt.Sprintf("set tags of channel %q", c.name))
+	if err != nil {
+		return err
+	}
+
 	c.tags = x
+	c.ruleTags = slices.DeleteFunc(c.ruleTags, func(tagId int64) bool { return !slices.Contains(x, tagId) })
+	// the map is shared with the copies of the channel from before
+	c.taggedAt = maps.Clone(c.taggedAt)
+	if c.taggedAt == nil {
+		c.taggedAt = make(map[int64]time.Time)
+	}
+	for _, tagId := range toAdd {
+		c.taggedAt[tagId] = time.Now().UTC().Truncate(time.Second)
+	}
+	for _, tagId := range toDelete {
+		delete(c.taggedAt, tagId)
+	}
 
 	return nil
 }
@@ -164,9 +204,20 @@ func (c *Channel) SetNotes(x string) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	w, err := history.Watch()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	err = w.Row("channels", map[string]any{"id": c.id})
+	if err != nil {
+		return err
+	}
+
 	var updateSql = "update channels set notes = :notes where id = :id"
 
-	updateSth, err := utils.DbConn.PrepareContext(ctx, updateSql)
+	updateSth, err := w.Tx().PrepareContext(ctx, updateSql)
 	if err != nil {
 		return err
 	}
@@ -176,6 +227,11 @@ func (c *Channel) SetNotes(x string) error {
 		return err
 	}
 
+	err = w.Record(fmt.Sprintf("set notes of channel %q", c.name))
+	if err != nil {
+		return err
+	}
+
 	c.notes = x
 
 	return nil
@@ -195,6 +251,13 @@ func (c Channels) ByName() map[string]Channel {
 }
 
 func (c *Channels) LoadEntriesFromDb() {
+	err := c.Load()
+	utils.HandleError(err, "Loading existing channels from db")
+}
+
+// Load replaces c with the channels in the db. Unlike LoadEntriesFromDb it
+// returns an error rather than exiting when they can't be read.
+func (c *Channels) Load() error {
 	var channelsById = make(map[string]Channel)
 	var channelsByName = make(map[string]Channel)
 
@@ -203,118 +266,185 @@ func (c *Channels) LoadEntriesFromDb() {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	var csText = "select id, name, description, ifnull(notes, '') from channels"
+	var csText = `
+		select id, name, description, ifnull(notes, ''), ifnull(unsubscribedAt, ''), archived,
+			ifnull(avatarUrl, ''), subscriberCount, videoCount, ifnull(country, ''), ifnull(customUrl, ''), ifnull(createdAt, ''), ifnull(enrichedAt, ''),
+			ifnull(latestVideoId, ''), ifnull(latestVideoTitle, ''), ifnull(latestVideoAt, ''), ifnull(feedCheckedAt, '')
+		from channels
+	`
 
-	csSth, err := utils.DbConn.PrepareContext(ctx, csText)
-	utils.HandleError(err, "Unable to prepare sqlSelect "+csText)
+	rows, err := utils.DbConn.QueryContext(ctx, csText)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 
-	rows, err := csSth.QueryContext(ctx)
-	utils.HandleError(err, "Unable to run sqlSelect "+csText)
+	var linkText = "select tagId, source, ifnull(createdAt, '') from links where channelId = :id"
+
+	linkSth, err := utils.DbConn.PrepareContext(ctx, linkText)
+	if err != nil {
+		return err
+	}
+	defer linkSth.Close()
 
-	defer rows.Close()
 	for rows.Next() {
 		var channel Channel
-		err = rows.Scan(&channel.id, &channel.name, &channel.description, &channel.notes)
-		utils.HandleError(err, "Loading existing channels from db (row)")
-
-		var linkText = "select * from links where channelId = :id"
-
-		linkSth, err := utils.DbConn.PrepareContext(ctx, linkText)
-		utils.HandleError(err, "Unable to prepare sqlSelect "+linkText)
+		var unsubscribedAt string
+		var subscriberCount, videoCount sql.NullInt64
+		var createdAt, enrichedAt string
+		var latestVideoAt, feedCheckedAt string
+		err = rows.Scan(&channel.id, &channel.name, &channel.description, &channel.notes, &unsubscribedAt, &channel.archived,
+			&channel.details.AvatarUrl, &subscriberCount, &videoCount, &channel.details.Country, &channel.details.CustomUrl, &createdAt, &enrichedAt,
+			&channel.latestVideo.Id, &channel.latestVideo.Title, &latestVideoAt, &feedCheckedAt)
+		if err != nil {
+			return err
+		}
 
-		linkRows, err := linkSth.QueryContext(ctx, channel.id)
-		utils.HandleError(err, "Unable to run sqlSelect "+linkText)
+		channel.latestVideo.PublishedAt, err = parseDbTime(latestVideoAt)
+		if err != nil {
+			return fmt.Errorf("latestVideoAt of %s: %w", channel.id, err)
+		}
+		channel.feedCheckedAt, err = parseDbTime(feedCheckedAt)
+		if err != nil {
+			return fmt.Errorf("feedCheckedAt of %s: %w", channel.id, err)
+		}
 
-		defer linkRows.Close()
-		for linkRows.Next() {
-			var channelId string
-			var tagId int64
+		if enrichedAt != "" {
+			channel.details.EnrichedAt, err = parseDbTime(enrichedAt)
+			if err != nil {
+				return fmt.Errorf("enrichedAt of %s: %w", channel.id, err)
+			}
+			channel.details.SubscriberCount = subscriberCount.Int64
+			channel.details.VideoCount = videoCount.Int64
+			// the counts are left null for channels youtube didn't know
+			channel.details.SubscribersHidden = !subscriberCount.Valid && videoCount.Valid
+		}
+		channel.details.CreatedAt, err = parseDbTime(createdAt)
+		if err != nil {
+			return fmt.Errorf("createdAt of %s: %w", channel.id, err)
+		}
 
-			err = linkRows.Scan(&channelId, &tagId)
-			utils.HandleError(err, "Loading existing links from db (row)")
+		channel.unsubscribedAt, err = parseDbTime(unsubscribedAt)
+		if err != nil {
+			return fmt.Errorf("unsubscribedAt of %s: %w", channel.id, err)
+		}
 
-			channel.tags = append(channel.tags, tagId)
+		err = channel.loadLinks(ctx, linkSth)
+		if err != nil {
+			return fmt.Errorf("links of %s: %w", channel.id, err)
 		}
-		slices.Sort(channel.tags)
+
 		channelsById[channel.id] = channel
 		channelsByName[channel.name] = channel
 	}
-	utils.HandleError(err, "Loading existing channels from db")
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
 	c.byId = channelsById
 	c.byName = channelsByName
+
+	return nil
 }
 
-func (c *Channels) CompareAndUpdateChannelsDb(newChannels []Channel) {
-	for _, newEntry := range newChannels {
-		var found = false
-		var oldEntry Channel
+// loadLinks fills in the channel's tags from the links query in linkSth.
+func (c *Channel) loadLinks(ctx context.Context, linkSth *sql.Stmt) error {
+	linkRows, err := linkSth.QueryContext(ctx, c.id)
+	if err != nil {
+		return err
+	}
+	defer linkRows.Close()
 
-		for _, dbEntry := range c.byId {
-			if newEntry.id == dbEntry.id {
-				found = true
-				oldEntry = dbEntry
-				break
-			}
+	for linkRows.Next() {
+		var tagId int64
+		var source, createdAt string
+
+		err = linkRows.Scan(&tagId, &source, &createdAt)
+		if err != nil {
+			return err
 		}
 
-		if !found {
-			fmt.Printf("not found, adding to db: %s\n", newEntry.name)
-			var sqlText = `
-				insert into CHANNELS (
-					id,
-					name,
-					description
-				) values (
-					?,
-					?,
-					?
-				)
-			`
-			_, err := utils.DbConn.Exec(sqlText, newEntry.id, newEntry.name, newEntry.description)
-			utils.HandleError(err, fmt.Sprintf("Unable to insert new entry to db (%s, %s, %s)", newEntry.id, newEntry.name, newEntry.description))
-			c.byId[newEntry.id] = newEntry
-			c.byName[newEntry.name] = newEntry
-		} else {
-			if (oldEntry.name != newEntry.name) || (oldEntry.description != newEntry.description) {
-				fmt.Printf("found, updating db: %s\n", newEntry.name)
-
-				var sqlText = `
-					update CHANNELS
-					set
-						name = :name,
-						description = :description
-					where
-						id = :id
-				`
-				_, err := utils.DbConn.Exec(sqlText, newEntry.name, newEntry.description, newEntry.id)
-				utils.HandleError(err, "Unable to update entry on db")
-				c.byId[newEntry.id] = newEntry
-				c.byName[newEntry.name] = newEntry
+		if createdAt != "" {
+			if c.taggedAt == nil {
+				c.taggedAt = make(map[int64]time.Time)
+			}
+			c.taggedAt[tagId], err = parseDbTime(createdAt)
+			if err != nil {
+				return err
 			}
 		}
+
+		c.tags = append(c.tags, tagId)
+		if source == "rule" {
+			c.ruleTags = append(c.ruleTags, tagId)
+		}
 	}
+	slices.Sort(c.tags)
+	slices.Sort(c.ruleTags)
 
-	for _, dbEntry := range c.byId {
-		var found = false
+	return linkRows.Err()
+}
 
-		for _, newEntry := range newChannels {
-			if newEntry.id == dbEntry.id {
-				found = true
-				break
-			}
+// parseDbTime parses a time stored in the db, where the empty string is the
+// zero time.
+func parseDbTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// CompareAndUpdateChannelsDb brings the db in line with newChannels, applying
+// every change without asking. Channels that are no longer in newChannels are
+// archived as unsubscribed rather than deleted, so their notes and tags
+// survive and come back if the channel is subscribed to again. That only
+// happens if the list is complete, so a truncated list from youtube can't
+// archive channels that simply weren't retrieved.
+func (c *Channels) CompareAndUpdateChannelsDb(list SubscriptionList) {
+	cs := c.Diff(list)
+	cs.AcceptAll()
+
+	for _, ch := range cs.Changes {
+		fmt.Printf("%s\n", ch)
+	}
+	if !cs.Complete {
+		fmt.Printf("The retrieved subscription list looks incomplete (%s), so no channels were marked as unsubscribed.\n", cs.Incomplete)
+	}
+
+	err := c.ApplyChanges(cs)
+	utils.HandleError(err, "Unable to update channels in db")
+}
+
+// PurgeUnsubscribed permanently deletes the channels marked as unsubscribed,
+// along with their notes and tag links. It returns the purged channels.
+func (c *Channels) PurgeUnsubscribed() ([]Channel, error) {
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var deleteSql = "delete from channels where id = :id"
+
+	deleteSth, err := utils.DbConn.PrepareContext(ctx, deleteSql)
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []Channel
+	for _, dbEntry := range c.byId {
+		if !dbEntry.Unsubscribed() {
+			continue
 		}
 
-		if !found {
-			fmt.Printf("not found, deleting from db: %s\n", dbEntry.name)
-
-			var sqlText = `
-				delete from CHANNELS
-				where id = :id
-			`
-			_, err := utils.DbConn.Exec(sqlText, dbEntry.id)
-			utils.HandleError(err, "Unable to delete entry on db")
-			delete(c.byId, dbEntry.id)
-			delete(c.byName, dbEntry.name)
+		_, err = deleteSth.ExecContext(ctx, dbEntry.id)
+		if err != nil {
+			return purged, err
 		}
+
+		delete(c.byId, dbEntry.id)
+		delete(c.byName, dbEntry.name)
+		purged = append(purged, dbEntry)
 	}
+
+	return purged, nil
 }
@@ -0,0 +1,80 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package channel
+
+import (
+	"path/filepath"
+	"testing"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+// openTestDb points utils.DbConn at a freshly migrated db file in a temp dir
+// and returns the (empty) channels loaded from it.
+func openTestDb(t *testing.T) *Channels {
+	t.Helper()
+
+	utils.InitDb(filepath.Join(t.TempDir(), "ysm.db"), 1)
+	t.Cleanup(func() {
+		utils.DbConn.Close()
+		utils.DbConn = nil
+	})
+
+	var channels Channels
+	channels.LoadEntriesFromDb()
+
+	return &channels
+}
+
+func TestApplyChangesMigratedFileDb(t *testing.T) {
+	channels := openTestDb(t)
+
+	cs := channels.Diff(SubscriptionList{Channels: []Channel{
+		NewChannel("UC111", "Alpha Speedruns", "fast games"),
+		NewChannel("UC222", "Beta Cooking", "slow food"),
+	}})
+	cs.AcceptAll()
+
+	err := channels.ApplyChanges(cs)
+	if err != nil {
+		t.Fatalf("ApplyChanges: %v", err)
+	}
+
+	// the update trigger rewrites the full-text index too
+	renamed := channels.Diff(SubscriptionList{Channels: []Channel{
+		NewChannel("UC111", "Alpha Speedruns", "fast games"),
+		NewChannel("UC222", "Gamma Cooking", "slow food"),
+	}})
+	renamed.AcceptAll()
+
+	err = channels.ApplyChanges(renamed)
+	if err != nil {
+		t.Fatalf("ApplyChanges (rename): %v", err)
+	}
+
+	var reloaded Channels
+	reloaded.LoadEntriesFromDb()
+	if len(reloaded.ById()) != 2 || reloaded.ById()["UC222"].Name() != "Gamma Cooking" {
+		t.Fatalf("reloaded channels = %v, want Alpha Speedruns and Gamma Cooking", reloaded.ById())
+	}
+
+	results, err := Search("gamma", false, "[", "]", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].ChannelId != "UC222" || results[0].Name != "[Gamma] Cooking" {
+		t.Errorf("Search(gamma) = %v, want UC222 as [Gamma] Cooking", results)
+	}
+}
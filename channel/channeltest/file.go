@@ -0,0 +1,61 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package channeltest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// WriteSubscriptionsFile writes subs to path in the format channel.FileSource
+// reads.
+func WriteSubscriptionsFile(path string, subs []Subscription) error {
+	type item struct {
+		Id          string `json:"id"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	var items = make([]item, 0, len(subs))
+	for _, sub := range subs {
+		items = append(items, item{Id: sub.ChannelId, Name: sub.Title, Description: sub.Description})
+	}
+
+	output, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, output, 0644)
+}
+
+// Subscriptions returns n made up subscriptions with predictable ids and
+// names, channel-0001 and so on.
+func Subscriptions(n int) []Subscription {
+	var subs = make([]Subscription, 0, n)
+	for i := 1; i <= n; i++ {
+		subs = append(subs, Subscription{
+			ChannelId:   fmt.Sprintf("UCfake%018d", i),
+			Title:       fmt.Sprintf("channel-%04d", i),
+			Description: fmt.Sprintf("description of channel %d", i),
+			Handle:      fmt.Sprintf("@channel%04d", i),
+			// every tenth channel hides its subscriber count
+			SubscriberCount: uint64(i%10) * 1000,
+			VideoCount:      uint64(i * 3),
+			Country:         "AU",
+		})
+	}
+
+	return subs
+}
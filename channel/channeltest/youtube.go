@@ -0,0 +1,281 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+// Package channeltest has stand-ins for the places subscription lists come
+// from, so syncing can be exercised without a youtube account or network.
+package channeltest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/option"
+	"google.golang.org/api/youtube/v3"
+)
+
+type Subscription struct {
+	ChannelId   string
+	Title       string
+	Description string
+	// the rest are only used for channels.list
+	Handle          string
+	SubscriberCount uint64
+	VideoCount      uint64
+	Country         string
+}
+
+// FakeYoutube is an http server answering subscriptions.list requests the way
+// the youtube data api does: in pages, with etags that If-None-Match is
+// checked against, and with 401s once the access token it handed out has been
+// expired. It also answers channels.list requests for the subscribed channels.
+type FakeYoutube struct {
+	server *httptest.Server
+
+	mu            sync.Mutex
+	subscriptions []Subscription
+	pageSize      int
+	totalResults  int64
+	token         int
+	requests      int
+	notModified   int
+	unauthorized  int
+}
+
+// NewFakeYoutube starts a server with subs as the subscription list, served 50
+// to a page like youtube does. Close it when done.
+func NewFakeYoutube(subs []Subscription) *FakeYoutube {
+	f := &FakeYoutube{
+		subscriptions: subs,
+		pageSize:      50,
+		token:         1,
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+
+	return f
+}
+
+func (f *FakeYoutube) Close() { f.server.Close() }
+
+func (f *FakeYoutube) URL() string { return f.server.URL }
+
+// Requests, NotModified and Unauthorized count the requests answered so far,
+// the ones answered with a 304 and the ones rejected with a 401.
+func (f *FakeYoutube) Requests() int     { f.mu.Lock(); defer f.mu.Unlock(); return f.requests }
+func (f *FakeYoutube) NotModified() int  { f.mu.Lock(); defer f.mu.Unlock(); return f.notModified }
+func (f *FakeYoutube) Unauthorized() int { f.mu.Lock(); defer f.mu.Unlock(); return f.unauthorized }
+
+func (f *FakeYoutube) SetSubscriptions(subs []Subscription) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subscriptions = subs
+}
+
+func (f *FakeYoutube) SetPageSize(pageSize int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pageSize = pageSize
+}
+
+// SetTotalResults makes the server report a total other than the length of
+// the list, like youtube does when it quietly leaves subscriptions out. 0 goes
+// back to reporting the real length.
+func (f *FakeYoutube) SetTotalResults(total int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.totalResults = total
+}
+
+// ExpireToken makes the server reject the access token it handed out last, so
+// the client has to connect again with reauth set.
+func (f *FakeYoutube) ExpireToken() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.token++
+}
+
+// Connect returns a youtube service pointed at the server. It has the same
+// signature as the connect func given to channel.NewYoutubeSourceConnect. A
+// service made without reauth uses the token from before the last
+// ExpireToken, like a stale cached credential would.
+func (f *FakeYoutube) Connect(reauth bool) (*youtube.Service, error) {
+	f.mu.Lock()
+	token := f.token
+	f.mu.Unlock()
+	if !reauth && token > 1 {
+		token--
+	}
+
+	ctx := context.Background()
+	client := oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: tokenString(token)}))
+
+	return youtube.NewService(ctx, option.WithEndpoint(f.server.URL+"/"), option.WithHTTPClient(client))
+}
+
+func tokenString(token int) string {
+	return fmt.Sprintf("fake-token-%d", token)
+}
+
+func (f *FakeYoutube) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests++
+
+	if r.Header.Get("Authorization") != "Bearer "+tokenString(f.token) {
+		f.unauthorized++
+		writeError(w, http.StatusUnauthorized, "Invalid Credentials")
+		return
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/channels") {
+		f.serveChannels(w, r)
+		return
+	}
+	if !strings.HasSuffix(r.URL.Path, "/subscriptions") {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	if r.FormValue("mine") != "true" {
+		writeError(w, http.StatusBadRequest, "only mine=true is supported")
+		return
+	}
+
+	pageSize := f.pageSize
+	if maxResults := r.FormValue("maxResults"); maxResults != "" {
+		n, err := strconv.Atoi(maxResults)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid maxResults")
+			return
+		}
+		pageSize = min(pageSize, n)
+	}
+
+	start := 0
+	if pageToken := r.FormValue("pageToken"); pageToken != "" {
+		n, err := strconv.Atoi(strings.TrimPrefix(pageToken, "page-"))
+		if err != nil || n < 0 || n > len(f.subscriptions) {
+			writeError(w, http.StatusBadRequest, "Invalid pageToken")
+			return
+		}
+		start = n
+	}
+	end := min(start+pageSize, len(f.subscriptions))
+
+	response := youtube.SubscriptionListResponse{
+		Kind:     "youtube#subscriptionListResponse",
+		PageInfo: &youtube.PageInfo{ResultsPerPage: int64(pageSize), TotalResults: int64(len(f.subscriptions))},
+	}
+	if f.totalResults != 0 {
+		response.PageInfo.TotalResults = f.totalResults
+	}
+	if end < len(f.subscriptions) {
+		response.NextPageToken = fmt.Sprintf("page-%d", end)
+	}
+	for _, sub := range f.subscriptions[start:end] {
+		response.Items = append(response.Items, &youtube.Subscription{
+			Kind: "youtube#subscription",
+			Snippet: &youtube.SubscriptionSnippet{
+				Title:       sub.Title,
+				Description: sub.Description,
+				ResourceId:  &youtube.ResourceId{Kind: "youtube#channel", ChannelId: sub.ChannelId},
+			},
+		})
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	response.Etag = fmt.Sprintf("%x", sha256.Sum256(body))
+
+	if r.Header.Get("If-None-Match") == response.Etag {
+		f.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body, err = json.Marshal(response)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", response.Etag)
+	w.Write(body)
+}
+
+// serveChannels answers a channels.list request for the ids in the id
+// parameter, leaving out the ones that aren't subscribed to.
+func (f *FakeYoutube) serveChannels(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	var ids []string
+	for _, id := range r.Form["id"] {
+		ids = append(ids, strings.Split(id, ",")...)
+	}
+	if len(ids) == 0 || len(ids) > 50 {
+		writeError(w, http.StatusBadRequest, "between 1 and 50 ids are needed")
+		return
+	}
+
+	response := youtube.ChannelListResponse{Kind: "youtube#channelListResponse"}
+	for _, sub := range f.subscriptions {
+		if !slices.Contains(ids, sub.ChannelId) {
+			continue
+		}
+		response.Items = append(response.Items, &youtube.Channel{
+			Kind: "youtube#channel",
+			Id:   sub.ChannelId,
+			Snippet: &youtube.ChannelSnippet{
+				Title:       sub.Title,
+				Description: sub.Description,
+				CustomUrl:   sub.Handle,
+				Country:     sub.Country,
+				PublishedAt: "2015-06-01T12:00:00Z",
+				Thumbnails: &youtube.ThumbnailDetails{
+					Medium: &youtube.Thumbnail{Url: f.server.URL + "/avatars/" + sub.ChannelId + ".jpg", Width: 240, Height: 240},
+				},
+			},
+			Statistics: &youtube.ChannelStatistics{
+				SubscriberCount:       sub.SubscriberCount,
+				HiddenSubscriberCount: sub.SubscriberCount == 0,
+				VideoCount:            sub.VideoCount,
+			},
+		})
+	}
+	response.PageInfo = &youtube.PageInfo{ResultsPerPage: int64(len(response.Items)), TotalResults: int64(len(response.Items))}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeError writes an error body in the shape googleapi.CheckResponse
+// expects.
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
@@ -0,0 +1,268 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package channel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+// detailsBatchSize is how many channels youtube looks up in one request.
+const detailsBatchSize = 50
+
+// Details is what youtube says about a channel beyond its name and
+// description. It's all empty until the channel has been enriched.
+type Details struct {
+	AvatarUrl       string
+	SubscriberCount int64
+	// SubscribersHidden is set for channels that don't show how many
+	// subscribers they have, which leaves SubscriberCount at 0.
+	SubscribersHidden bool
+	VideoCount        int64
+	Country           string
+	// CustomUrl is the channel's handle, like @name.
+	CustomUrl  string
+	CreatedAt  time.Time
+	EnrichedAt time.Time
+}
+
+func (d Details) Enriched() bool { return !d.EnrichedAt.IsZero() }
+
+// HandleUrl is the address of the channel's page under its handle, or "" if
+// it hasn't got one.
+func (d Details) HandleUrl() string {
+	if d.CustomUrl == "" {
+		return ""
+	}
+	return "https://www.youtube.com/" + d.CustomUrl
+}
+
+// LookupDetails retrieves the details of the channels with ids from youtube.
+// Channels youtube doesn't know, like deleted ones, are left out.
+func (s *YoutubeSource) LookupDetails(ids []string) (map[string]Details, error) {
+	var details = make(map[string]Details)
+	var err error
+
+	if s.service == nil {
+		s.service, err = s.connect(false)
+		if err != nil {
+			return details, err
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for batch := range slices.Chunk(ids, detailsBatchSize) {
+		response, err := s.channelsCall(batch).Do()
+		if isAuthError(err) {
+			s.service, err = s.connect(true)
+			if err != nil {
+				return details, err
+			}
+			response, err = s.channelsCall(batch).Do()
+		}
+		if err != nil {
+			return details, err
+		}
+
+		for _, item := range response.Items {
+			d := detailsFromYoutube(item)
+			d.EnrichedAt = now
+			details[item.Id] = d
+		}
+	}
+
+	return details, nil
+}
+
+func (s *YoutubeSource) channelsCall(ids []string) *youtube.ChannelsListCall {
+	call := s.service.Channels.List([]string{"snippet", "statistics", "brandingSettings"})
+	call.Id(ids...)
+	call.MaxResults(detailsBatchSize)
+	return call
+}
+
+func detailsFromYoutube(item *youtube.Channel) Details {
+	var d Details
+
+	if item.Snippet != nil {
+		d.Country = item.Snippet.Country
+		d.CustomUrl = item.Snippet.CustomUrl
+		// an unparseable date is just left unknown
+		d.CreatedAt, _ = time.Parse(time.RFC3339, item.Snippet.PublishedAt)
+
+		if thumbnails := item.Snippet.Thumbnails; thumbnails != nil {
+			for _, thumbnail := range []*youtube.Thumbnail{thumbnails.Medium, thumbnails.Default, thumbnails.High} {
+				if thumbnail != nil && thumbnail.Url != "" {
+					d.AvatarUrl = thumbnail.Url
+					break
+				}
+			}
+		}
+	}
+
+	if d.Country == "" && item.BrandingSettings != nil && item.BrandingSettings.Channel != nil {
+		d.Country = item.BrandingSettings.Channel.Country
+	}
+
+	if item.Statistics != nil {
+		d.SubscriberCount = int64(item.Statistics.SubscriberCount)
+		d.SubscribersHidden = item.Statistics.HiddenSubscriberCount
+		d.VideoCount = int64(item.Statistics.VideoCount)
+	}
+
+	return d
+}
+
+// NeedEnriching returns the subscribed channels that have never been
+// enriched, or were last enriched longer ago than maxAge, sorted by name. A
+// maxAge of 0 returns them all.
+func (c Channels) NeedEnriching(maxAge time.Duration) []Channel {
+	return c.due(maxAge, func(chanInfo Channel) time.Time { return chanInfo.details.EnrichedAt })
+}
+
+// due returns the subscribed channels whose lastDone is the zero time or
+// longer ago than maxAge, sorted by name. A maxAge of 0 returns them all.
+func (c Channels) due(maxAge time.Duration, lastDone func(chanInfo Channel) time.Time) []Channel {
+	var channels []Channel
+	for _, chanInfo := range c.byId {
+		if chanInfo.Unsubscribed() {
+			continue
+		}
+		if maxAge > 0 && !lastDone(chanInfo).IsZero() && time.Since(lastDone(chanInfo)) <= maxAge {
+			continue
+		}
+		channels = append(channels, chanInfo)
+	}
+
+	slices.SortFunc(channels, func(a, b Channel) int {
+		return strings.Compare(strings.ToLower(a.name), strings.ToLower(b.name))
+	})
+
+	return channels
+}
+
+// Enrich looks the details of channels up on youtube and saves them, a batch
+// at a time, so everything up to an error is kept. Channels youtube doesn't
+// know keep whatever details they had, but are marked as enriched so they
+// aren't looked up every time. It returns how many channels youtube knew.
+// How far it's got is written to progress after each batch.
+func (c *Channels) Enrich(s *YoutubeSource, channels []Channel, progress io.Writer) (int, error) {
+	var found, done int
+
+	for batch := range slices.Chunk(channels, detailsBatchSize) {
+		var ids []string
+		for _, chanInfo := range batch {
+			ids = append(ids, chanInfo.id)
+		}
+
+		details, err := s.LookupDetails(ids)
+		if err != nil {
+			fmt.Fprintf(progress, "\n")
+			return found, fmt.Errorf("error looking up channel details: %w", err)
+		}
+		found += len(details)
+
+		var missing []string
+		for _, id := range ids {
+			if _, ok := details[id]; !ok {
+				missing = append(missing, id)
+			}
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		err = saveDetails(details, missing, now)
+		if err != nil {
+			fmt.Fprintf(progress, "\n")
+			return found, fmt.Errorf("unable to save channel details: %w", err)
+		}
+
+		for _, id := range ids {
+			entry, ok := c.byId[id]
+			if !ok {
+				continue
+			}
+			if d, ok := details[id]; ok {
+				entry.details = d
+			} else {
+				entry.details.EnrichedAt = now
+			}
+			c.byId[id] = entry
+			c.byName[entry.name] = entry
+		}
+
+		done += len(batch)
+		fmt.Fprintf(progress, "\rLooked up %d of %d channels", done, len(channels))
+	}
+	fmt.Fprintf(progress, "\n")
+
+	return found, nil
+}
+
+// saveDetails writes the details of each channel in details to the db, and
+// marks the missing channels as enriched at now, in a single transaction.
+func saveDetails(details map[string]Details, missing []string, now time.Time) error {
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := utils.DbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var updateSql = `
+		update channels set avatarUrl = :avatarUrl, subscriberCount = :subscriberCount, videoCount = :videoCount,
+			country = :country, customUrl = :customUrl, createdAt = :createdAt, enrichedAt = :enrichedAt
+		where id = :id
+	`
+	for id, d := range details {
+		var subscriberCount any = d.SubscriberCount
+		if d.SubscribersHidden {
+			subscriberCount = nil
+		}
+		var createdAt any
+		if !d.CreatedAt.IsZero() {
+			createdAt = d.CreatedAt.UTC().Format(time.RFC3339)
+		}
+
+		_, err = tx.ExecContext(ctx, updateSql, nullIfEmpty(d.AvatarUrl), subscriberCount, d.VideoCount, nullIfEmpty(d.Country), nullIfEmpty(d.CustomUrl), createdAt, d.EnrichedAt.UTC().Format(time.RFC3339), id)
+		if err != nil {
+			return fmt.Errorf("channel %s: %w", id, err)
+		}
+	}
+
+	for _, id := range missing {
+		_, err = tx.ExecContext(ctx, "update channels set enrichedAt = :enrichedAt where id = :id", now.Format(time.RFC3339), id)
+		if err != nil {
+			return fmt.Errorf("channel %s: %w", id, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func nullIfEmpty(x string) any {
+	if x == "" {
+		return nil
+	}
+	return x
+}
@@ -0,0 +1,50 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package channel
+
+import (
+	"testing"
+
+	"repo.joyrex.net/ejstacey/ysm/channel/channeltest"
+)
+
+func TestLookupDetails(t *testing.T) {
+	subs := channeltest.Subscriptions(60)
+	_, src, _ := newFakeSource(t, subs)
+
+	// more than one batch, and an id youtube doesn't know
+	var ids []string
+	for _, sub := range subs {
+		ids = append(ids, sub.ChannelId)
+	}
+	ids = append(ids, "UCdeleted")
+
+	details, err := src.LookupDetails(ids)
+	if err != nil {
+		t.Fatalf("LookupDetails: %v", err)
+	}
+	if len(details) != 60 {
+		t.Errorf("got details for %d channels, want 60", len(details))
+	}
+	if _, ok := details["UCdeleted"]; ok {
+		t.Errorf("got details for a channel youtube doesn't know")
+	}
+
+	first := details[subs[0].ChannelId]
+	if first.CustomUrl != "@channel0001" || first.SubscriberCount != 1000 || first.VideoCount != 3 || first.Country != "AU" || !first.Enriched() {
+		t.Errorf("details of %s = %+v", subs[0].Title, first)
+	}
+	if tenth := details[subs[9].ChannelId]; !tenth.SubscribersHidden {
+		t.Errorf("details of %s = %+v, want the subscriber count hidden", subs[9].Title, tenth)
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/feed"
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+const (
+	// feedWorkers is how many feeds are read at once
+	feedWorkers = 8
+	// feedBatchSize is how many feeds are read between saves
+	feedBatchSize = 50
+)
+
+// Quiet says whether the channel has been checked and hasn't uploaded
+// anything since since, or at all.
+func (c Channel) Quiet(since time.Time) bool {
+	if c.feedCheckedAt.IsZero() {
+		return false
+	}
+
+	return c.latestVideo.Id == "" || c.latestVideo.PublishedAt.Before(since)
+}
+
+// QuietSince is the time a channel has to have uploaded since to not count
+// as quiet for months.
+func QuietSince(months int) time.Time {
+	return time.Now().AddDate(0, -months, 0)
+}
+
+// NeedFeedCheck returns the subscribed channels whose feed has never been
+// checked, or was last checked longer ago than maxAge, sorted by name. A
+// maxAge of 0 returns them all.
+func (c Channels) NeedFeedCheck(maxAge time.Duration) []Channel {
+	return c.due(maxAge, func(chanInfo Channel) time.Time { return chanInfo.feedCheckedAt })
+}
+
+type feedResult struct {
+	latest feed.Video
+	err    error
+}
+
+// CheckFeeds reads the feeds of channels, a few at once, and saves the newest
+// upload in each, a batch at a time so everything up to an error is kept. A
+// channel without a feed has its upload cleared, since it's most likely been
+// deleted. Channels whose feed couldn't be read for any other reason keep
+// what they had. It returns why each of those couldn't be read. How far it's
+// got is written to progress after each batch.
+func (c *Channels) CheckFeeds(client *feed.Client, channels []Channel, progress io.Writer) (map[string]error, error) {
+	var failed = make(map[string]error)
+	var done int
+
+	for batch := range slices.Chunk(channels, feedBatchSize) {
+		var results = make(map[string]feedResult)
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+
+		ids := make(chan string)
+		for range feedWorkers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for id := range ids {
+					latest, err := client.Latest(id)
+					mu.Lock()
+					results[id] = feedResult{latest: latest, err: err}
+					mu.Unlock()
+				}
+			}()
+		}
+		for _, chanInfo := range batch {
+			ids <- chanInfo.id
+		}
+		close(ids)
+		wg.Wait()
+
+		now := time.Now().UTC().Truncate(time.Second)
+		for id, result := range results {
+			if result.err != nil {
+				failed[id] = result.err
+			}
+		}
+
+		err := saveFeedResults(results, now)
+		if err != nil {
+			fmt.Fprintf(progress, "\n")
+			return failed, fmt.Errorf("unable to save feed results: %w", err)
+		}
+
+		for id, result := range results {
+			entry, ok := c.byId[id]
+			if !ok || (result.err != nil && !errors.Is(result.err, feed.ErrNotFound)) {
+				continue
+			}
+			entry.latestVideo = result.latest
+			entry.feedCheckedAt = now
+			c.byId[id] = entry
+			c.byName[entry.name] = entry
+		}
+
+		done += len(batch)
+		fmt.Fprintf(progress, "\rChecked %d of %d feeds", done, len(channels))
+	}
+	fmt.Fprintf(progress, "\n")
+
+	return failed, nil
+}
+
+// saveFeedResults writes the newest upload of each channel in results to the
+// db in a single transaction, skipping the ones whose feed couldn't be read.
+func saveFeedResults(results map[string]feedResult, now time.Time) error {
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := utils.DbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var updateSql = `
+		update channels set latestVideoId = :latestVideoId, latestVideoTitle = :latestVideoTitle, latestVideoAt = :latestVideoAt, feedCheckedAt = :feedCheckedAt
+		where id = :id
+	`
+	for id, result := range results {
+		if result.err != nil && !errors.Is(result.err, feed.ErrNotFound) {
+			continue
+		}
+
+		var publishedAt any
+		if result.latest.Id != "" {
+			publishedAt = result.latest.PublishedAt.UTC().Format(time.RFC3339)
+		}
+
+		_, err = tx.ExecContext(ctx, updateSql, nullIfEmpty(result.latest.Id), nullIfEmpty(result.latest.Title), publishedAt, now.Format(time.RFC3339), id)
+		if err != nil {
+			return fmt.Errorf("channel %s: %w", id, err)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,82 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package channel
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/feed"
+	"repo.joyrex.net/ejstacey/ysm/feed/feedtest"
+)
+
+func TestCheckFeeds(t *testing.T) {
+	channels := openTestDb(t)
+	cs := channels.Diff(SubscriptionList{Channels: []Channel{
+		NewChannel("UC111", "Alpha", ""),
+		NewChannel("UC222", "Beta", ""),
+		NewChannel("UC333", "Gamma", ""),
+	}})
+	cs.AcceptAll()
+	err := channels.ApplyChanges(cs)
+	if err != nil {
+		t.Fatalf("ApplyChanges: %v", err)
+	}
+
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	server := feedtest.NewServer(map[string][]feed.Video{
+		"UC111": {{Id: "a1", Title: "Alpha one", PublishedAt: day}},
+		"UC222": {{Id: "b1", Title: "Beta one", PublishedAt: day}},
+		"UC333": {{Id: "c1", Title: "Gamma one", PublishedAt: day}},
+	})
+	defer server.Close()
+	client := feed.NewClient(server.URL())
+
+	failed, err := channels.CheckFeeds(client, channels.NeedFeedCheck(0), io.Discard)
+	if err != nil || len(failed) != 0 {
+		t.Fatalf("CheckFeeds = %v, %v", failed, err)
+	}
+
+	// Alpha uploads again, Beta's feed fails and Gamma is deleted
+	server.SetVideos("UC111", []feed.Video{{Id: "a2", Title: "Alpha two", PublishedAt: day.Add(time.Hour)}})
+	server.SetStatus("UC222", http.StatusInternalServerError)
+	server.SetVideos("UC333", nil)
+	checkedBefore := channels.ById()["UC222"].FeedCheckedAt()
+
+	failed, err = channels.CheckFeeds(client, channels.NeedFeedCheck(0), io.Discard)
+	if err != nil {
+		t.Fatalf("CheckFeeds: %v", err)
+	}
+	if len(failed) != 2 || failed["UC222"] == nil || !errors.Is(failed["UC333"], feed.ErrNotFound) {
+		t.Errorf("failed = %v, want UC222's error and ErrNotFound for UC333", failed)
+	}
+
+	// what was saved, not just what's in channels
+	var reloaded Channels
+	err = reloaded.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest := reloaded.ById()["UC111"].LatestVideo(); latest.Id != "a2" {
+		t.Errorf("UC111's latest video = %+v, want a2", latest)
+	}
+	if beta := reloaded.ById()["UC222"]; beta.LatestVideo().Id != "b1" || !beta.FeedCheckedAt().Equal(checkedBefore) {
+		t.Errorf("UC222 = %+v checked at %s, want b1 kept from the last check", beta.LatestVideo(), beta.FeedCheckedAt())
+	}
+	if gamma := reloaded.ById()["UC333"]; gamma.LatestVideo().Id != "" || gamma.FeedCheckedAt().IsZero() {
+		t.Errorf("UC333 = %+v, want its video cleared now its feed is gone", gamma.LatestVideo())
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package channel
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+// SearchResult is a channel that matched a search. Name and Snippet have
+// the matching words wrapped in the markers given to Search. Snippet is the
+// best matching part of the name, description or notes.
+type SearchResult struct {
+	ChannelId string
+	Name      string
+	Snippet   string
+}
+
+// how much a match in each column of channels_fts counts towards the rank,
+// in column order (the id isn't searched)
+const searchWeights = "0.0, 10.0, 1.0, 5.0"
+
+// Search finds channels by what's in their names, descriptions and notes,
+// best match first. Each word only has to match the start of a word in the
+// channel, so results show up while a word is still being typed. With
+// raw, text is used as an fts5 query as is, see https://sqlite.org/fts5.html.
+// Matching words are put between start and end in the results.
+func Search(text string, raw bool, start string, end string, limit int) ([]SearchResult, error) {
+	var results []SearchResult
+
+	match := text
+	if !raw {
+		match = searchQuery(text)
+	}
+	if strings.TrimSpace(match) == "" {
+		return results, nil
+	}
+
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var searchSql = `
+		select id, highlight(channels_fts, 1, :start, :end), snippet(channels_fts, -1, :start, :end, '…', 12)
+		from channels_fts
+		where channels_fts match :match
+		order by bm25(channels_fts, ` + searchWeights + `)
+		limit :limit
+	`
+
+	rows, err := utils.DbConn.QueryContext(ctx, searchSql, sql.Named("start", start), sql.Named("end", end), sql.Named("match", match), sql.Named("limit", limit))
+	if err != nil {
+		return results, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var result SearchResult
+		err = rows.Scan(&result.ChannelId, &result.Name, &result.Snippet)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// searchQuery turns what was typed into an fts5 query. Every word is quoted,
+// so nothing in it is taken as fts5 syntax, and made a prefix.
+func searchQuery(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"*`
+	}
+
+	return strings.Join(words, " ")
+}
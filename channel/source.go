@@ -0,0 +1,338 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package channel
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+// ErrNotModified is returned by a SubscriptionSource when the page asked for
+// still has the etag it was asked with.
+var ErrNotModified = errors.New("page not modified")
+
+// SubscriptionSource is somewhere the subscription list comes from. The list
+// is retrieved a page at a time, starting with the empty page token.
+type SubscriptionSource interface {
+	// Name is what the sync state and cached pages are stored under.
+	Name() string
+	// Page retrieves the page for pageToken. If etag isn't empty and the page
+	// hasn't changed since, it returns ErrNotModified.
+	Page(pageToken string, etag string) (SubscriptionPage, error)
+}
+
+type SubscriptionPage struct {
+	Etag          string
+	NextPageToken string
+	// TotalResults is how many subscriptions the source says there are in
+	// total, or 0 if it doesn't know.
+	TotalResults int64
+	Channels     []Channel
+}
+
+func NewChannel(id string, name string, description string) Channel {
+	return Channel{id: id, name: name, description: description}
+}
+
+// NewSource returns the subscription source set up in the settings.
+func NewSource(settings utils.Settings) (SubscriptionSource, error) {
+	switch settings.Source {
+	case "", "youtube":
+		return NewYoutubeSource(), nil
+	case "file", "takeout":
+		if settings.SourceFile == "" {
+			return nil, fmt.Errorf("source is %q but SourceFile isn't set", settings.Source)
+		}
+		if settings.Source == "takeout" {
+			return NewTakeoutSource(settings.SourceFile), nil
+		}
+		return NewFileSource(settings.SourceFile, 0), nil
+	}
+
+	return nil, fmt.Errorf("unknown Source %q, expected \"youtube\", \"file\" or \"takeout\"", settings.Source)
+}
+
+// SubscriptionList is a subscription list retrieved by LoadChannels.
+type SubscriptionList struct {
+	Channels []Channel
+	// Incomplete says why the list looks truncated, so channels missing from
+	// it can't be taken as unsubscribed. It's empty if the list looks whole.
+	Incomplete string
+	// State is recorded, along with the pages for the next sync's etags, when
+	// the changes from the list are applied. Nothing is recorded for a dry run
+	// or a review that's abandoned, so the next sync isn't skipped.
+	State SyncState
+	pages []syncPage
+}
+
+func (l SubscriptionList) Complete() bool { return l.Incomplete == "" }
+
+// LoadChannels retrieves the subscription list from src, stopping once
+// maxChannels have been retrieved (0 means no limit). The list is marked as
+// incomplete if the limit was hit, if a page before the last came back
+// empty, or if at least a whole page's worth of the subscriptions the source
+// reported are missing. A smaller shortfall is normal for youtube, whose
+// total counts terminated and hidden channels that are never listed.
+//
+// Each page is requested with the etag it had on the last sync, and pages the
+// source says haven't changed are reused from the db instead. Nothing is
+// saved until the list is applied with ApplyChanges. How far it's got, and
+// anything odd about the list, is written to progress.
+func LoadChannels(src SubscriptionSource, maxChannels int, progress io.Writer) (SubscriptionList, error) {
+	var list SubscriptionList
+
+	cachedPages, err := loadSyncPages(src.Name())
+	if err != nil {
+		return list, fmt.Errorf("unable to load cached subscription pages: %w", err)
+	}
+
+	var pages []syncPage
+	var totalResults int64
+	var pageSize int
+	var unchangedPages = 0
+	var pageToken = ""
+
+	for {
+		var page = syncPage{pageToken: pageToken}
+
+		cached, ok := cachedPages[pageToken]
+		var etag string
+		if ok {
+			etag = cached.Etag
+		}
+
+		page.SubscriptionPage, err = src.Page(pageToken, etag)
+		if errors.Is(err, ErrNotModified) && ok {
+			page = cached
+			unchangedPages++
+		} else if err != nil {
+			fmt.Fprintf(progress, "\n")
+			return SubscriptionList{}, fmt.Errorf("error retrieving channels: %w", err)
+		}
+
+		pages = append(pages, page)
+		list.Channels = append(list.Channels, page.Channels...)
+		if page.TotalResults > totalResults {
+			totalResults = page.TotalResults
+		}
+		pageSize = max(pageSize, len(page.Channels))
+		fmt.Fprintf(progress, "\rRetrieved %d of %d subscriptions", len(list.Channels), totalResults)
+
+		if page.NextPageToken == "" {
+			break
+		}
+
+		if len(page.Channels) == 0 && list.Incomplete == "" {
+			list.Incomplete = fmt.Sprintf("page %d came back empty", len(pages))
+		}
+
+		if maxChannels > 0 && len(list.Channels) >= maxChannels {
+			fmt.Fprintf(progress, "\nStopped after reaching the MaxSubscriptions limit of %d.", maxChannels)
+			list.Incomplete = fmt.Sprintf("it stopped at the MaxSubscriptions limit of %d", maxChannels)
+			break
+		}
+
+		pageToken = page.NextPageToken
+	}
+	fmt.Fprintf(progress, " (%d of %d pages unchanged since the last sync)\n", unchangedPages, len(pages))
+
+	if missing := totalResults - int64(len(list.Channels)); missing > 0 && list.Incomplete == "" {
+		if pageSize > 0 && missing < int64(pageSize) {
+			fmt.Fprintf(progress, "%s reported %d subscriptions but listed %d. The missing ones are usually terminated or hidden channels, so they aren't taken as a sign of a truncated list.\n", src.Name(), totalResults, len(list.Channels))
+		} else {
+			list.Incomplete = fmt.Sprintf("%s reported %d subscriptions but only listed %d, at least a page short", src.Name(), totalResults, len(list.Channels))
+		}
+	}
+
+	list.pages = pages
+	list.State = SyncState{
+		Source:         src.Name(),
+		LastSync:       time.Now(),
+		ChannelCount:   len(list.Channels),
+		PageCount:      len(pages),
+		UnchangedPages: unchangedPages,
+	}
+
+	return list, nil
+}
+
+// LastSync returns when the subscription list was last retrieved from src, or
+// the zero time if it never has been.
+func LastSync(src SubscriptionSource) time.Time {
+	state, err := LoadSyncState(src.Name())
+	utils.HandleError(err, "Unable to load sync state")
+
+	return state.LastSync
+}
+
+// YoutubeSource retrieves the subscription list of the authenticated user from
+// the youtube data api.
+type YoutubeSource struct {
+	connect func(reauth bool) (*youtube.Service, error)
+	service *youtube.Service
+}
+
+// NewYoutubeSource returns a source that connects to youtube with the cached
+// oauth credential, going through the browser auth when it's missing or
+// stops working.
+func NewYoutubeSource() *YoutubeSource {
+	return NewYoutubeSourceConnect(func(reauth bool) (*youtube.Service, error) {
+		return utils.ConnectYoutube(reauth), nil
+	})
+}
+
+// NewYoutubeSourceConnect returns a source that gets its service from connect.
+// connect is called with reauth set when the credential it returned before
+// was rejected.
+func NewYoutubeSourceConnect(connect func(reauth bool) (*youtube.Service, error)) *YoutubeSource {
+	return &YoutubeSource{connect: connect}
+}
+
+func (s *YoutubeSource) Name() string { return "youtube" }
+
+func (s *YoutubeSource) Page(pageToken string, etag string) (SubscriptionPage, error) {
+	var page SubscriptionPage
+	var err error
+
+	if s.service == nil {
+		s.service, err = s.connect(false)
+		if err != nil {
+			return page, err
+		}
+	}
+
+	response, err := s.listCall(pageToken, etag).Do()
+	if isAuthError(err) {
+		s.service, err = s.connect(true)
+		if err != nil {
+			return page, err
+		}
+		response, err = s.listCall(pageToken, etag).Do()
+	}
+	if googleapi.IsNotModified(err) {
+		return page, ErrNotModified
+	}
+	if err != nil {
+		return page, err
+	}
+
+	page.Etag = response.Etag
+	page.NextPageToken = response.NextPageToken
+	if response.PageInfo != nil {
+		page.TotalResults = response.PageInfo.TotalResults
+	}
+	for _, subInfo := range response.Items {
+		page.Channels = append(page.Channels, NewChannel(subInfo.Snippet.ResourceId.ChannelId, subInfo.Snippet.Title, subInfo.Snippet.Description))
+	}
+
+	return page, nil
+}
+
+func (s *YoutubeSource) listCall(pageToken string, etag string) *youtube.SubscriptionsListCall {
+	call := s.service.Subscriptions.List([]string{"snippet"})
+	call.Mine(true)
+	call.MaxResults(50)
+	if pageToken != "" {
+		call.PageToken(pageToken)
+	}
+	if etag != "" {
+		call.IfNoneMatch(etag)
+	}
+	return call
+}
+
+// isAuthError says whether err means the oauth credential has expired or been
+// revoked, so it's worth authenticating again.
+func isAuthError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		var retrieveErr *oauth2.RetrieveError
+		return errors.As(urlErr.Err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant"
+	}
+
+	var googleErr *googleapi.Error
+	return errors.As(err, &googleErr) && googleErr.Code == 401
+}
+
+// FileSource reads the subscription list from a json file holding an array of
+// {"id", "name", "description"} objects, which is handy for fixtures and for
+// trying things out without a youtube account.
+type FileSource struct {
+	path     string
+	pageSize int
+}
+
+// NewFileSource returns a source reading from path. If pageSize is more than
+// 0 the list is split into pages of that size, like youtube does.
+func NewFileSource(path string, pageSize int) *FileSource {
+	return &FileSource{path: path, pageSize: pageSize}
+}
+
+func (s *FileSource) Name() string { return "file" }
+
+func (s *FileSource) Page(pageToken string, etag string) (SubscriptionPage, error) {
+	var page SubscriptionPage
+
+	input, err := os.ReadFile(s.path)
+	if err != nil {
+		return page, err
+	}
+
+	var items []pageItem
+	err = json.Unmarshal(input, &items)
+	if err != nil {
+		return page, fmt.Errorf("unable to read %s: %w", s.path, err)
+	}
+
+	start := 0
+	if pageToken != "" {
+		start, err = strconv.Atoi(pageToken)
+		if err != nil || start < 0 || start > len(items) {
+			return page, fmt.Errorf("invalid page token %q", pageToken)
+		}
+	}
+	end := len(items)
+	if s.pageSize > 0 && start+s.pageSize < end {
+		end = start + s.pageSize
+		page.NextPageToken = strconv.Itoa(end)
+	}
+
+	// the etag covers the whole file, so any change to it changes every page
+	page.Etag = fmt.Sprintf("%x-%d", sha256.Sum256(input), start)
+	if etag != "" && etag == page.Etag {
+		return page, ErrNotModified
+	}
+
+	page.TotalResults = int64(len(items))
+	for _, item := range items[start:end] {
+		page.Channels = append(page.Channels, NewChannel(item.Id, item.Name, item.Description))
+	}
+
+	return page, nil
+}
@@ -0,0 +1,245 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package channel
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/api/youtube/v3"
+	"repo.joyrex.net/ejstacey/ysm/channel/channeltest"
+)
+
+// newFakeSource starts a fake youtube with subs and returns a source pointed
+// at it, counting how often it connects with reauth set.
+func newFakeSource(t *testing.T, subs []channeltest.Subscription) (*channeltest.FakeYoutube, *YoutubeSource, *int) {
+	t.Helper()
+
+	fake := channeltest.NewFakeYoutube(subs)
+	t.Cleanup(fake.Close)
+
+	var reauths int
+	src := NewYoutubeSourceConnect(func(reauth bool) (*youtube.Service, error) {
+		if reauth {
+			reauths++
+		}
+		return fake.Connect(reauth)
+	})
+
+	return fake, src, &reauths
+}
+
+// syncFrom loads the list from src and applies all of it, like a sync
+// without review does.
+func syncFrom(t *testing.T, channels *Channels, src SubscriptionSource) (SubscriptionList, Changeset) {
+	t.Helper()
+
+	list, err := LoadChannels(src, 0, io.Discard)
+	if err != nil {
+		t.Fatalf("LoadChannels: %v", err)
+	}
+
+	cs := channels.Diff(list)
+	cs.AcceptAll()
+	err = channels.ApplyChanges(cs)
+	if err != nil {
+		t.Fatalf("ApplyChanges: %v", err)
+	}
+
+	return list, cs
+}
+
+func countChanges(cs Changeset, changeType ChangeType) int {
+	var count int
+	for _, ch := range cs.Changes {
+		if ch.Type == changeType {
+			count++
+		}
+	}
+	return count
+}
+
+func TestYoutubeSourcePages(t *testing.T) {
+	channels := openTestDb(t)
+	fake, src, _ := newFakeSource(t, channeltest.Subscriptions(120))
+
+	list, cs := syncFrom(t, channels, src)
+
+	if !list.Complete() {
+		t.Errorf("list is incomplete: %s", list.Incomplete)
+	}
+	if len(list.Channels) != 120 || list.State.PageCount != 3 {
+		t.Errorf("got %d channels in %d pages, want 120 in 3", len(list.Channels), list.State.PageCount)
+	}
+	if fake.Requests() != 3 {
+		t.Errorf("made %d requests, want 3", fake.Requests())
+	}
+	if countChanges(cs, ChangeAdded) != 120 {
+		t.Errorf("added %d channels, want 120", countChanges(cs, ChangeAdded))
+	}
+	if _, ok := channels.ById()["UCfake000000000000000120"]; !ok {
+		t.Errorf("the last channel on the last page wasn't added")
+	}
+}
+
+func TestYoutubeSourceEtags(t *testing.T) {
+	channels := openTestDb(t)
+	subs := channeltest.Subscriptions(120)
+	fake, src, _ := newFakeSource(t, subs)
+
+	syncFrom(t, channels, src)
+
+	list, cs := syncFrom(t, channels, src)
+	if fake.NotModified() != 3 || list.State.UnchangedPages != 3 {
+		t.Errorf("%d pages were answered with a 304 and %d reused, want 3 of each", fake.NotModified(), list.State.UnchangedPages)
+	}
+	if len(list.Channels) != 120 || len(cs.Changes) != 0 {
+		t.Errorf("got %d channels and %d changes from the cached pages, want 120 and none", len(list.Channels), len(cs.Changes))
+	}
+
+	// only the last page changes
+	subs[110].Title = "renamed"
+	fake.SetSubscriptions(subs)
+
+	list, cs = syncFrom(t, channels, src)
+	if list.State.UnchangedPages != 2 {
+		t.Errorf("%d pages reused, want 2", list.State.UnchangedPages)
+	}
+	if len(cs.Changes) != 1 || cs.Changes[0].Type != ChangeRenamed || cs.Changes[0].New.Name() != "renamed" {
+		t.Errorf("changes = %v, want the one rename", cs.Changes)
+	}
+}
+
+func TestYoutubeSourceNotSavedUntilApplied(t *testing.T) {
+	channels := openTestDb(t)
+	fake, src, _ := newFakeSource(t, channeltest.Subscriptions(60))
+
+	_, err := LoadChannels(src, 0, io.Discard)
+	if err != nil {
+		t.Fatalf("LoadChannels: %v", err)
+	}
+	if !LastSync(src).IsZero() {
+		t.Errorf("LastSync is set before anything was applied")
+	}
+
+	// nothing was cached, so no etags are sent
+	syncFrom(t, channels, src)
+	if fake.NotModified() != 0 {
+		t.Errorf("%d pages were answered with a 304 after an unapplied load, want 0", fake.NotModified())
+	}
+	if LastSync(src).IsZero() {
+		t.Errorf("LastSync isn't set after applying")
+	}
+}
+
+func TestYoutubeSourceExpiredToken(t *testing.T) {
+	channels := openTestDb(t)
+	fake, src, reauths := newFakeSource(t, channeltest.Subscriptions(80))
+
+	syncFrom(t, channels, src)
+	if *reauths != 0 || fake.Unauthorized() != 0 {
+		t.Fatalf("reconnected %d times after %d 401s before the token expired", *reauths, fake.Unauthorized())
+	}
+
+	fake.ExpireToken()
+	list, _ := syncFrom(t, channels, src)
+
+	if fake.Unauthorized() != 1 || *reauths != 1 {
+		t.Errorf("got %d 401s and %d reconnects, want 1 of each", fake.Unauthorized(), *reauths)
+	}
+	if len(list.Channels) != 80 {
+		t.Errorf("got %d channels after reconnecting, want 80", len(list.Channels))
+	}
+
+	// a fresh source starts with the stale credential again
+	fresh := NewYoutubeSourceConnect(fake.Connect)
+	_, err := LoadChannels(fresh, 0, io.Discard)
+	if err != nil {
+		t.Errorf("LoadChannels with a stale credential: %v", err)
+	}
+}
+
+func TestYoutubeSourceTruncated(t *testing.T) {
+	channels := openTestDb(t)
+	subs := channeltest.Subscriptions(120)
+	fake, src, _ := newFakeSource(t, subs)
+
+	syncFrom(t, channels, src)
+
+	// youtube reporting all 120 but only listing the first page
+	fake.SetSubscriptions(subs[:50])
+	fake.SetTotalResults(120)
+
+	list, cs := syncFrom(t, channels, src)
+	if list.Complete() || cs.Complete {
+		t.Errorf("a list 70 short of its total is complete")
+	}
+	if cs.Incomplete == "" {
+		t.Errorf("no reason given for holding back removals")
+	}
+	if countChanges(cs, ChangeRemoved) != 0 {
+		t.Errorf("%d channels were removed from a truncated list", countChanges(cs, ChangeRemoved))
+	}
+	for _, chanInfo := range channels.ById() {
+		if chanInfo.Unsubscribed() {
+			t.Fatalf("%s was marked as unsubscribed", chanInfo.Name())
+		}
+	}
+}
+
+func TestYoutubeSourceHiddenChannels(t *testing.T) {
+	channels := openTestDb(t)
+	subs := channeltest.Subscriptions(120)
+	fake, src, _ := newFakeSource(t, subs)
+
+	syncFrom(t, channels, src)
+
+	// one unsubscribed, plus a few terminated channels youtube still counts
+	fake.SetSubscriptions(subs[1:])
+	fake.SetTotalResults(123)
+
+	list, cs := syncFrom(t, channels, src)
+	if !list.Complete() {
+		t.Errorf("a list a few short of its total is incomplete: %s", list.Incomplete)
+	}
+	if countChanges(cs, ChangeRemoved) != 1 || !channels.ById()[subs[0].ChannelId].Unsubscribed() {
+		t.Errorf("changes = %v, want %s removed", cs.Changes, subs[0].Title)
+	}
+}
+
+func TestFileSourcePages(t *testing.T) {
+	channels := openTestDb(t)
+	path := filepath.Join(t.TempDir(), "subscriptions.json")
+	subs := channeltest.Subscriptions(25)
+
+	err := channeltest.WriteSubscriptionsFile(path, subs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, _ := syncFrom(t, channels, NewFileSource(path, 10))
+	if !list.Complete() || len(list.Channels) != 25 || list.State.PageCount != 3 {
+		t.Errorf("got %d channels in %d pages (incomplete: %q), want 25 in 3", len(list.Channels), list.State.PageCount, list.Incomplete)
+	}
+
+	// dropping a channel from the file unsubscribes it
+	err = channeltest.WriteSubscriptionsFile(path, subs[:24])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, cs := syncFrom(t, channels, NewFileSource(path, 10))
+	if countChanges(cs, ChangeRemoved) != 1 || !channels.ById()[subs[24].ChannelId].Unsubscribed() {
+		t.Errorf("changes = %v, want %s removed", cs.Changes, subs[24].Title)
+	}
+}
@@ -0,0 +1,180 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package channel
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+// SyncState is what was recorded about the last sync from a source.
+type SyncState struct {
+	Source         string
+	LastSync       time.Time
+	ChannelCount   int
+	PageCount      int
+	UnchangedPages int
+}
+
+// syncPage is a cached page of the subscription list, along with the page
+// token it was requested with. The channels are kept as they were retrieved, so
+// when the source says a page hasn't changed since the etag, the page can be
+// reused as-is.
+type syncPage struct {
+	pageToken string
+	SubscriptionPage
+}
+
+// pageItem is how a channel on a cached page is stored in the db.
+type pageItem struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// NoDescription is set for sources that don't provide descriptions.
+	NoDescription bool `json:"noDescription,omitempty"`
+}
+
+func LoadSyncState(source string) (SyncState, error) {
+	var state = SyncState{Source: source}
+
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var selectSql = "select ifnull(lastSync, ''), ifnull(channelCount, 0), ifnull(pageCount, 0), ifnull(unchangedPages, 0) from sync_state where source = :source"
+
+	var lastSync string
+	err := utils.DbConn.QueryRowContext(ctx, selectSql, source).Scan(&lastSync, &state.ChannelCount, &state.PageCount, &state.UnchangedPages)
+	if errors.Is(err, sql.ErrNoRows) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+
+	if lastSync != "" {
+		state.LastSync, err = time.Parse(time.RFC3339, lastSync)
+	}
+
+	return state, err
+}
+
+// execer is what saving the sync state needs from either a *sql.DB or a
+// *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func (s SyncState) Save() error {
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.save(ctx, utils.DbConn)
+}
+
+func (s SyncState) save(ctx context.Context, db execer) error {
+	var upsertSql = `
+		insert into sync_state (source, lastSync, channelCount, pageCount, unchangedPages)
+		values (:source, :lastSync, :channelCount, :pageCount, :unchangedPages)
+		on conflict (source) do update set
+			lastSync = excluded.lastSync,
+			channelCount = excluded.channelCount,
+			pageCount = excluded.pageCount,
+			unchangedPages = excluded.unchangedPages
+	`
+	_, err := db.ExecContext(ctx, upsertSql, s.Source, s.LastSync.UTC().Format(time.RFC3339), s.ChannelCount, s.PageCount, s.UnchangedPages)
+
+	return err
+}
+
+// loadSyncPages returns the cached pages for a source, keyed by the page token
+// used to request them.
+func loadSyncPages(source string) (map[string]syncPage, error) {
+	var pages = make(map[string]syncPage)
+
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var selectSql = "select pageToken, etag, nextPageToken, totalResults, items from sync_pages where source = :source"
+
+	rows, err := utils.DbConn.QueryContext(ctx, selectSql, source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var page syncPage
+		var items string
+
+		err = rows.Scan(&page.pageToken, &page.Etag, &page.NextPageToken, &page.TotalResults, &items)
+		if err != nil {
+			return nil, err
+		}
+
+		var stored []pageItem
+		err = json.Unmarshal([]byte(items), &stored)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range stored {
+			channel := NewChannel(item.Id, item.Name, item.Description)
+			channel.descriptionUnknown = item.NoDescription
+			page.Channels = append(page.Channels, channel)
+		}
+
+		pages[page.pageToken] = page
+	}
+
+	return pages, rows.Err()
+}
+
+// saveSyncPages replaces the cached pages for a source, as part of tx.
+func saveSyncPages(ctx context.Context, tx *sql.Tx, source string, pages []syncPage) error {
+	_, err := tx.ExecContext(ctx, "delete from sync_pages where source = :source", source)
+	if err != nil {
+		return err
+	}
+
+	var insertSql = `
+		insert into sync_pages (source, pageToken, etag, nextPageToken, totalResults, items)
+		values (:source, :pageToken, :etag, :nextPageToken, :totalResults, :items)
+	`
+	for _, page := range pages {
+		var stored = make([]pageItem, 0, len(page.Channels))
+		for _, item := range page.Channels {
+			stored = append(stored, pageItem{Id: item.id, Name: item.name, Description: item.description, NoDescription: item.descriptionUnknown})
+		}
+		items, err := json.Marshal(stored)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, insertSql, source, page.pageToken, page.Etag, page.NextPageToken, page.TotalResults, string(items))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package channel
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// TakeoutSource reads the subscriptions.csv file from a Google Takeout export
+// of youtube data, for when going through the oauth flow isn't an option. The
+// file has "Channel Id", "Channel Url" and "Channel Title" columns, and no
+// descriptions, so the descriptions already in the db are left alone.
+type TakeoutSource struct {
+	path string
+}
+
+func NewTakeoutSource(path string) *TakeoutSource {
+	return &TakeoutSource{path: path}
+}
+
+func (s *TakeoutSource) Name() string { return "takeout" }
+
+func (s *TakeoutSource) Page(pageToken string, etag string) (SubscriptionPage, error) {
+	var page SubscriptionPage
+
+	if pageToken != "" {
+		return page, fmt.Errorf("invalid page token %q", pageToken)
+	}
+
+	input, err := os.ReadFile(s.path)
+	if err != nil {
+		return page, err
+	}
+
+	page.Etag = fmt.Sprintf("%x", sha256.Sum256(input))
+	if etag != "" && etag == page.Etag {
+		return page, ErrNotModified
+	}
+
+	page.Channels, err = readTakeoutCsv(bytes.NewReader(input))
+	if err != nil {
+		return page, fmt.Errorf("unable to read %s: %w", s.path, err)
+	}
+	page.TotalResults = int64(len(page.Channels))
+
+	return page, nil
+}
+
+func readTakeoutCsv(input io.Reader) ([]Channel, error) {
+	r := csv.NewReader(input)
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the columns are found by name, falling back to the order takeout writes
+	// them in when the header is in another language
+	var idCol, urlCol, titleCol = 0, 1, 2
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) {
+		case "channel id":
+			idCol = i
+		case "channel url":
+			urlCol = i
+		case "channel title":
+			titleCol = i
+		}
+	}
+
+	var channels []Channel
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var id, title string
+		if idCol < len(record) {
+			id = strings.TrimSpace(record[idCol])
+		}
+		if id == "" && urlCol < len(record) {
+			_, id, _ = strings.Cut(strings.TrimSpace(record[urlCol]), "/channel/")
+		}
+		if titleCol < len(record) {
+			title = strings.TrimSpace(record[titleCol])
+		}
+		if id == "" {
+			line, _ := r.FieldPos(0)
+			return nil, fmt.Errorf("line %d: no channel id", line)
+		}
+
+		channel := NewChannel(id, title, "")
+		channel.descriptionUnknown = true
+		channels = append(channels, channel)
+	}
+
+	return channels, nil
+}
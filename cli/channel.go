@@ -0,0 +1,243 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/query"
+)
+
+func runChannel(args []string) error {
+	if len(args) == 0 {
+		return newUsageError("missing channel subcommand")
+	}
+
+	switch args[0] {
+	case "list":
+		return runChannelList(args[1:])
+	case "tag":
+		return runChannelTag(args[1:], true)
+	case "untag":
+		return runChannelTag(args[1:], false)
+	case "note":
+		return runChannelNote(args[1:])
+	case "purge":
+		return runChannelPurge(args[1:])
+	}
+
+	return newUsageError("unknown channel subcommand %q", args[0])
+}
+
+func runChannelList(args []string) error {
+	fs := flag.NewFlagSet("channel list", flag.ContinueOnError)
+	untagged := fs.Bool("untagged", false, "only show channels without any tags")
+	unsubscribed := fs.Bool("unsubscribed", false, "show the channels that have been unsubscribed from instead")
+	tagName := fs.String("tag", "", "only show channels with this tag or any of its sub-tags")
+	queryText := fs.String("query", "", "only show channels matching this query, eg 'tag:music AND NOT notes:\"seen it\"'")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return newUsageError("channel list takes no arguments")
+	}
+
+	e, err := loadEnv(false)
+	if err != nil {
+		return err
+	}
+
+	var matchTags map[int64]bool
+	if *tagName != "" {
+		tagInfo, err := findTag(e.tags, *tagName)
+		if err != nil {
+			return err
+		}
+		matchTags = e.tags.WithDescendants(tagInfo.Id())
+	}
+
+	q, err := query.Parse(*queryText, e.tags)
+	if err != nil {
+		return newUsageError("bad query: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\tNAME\tTAGS\n")
+	for _, channelName := range slices.Sorted(maps.Keys(e.channels.ByName())) {
+		chanInfo := e.channels.ByName()[channelName]
+		if *untagged && len(chanInfo.Tags()) != 0 {
+			continue
+		}
+		if *unsubscribed != chanInfo.Unsubscribed() {
+			continue
+		}
+		if matchTags != nil && !slices.ContainsFunc(chanInfo.Tags(), func(id int64) bool { return matchTags[id] }) {
+			continue
+		}
+		if !q.Match(chanInfo) {
+			continue
+		}
+
+		var tagNames []string
+		for _, tagId := range chanInfo.Tags() {
+			// tags added by an auto-tagging rule get a * after them
+			if slices.Contains(chanInfo.RuleTags(), tagId) {
+				tagNames = append(tagNames, e.tags.ById()[tagId].Name()+"*")
+			} else {
+				tagNames = append(tagNames, e.tags.ById()[tagId].Name())
+			}
+		}
+		slices.Sort(tagNames)
+
+		fmt.Fprintf(w, "%s\t%s\t%s\n", chanInfo.Id(), chanInfo.Name(), strings.Join(tagNames, ", "))
+	}
+
+	return w.Flush()
+}
+
+func runChannelTag(args []string, add bool) error {
+	var name = "channel untag"
+	if add {
+		name = "channel tag"
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return newUsageError("%s takes a channel id and a tag name", name)
+	}
+
+	e, err := loadEnv(true)
+	if err != nil {
+		return err
+	}
+
+	chanInfo, err := findChannel(e.channels, positional[0])
+	if err != nil {
+		return err
+	}
+	tagInfo, err := findTag(e.tags, positional[1])
+	if err != nil {
+		return err
+	}
+
+	var tagIds []int64
+	if add {
+		if slices.Contains(chanInfo.Tags(), tagInfo.Id()) {
+			fmt.Printf("%s is already tagged with %s\n", chanInfo.Name(), tagInfo.Name())
+			return nil
+		}
+		tagIds = append(slices.Clone(chanInfo.Tags()), tagInfo.Id())
+	} else {
+		if !slices.Contains(chanInfo.Tags(), tagInfo.Id()) {
+			fmt.Printf("%s is not tagged with %s\n", chanInfo.Name(), tagInfo.Name())
+			return nil
+		}
+		for _, tagId := range chanInfo.Tags() {
+			if tagId != tagInfo.Id() {
+				tagIds = append(tagIds, tagId)
+			}
+		}
+	}
+
+	err = chanInfo.SetTags(tagIds)
+	if err != nil {
+		return fmt.Errorf("updating channel tags: %w", err)
+	}
+
+	if add {
+		fmt.Printf("Tagged %s with %s\n", chanInfo.Name(), tagInfo.Name())
+	} else {
+		fmt.Printf("Removed %s from %s\n", tagInfo.Name(), chanInfo.Name())
+	}
+
+	return nil
+}
+
+func runChannelNote(args []string) error {
+	fs := flag.NewFlagSet("channel note", flag.ContinueOnError)
+	clearNotes := fs.Bool("clear", false, "remove the notes from the channel")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return newUsageError("channel note needs a channel id")
+	}
+	if *clearNotes && len(positional) > 1 {
+		return newUsageError("-clear can't be used when giving a note")
+	}
+
+	// only take a backup if we're going to change something
+	e, err := loadEnv(*clearNotes || len(positional) > 1)
+	if err != nil {
+		return err
+	}
+
+	chanInfo, err := findChannel(e.channels, positional[0])
+	if err != nil {
+		return err
+	}
+
+	if !*clearNotes && len(positional) == 1 {
+		fmt.Println(chanInfo.Notes())
+		return nil
+	}
+
+	err = chanInfo.SetNotes(strings.Join(positional[1:], " "))
+	if err != nil {
+		return fmt.Errorf("updating channel notes: %w", err)
+	}
+
+	fmt.Printf("Updated notes for %s\n", chanInfo.Name())
+
+	return nil
+}
+
+func runChannelPurge(args []string) error {
+	fs := flag.NewFlagSet("channel purge", flag.ContinueOnError)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return newUsageError("channel purge takes no arguments")
+	}
+
+	e, err := loadEnv(true)
+	if err != nil {
+		return err
+	}
+
+	purged, err := e.channels.PurgeUnsubscribed()
+	for _, chanInfo := range purged {
+		fmt.Printf("Purged %s (unsubscribed %s)\n", chanInfo.Name(), chanInfo.UnsubscribedAt().Local().Format(time.DateOnly))
+	}
+	if err != nil {
+		return fmt.Errorf("purging unsubscribed channels: %w", err)
+	}
+	fmt.Printf("Purged %d unsubscribed channels\n", len(purged))
+
+	return nil
+}
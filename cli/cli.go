@@ -0,0 +1,265 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/tag"
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+const (
+	ExitOk    int = 0
+	ExitError int = 1
+	ExitUsage int = 2
+)
+
+// usageError is returned when the command line itself is wrong, so Run can
+// tell it apart from something failing while the command was running.
+type usageError struct {
+	message string
+}
+
+func (e usageError) Error() string { return e.message }
+
+func newUsageError(format string, a ...any) error {
+	return usageError{message: fmt.Sprintf(format, a...)}
+}
+
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{
+			name:        "sync",
+			usage:       "sync [-dry-run] [-interactive] [-status] [-file subscriptions.json]",
+			description: "refresh the channel list from youtube (or the configured source)",
+			run:         runSync,
+		},
+		{
+			name:        "enrich",
+			usage:       "enrich [-days days] [-all]",
+			description: "look up channels' avatars, subscriber and video counts, country, handle and creation date on youtube",
+			run:         runEnrich,
+		},
+		{
+			name:        "feeds",
+			usage:       "feeds check [-days days] [-all] | feeds quiet [-months months]",
+			description: "read channels' rss feeds for their latest upload, or list the ones that have gone quiet",
+			run:         runFeeds,
+		},
+		{
+			name:        "import",
+			usage:       "import -takeout subscriptions.csv | -opml feeds.opml | -json backup.json [-mode mode] [-dry-run] [-interactive]",
+			description: "sync from a google takeout export, add the youtube channels from an opml file or load a backup",
+			run:         runImport,
+		},
+		{
+			name:        "export",
+			usage:       "export -opml file [-tag tag,tag] | -json file",
+			description: "export channels and their rss feeds as opml, or back up everything as json",
+			run:         runExport,
+		},
+		{
+			name:        "generate",
+			usage:       "generate [-profile name] [-template file] [-output file] [-title title] [-hide tag,tag] [-query query] [-format html|json|csv|markdown|atom|site] | generate -all",
+			description: "generate the html (or json, csv, markdown, atom or a whole site) output of channels and tags",
+			run:         runGenerate,
+		},
+		{
+			name:        "tag",
+			usage:       "tag list | tag add <name> [-description text] [-fg hex] [-bg hex] [-parent tag] [-visibility visibility] | tag rm <name> | tag move <name> <parent> | tag move <name> -top | tag rename <name> <new name> | tag merge <name> <into> | tag split <name> <new name> <channel>... | tag unalias <old name> | tag visibility <name> public|unlisted|private",
+			description: "list, add, remove, move, rename, merge or split tags, or change whether the generator shows them",
+			run:         runTag,
+		},
+		{
+			name:        "channel",
+			usage:       "channel list [-untagged] [-unsubscribed] [-tag tag] [-query query] | channel tag <id> <tag> | channel untag <id> <tag> | channel note <id> [-clear] [note] | channel purge",
+			description: "list channels, change their tags, show/set their notes or purge unsubscribed ones",
+			run:         runChannel,
+		},
+		{
+			name:        "rule",
+			usage:       "rule list | rule add <tag> [-field name|description|any] [-regex] <pattern> | rule rm <id> | rule preview [id...] | rule apply [id...] [-dry-run]",
+			description: "list, add or remove auto-tagging rules, or preview and apply them",
+			run:         runRule,
+		},
+		{
+			name:        "search",
+			usage:       "search [-n count] [-raw] <words>...",
+			description: "find channels by the words in their names, descriptions and notes",
+			run:         runSearch,
+		},
+		{
+			name:        "history",
+			usage:       "history [list] [-n count] | history show <id> | history undo | history redo | history revert <id> [-force]",
+			description: "list past changes to tags, notes and links, or undo, redo or revert them",
+			run:         runHistory,
+		},
+	}
+}
+
+// Run executes the subcommand named in args and returns the exit code the
+// program should finish with.
+func Run(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage()
+		return ExitOk
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		err := cmd.run(args[1:])
+		if err == nil {
+			return ExitOk
+		}
+
+		fmt.Fprintf(os.Stderr, "ysm %s: %v\n", cmd.name, err)
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			fmt.Fprintf(os.Stderr, "usage: ysm %s\n", cmd.usage)
+			return ExitUsage
+		}
+		return ExitError
+	}
+
+	fmt.Fprintf(os.Stderr, "ysm: unknown command %q\n\n", args[0])
+	printUsage()
+	return ExitUsage
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "usage: ysm [--install] [command [arguments]]\n\n")
+	fmt.Fprintf(os.Stderr, "Running ysm without a command starts the TUI. The commands are:\n\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.description)
+		fmt.Fprintf(os.Stderr, "  %-10s   ysm %s\n", "", cmd.usage)
+	}
+}
+
+type env struct {
+	settings utils.Settings
+	channels channel.Channels
+	tags     tag.Tags
+}
+
+// loadEnv does the same start up as the TUI: check the installation, read the
+// settings, open the db and load the existing channels and tags. Commands that
+// change the db pass backup so a copy is taken before they touch it. Anything
+// going wrong is returned rather than exiting, so Run picks the exit code.
+func loadEnv(backup bool) (env, error) {
+	var e env
+
+	err := utils.CheckInstallation()
+	if err != nil {
+		return e, err
+	}
+
+	e.settings, err = utils.ReadSettings()
+	if err != nil {
+		return e, err
+	}
+
+	if backup {
+		err = utils.BackupDb(e.settings.DbFile, e.settings.BackupCopies)
+		if err != nil {
+			return e, err
+		}
+	}
+
+	err = utils.OpenDb(e.settings.DbFile, e.settings.BackupCopies)
+	if err != nil {
+		return e, err
+	}
+
+	err = e.channels.Load()
+	if err != nil {
+		return e, fmt.Errorf("loading existing channels from db: %w", err)
+	}
+	err = e.tags.Load()
+	if err != nil {
+		return e, fmt.Errorf("loading existing tags from db: %w", err)
+	}
+
+	return e, nil
+}
+
+// findTag looks a tag up by its name, ignoring case if there's no exact
+// match, or by a name it used to have.
+func findTag(tags tag.Tags, name string) (tag.Tag, error) {
+	if tagInfo, ok := tags.Find(name); ok {
+		return tagInfo, nil
+	}
+
+	return tag.Tag{}, fmt.Errorf("no tag named %q", name)
+}
+
+// findChannel looks a channel up by its id, falling back to its name.
+func findChannel(channels channel.Channels, idOrName string) (channel.Channel, error) {
+	if chanInfo, ok := channels.ById()[idOrName]; ok {
+		return chanInfo, nil
+	}
+
+	if chanInfo, ok := channels.ByName()[idOrName]; ok {
+		return chanInfo, nil
+	}
+
+	return channel.Channel{}, fmt.Errorf("no channel with id or name %q", idOrName)
+}
+
+// parseFlags parses the flags in args, which unlike flag.Parse are allowed to
+// come after the positional arguments. Everything after a "--" is positional.
+// It returns the positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+
+	var rest []string
+	if i := slices.Index(args, "--"); i != -1 {
+		rest = args[i+1:]
+		args = args[:i]
+	}
+
+	var positional []string
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, newUsageError("%v", err)
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	return append(positional, rest...), nil
+}
@@ -0,0 +1,73 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/channel"
+)
+
+func runEnrich(args []string) error {
+	fs := flag.NewFlagSet("enrich", flag.ContinueOnError)
+	all := fs.Bool("all", false, "look up every subscribed channel, even ones looked up recently")
+	days := fs.Int("days", 30, "look up the channels that haven't been looked up in this many days")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return newUsageError("enrich takes no arguments")
+	}
+	if *days < 0 {
+		return newUsageError("-days can't be negative")
+	}
+
+	e, err := loadEnv(true)
+	if err != nil {
+		return err
+	}
+
+	var maxAge time.Duration
+	if !*all {
+		maxAge = time.Duration(*days) * 24 * time.Hour
+	}
+	channels := e.channels.NeedEnriching(maxAge)
+	if len(channels) == 0 {
+		// with no age limit, that's only when there's nothing subscribed
+		if maxAge == 0 {
+			fmt.Println("There are no subscribed channels to look up")
+		} else {
+			fmt.Println("Every channel has been looked up in the last", *days, "days")
+		}
+		return nil
+	}
+
+	// this always asks youtube, whatever the subscription source is
+	fmt.Printf("Looking up %d channels on youtube.\n\n", len(channels))
+	found, err := e.channels.Enrich(channel.NewYoutubeSource(), channels, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated the details of %d channels", found)
+	if missing := len(channels) - found; missing > 0 {
+		fmt.Printf(" (%d weren't found on youtube)", missing)
+	}
+	fmt.Printf("\n")
+
+	return nil
+}
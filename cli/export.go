@@ -0,0 +1,96 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"repo.joyrex.net/ejstacey/ysm/backup"
+	"repo.joyrex.net/ejstacey/ysm/opml"
+)
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	opmlFile := fs.String("opml", "", "write the channels as opml with their rss feeds, to a file or - for stdout")
+	jsonFile := fs.String("json", "", "write a backup of the channels, notes, tags and links, to a file or - for stdout")
+	tagNames := fs.String("tag", "", "comma separated tags to export with -opml, default all channels")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return newUsageError("export takes no arguments")
+	}
+	if (*opmlFile == "") == (*jsonFile == "") {
+		return newUsageError("the file to export to must be given with one of -opml or -json")
+	}
+	if *jsonFile != "" && *tagNames != "" {
+		return newUsageError("-tag only works with -opml, backups always have everything")
+	}
+
+	e, err := loadEnv(false)
+	if err != nil {
+		return err
+	}
+
+	if *jsonFile != "" {
+		return writeOutput(*jsonFile, backup.Export)
+	}
+
+	var onlyTags = make(map[int64]bool)
+	for _, name := range strings.Split(*tagNames, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		tagInfo, err := findTag(e.tags, name)
+		if err != nil {
+			return err
+		}
+		onlyTags[tagInfo.Id()] = true
+	}
+
+	return writeOutput(*opmlFile, func(w io.Writer) error {
+		return opml.Export(w, e.channels, e.tags, onlyTags, e.settings.Generator.Title)
+	})
+}
+
+// writeOutput calls write with the named file, or stdout if the name is "-".
+func writeOutput(path string, write func(w io.Writer) error) (err error) {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+
+	fo, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	// close fo on exit and check for its returned error
+	defer func() {
+		closeErr := fo.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	err = write(fo)
+	if err == nil {
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+	}
+	return err
+}
@@ -0,0 +1,150 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/feed"
+)
+
+func runFeeds(args []string) error {
+	if len(args) == 0 {
+		return newUsageError("missing feeds subcommand")
+	}
+
+	switch args[0] {
+	case "check":
+		return runFeedsCheck(args[1:])
+	case "quiet":
+		return runFeedsQuiet(args[1:])
+	}
+
+	return newUsageError("unknown feeds subcommand %q", args[0])
+}
+
+func runFeedsCheck(args []string) error {
+	fs := flag.NewFlagSet("feeds check", flag.ContinueOnError)
+	all := fs.Bool("all", false, "check every subscribed channel, even ones checked recently")
+	days := fs.Int("days", 1, "check the channels that haven't been checked in this many days")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return newUsageError("feeds check takes no arguments")
+	}
+	if *days < 0 {
+		return newUsageError("-days can't be negative")
+	}
+
+	e, err := loadEnv(true)
+	if err != nil {
+		return err
+	}
+
+	var maxAge time.Duration
+	if !*all {
+		maxAge = time.Duration(*days) * 24 * time.Hour
+	}
+	channels := e.channels.NeedFeedCheck(maxAge)
+	if len(channels) == 0 {
+		// with no age limit, that's only when there's nothing subscribed
+		if maxAge == 0 {
+			fmt.Println("There are no subscribed channels to check the feeds of")
+		} else {
+			fmt.Println("Every channel's feed has been checked in the last", *days, "days")
+		}
+		return nil
+	}
+
+	fmt.Printf("Checking the feeds of %d channels.\n\n", len(channels))
+	failed, err := e.channels.CheckFeeds(feed.NewClient(feed.DefaultBaseUrl), channels, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(failed))
+	for id := range failed {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	fmt.Printf("\n")
+	for _, id := range ids {
+		fmt.Printf("%s (%s): %v\n", e.channels.ById()[id].Name(), id, failed[id])
+	}
+
+	return nil
+}
+
+func runFeedsQuiet(args []string) error {
+	fs := flag.NewFlagSet("feeds quiet", flag.ContinueOnError)
+	months := fs.Int("months", 6, "list the channels that haven't uploaded anything in this many months")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return newUsageError("feeds quiet takes no arguments")
+	}
+	if *months <= 0 {
+		return newUsageError("-months has to be more than 0")
+	}
+
+	e, err := loadEnv(false)
+	if err != nil {
+		return err
+	}
+
+	since := channel.QuietSince(*months)
+	var quiet []channel.Channel
+	for _, chanInfo := range e.channels.ById() {
+		if !chanInfo.Unsubscribed() && chanInfo.Quiet(since) {
+			quiet = append(quiet, chanInfo)
+		}
+	}
+
+	// the longest quiet first, channels that never uploaded anything before
+	// all of them
+	slices.SortFunc(quiet, func(a, b channel.Channel) int {
+		if c := a.LatestVideo().PublishedAt.Compare(b.LatestVideo().PublishedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(strings.ToLower(a.Name()), strings.ToLower(b.Name()))
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\tNAME\tLAST UPLOAD\tTITLE\n")
+	for _, chanInfo := range quiet {
+		latest := chanInfo.LatestVideo()
+		uploaded := "never"
+		if latest.Id != "" {
+			uploaded = latest.PublishedAt.Local().Format(time.DateOnly)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", chanInfo.Id(), chanInfo.Name(), uploaded, latest.Title)
+	}
+
+	return w.Flush()
+}
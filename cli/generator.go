@@ -0,0 +1,123 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"repo.joyrex.net/ejstacey/ysm/generator"
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+func runGenerate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	profileName := fs.String("profile", "", "the profile in settings.json to generate (default: the \"Generator\" section itself)")
+	all := fs.Bool("all", false, "generate every profile in settings.json")
+	templateFile := fs.String("template", "", "template file to use (default from the profile)")
+	outputFile := fs.String("output", "", "file to write the output to (default from the profile)")
+	title := fs.String("title", "", "title for the page (default from the profile)")
+	hide := fs.String("hide", "", "comma separated list of tags to leave out, on top of the private ones (default from the profile)")
+	queryText := fs.String("query", "", "only include channels matching this query (default from the profile)")
+	format := fs.String("format", "", "html, json, csv, markdown, atom or site (default from the profile, or the output file's extension)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return newUsageError("generate takes no arguments")
+	}
+	if *all && fs.NFlag() > 1 {
+		return newUsageError("-all can't be used with other flags")
+	}
+
+	e, err := loadEnv(false)
+	if err != nil {
+		return err
+	}
+
+	if *all {
+		profiles := e.settings.Generator.Profiles
+		if len(profiles) == 0 {
+			return newUsageError("there are no profiles in settings.json")
+		}
+
+		outputs := make(map[string]string)
+		for _, p := range profiles {
+			p, _ = e.settings.Generator.Profile(p.Name)
+			if other, ok := outputs[p.OutputFile]; ok {
+				return fmt.Errorf("profiles %q and %q both write to %s", other, p.Name, p.OutputFile)
+			}
+			outputs[p.OutputFile] = p.Name
+		}
+
+		for _, p := range profiles {
+			p, _ = e.settings.Generator.Profile(p.Name)
+			err = generateProfile(e, p)
+			if err != nil {
+				return fmt.Errorf("profile %q: %w", p.Name, err)
+			}
+		}
+
+		return nil
+	}
+
+	p, ok := e.settings.Generator.Profile(*profileName)
+	if !ok {
+		return newUsageError("no profile named %q in settings.json", *profileName)
+	}
+	if *templateFile != "" {
+		p.TemplateFile = *templateFile
+	}
+	if *outputFile != "" {
+		p.OutputFile = *outputFile
+	}
+	if *title != "" {
+		p.Title = *title
+	}
+	if *queryText != "" {
+		p.Query = *queryText
+	}
+	if *format != "" {
+		p.Format = *format
+	}
+	if *hide != "" {
+		p.HideTags = strings.Split(*hide, ",")
+	}
+
+	return generateProfile(e, p)
+}
+
+func generateProfile(e env, p utils.GeneratorProfile) error {
+	gen, hiddenTags, err := generator.FromProfile(p, e.tags)
+	if err != nil {
+		return err
+	}
+
+	gen.LoadEntries(e.channels, e.tags, hiddenTags)
+
+	err = gen.LoadTemplateFile()
+	if err != nil {
+		return fmt.Errorf("unable to load template %s: %w", gen.TemplateFile, err)
+	}
+
+	err = gen.GenerateOutputFile()
+	if err != nil {
+		return fmt.Errorf("unable to generate %s: %w", gen.OutputFile, err)
+	}
+
+	fmt.Printf("Created/updated: %s\n", gen.OutputFile)
+
+	return nil
+}
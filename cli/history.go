@@ -0,0 +1,188 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"repo.joyrex.net/ejstacey/ysm/history"
+)
+
+func runHistory(args []string) error {
+	if len(args) == 0 {
+		return runHistoryList(args)
+	}
+
+	switch args[0] {
+	case "list":
+		return runHistoryList(args[1:])
+	case "show":
+		return runHistoryShow(args[1:])
+	case "undo":
+		return runHistoryStep(args[1:], true)
+	case "redo":
+		return runHistoryStep(args[1:], false)
+	case "revert":
+		return runHistoryRevert(args[1:])
+	}
+
+	// plain "ysm history -n 5" lists too
+	if args[0] != "" && args[0][0] == '-' {
+		return runHistoryList(args)
+	}
+
+	return newUsageError("unknown history subcommand %q", args[0])
+}
+
+func runHistoryList(args []string) error {
+	fs := flag.NewFlagSet("history list", flag.ContinueOnError)
+	limit := fs.Int("n", 20, "how many entries to show")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return newUsageError("history list takes no arguments")
+	}
+	if *limit < 1 {
+		return newUsageError("-n has to be at least 1")
+	}
+
+	_, err = loadEnv(false)
+	if err != nil {
+		return err
+	}
+
+	entries, err := history.List(*limit)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\tWHEN\tCHANGES\tDESCRIPTION\n")
+	for _, entry := range entries {
+		description := entry.Description
+		if entry.Undone {
+			description += " (undone)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\n", entry.Id, entry.At.Local().Format("2006-01-02 15:04:05"), len(entry.Changes), description)
+	}
+
+	return w.Flush()
+}
+
+func runHistoryShow(args []string) error {
+	fs := flag.NewFlagSet("history show", flag.ContinueOnError)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return newUsageError("history show takes exactly one history id")
+	}
+	id, err := strconv.ParseInt(positional[0], 10, 64)
+	if err != nil {
+		return newUsageError("%q is not a history id", positional[0])
+	}
+
+	_, err = loadEnv(false)
+	if err != nil {
+		return err
+	}
+
+	entry, err := history.Get(id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d: %s\n", entry.Id, entry.Description)
+	fmt.Printf("at %s", entry.At.Local().Format("2006-01-02 15:04:05"))
+	if entry.Undone {
+		fmt.Printf(", undone")
+	}
+	fmt.Printf("\n\n")
+	for _, change := range entry.Changes {
+		fmt.Printf("  %s\n", change)
+	}
+
+	return nil
+}
+
+func runHistoryStep(args []string, undo bool) error {
+	fs := flag.NewFlagSet("history undo", flag.ContinueOnError)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return newUsageError("history undo and redo take no arguments")
+	}
+
+	_, err = loadEnv(true)
+	if err != nil {
+		return err
+	}
+
+	if undo {
+		entry, err := history.Undo()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Undid %d: %s\n", entry.Id, entry.Description)
+		return nil
+	}
+
+	entry, err := history.Redo()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Redid %d: %s\n", entry.Id, entry.Description)
+	return nil
+}
+
+func runHistoryRevert(args []string) error {
+	fs := flag.NewFlagSet("history revert", flag.ContinueOnError)
+	force := fs.Bool("force", false, "revert even if the rows have been changed again since")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return newUsageError("history revert takes exactly one history id")
+	}
+	id, err := strconv.ParseInt(positional[0], 10, 64)
+	if err != nil {
+		return newUsageError("%q is not a history id", positional[0])
+	}
+
+	_, err = loadEnv(true)
+	if err != nil {
+		return err
+	}
+
+	entry, err := history.Revert(id, *force)
+	if errors.Is(err, history.ErrConflict) {
+		return fmt.Errorf("%w, use -force to revert it anyway", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Reverted %d: %s\n", entry.Id, entry.Description)
+	return nil
+}
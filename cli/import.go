@@ -0,0 +1,131 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	"repo.joyrex.net/ejstacey/ysm/backup"
+	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/opml"
+)
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	takeout := fs.String("takeout", "", "import the subscriptions.csv from a google takeout export")
+	opmlFile := fs.String("opml", "", "add the youtube channels in an opml file from a feed reader")
+	jsonFile := fs.String("json", "", "load a backup made with export -json")
+	mode := fs.String("mode", string(backup.MergePreferLocal), "how to load a backup: replace, merge-prefer-local or merge-prefer-file")
+	dryRun := fs.Bool("dry-run", false, "only show what would change, don't apply anything")
+	interactive := fs.Bool("interactive", false, "ask before applying the changes, either all at once or one by one")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return newUsageError("import takes no arguments")
+	}
+	var given = 0
+	for _, file := range []string{*takeout, *opmlFile, *jsonFile} {
+		if file != "" {
+			given++
+		}
+	}
+	if given != 1 {
+		return newUsageError("the file to import must be given with one of -takeout, -opml or -json")
+	}
+	if *dryRun && *interactive {
+		return newUsageError("-dry-run and -interactive can't be used together")
+	}
+	if *jsonFile != "" {
+		if *interactive {
+			return newUsageError("-interactive doesn't work with -json")
+		}
+		if !slices.Contains(backup.Modes, backup.Mode(*mode)) {
+			return newUsageError("unknown mode %q", *mode)
+		}
+		return importBackup(*jsonFile, backup.Mode(*mode), *dryRun)
+	}
+
+	e, err := loadEnv(!*dryRun)
+	if err != nil {
+		return err
+	}
+
+	var cs channel.Changeset
+	if *takeout != "" {
+		src := channel.NewTakeoutSource(*takeout)
+
+		fmt.Printf("Loading the subscription list from %s.\n\n", *takeout)
+		list, err := channel.LoadChannels(src, e.settings.MaxSubscriptions, os.Stdout)
+		if err != nil {
+			return err
+		}
+
+		cs = e.channels.Diff(list)
+	} else {
+		newChannels, skipped, err := opml.ReadFile(*opmlFile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Found %d youtube channels in %s.\n", len(newChannels), *opmlFile)
+		if skipped != 0 {
+			fmt.Printf("Skipped %d feeds that aren't youtube channels or don't have a channel id.\n", skipped)
+		}
+
+		// feed readers don't know about unsubscribing, so only add channels.
+		// Nothing is ever removed, so there's no truncation to warn about.
+		cs = e.channels.Diff(channel.SubscriptionList{Channels: newChannels})
+		cs.Keep(channel.ChangeAdded)
+		cs.Complete = true
+		cs.Incomplete = ""
+	}
+
+	return reviewAndApply(&e.channels, e.tags, cs, *dryRun, *interactive)
+}
+
+func importBackup(path string, mode backup.Mode, dryRun bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	doc, err := backup.Read(f)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	_, err = loadEnv(!dryRun)
+	if err != nil {
+		return err
+	}
+
+	summary, err := backup.Import(doc, mode, dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, clash := range summary.NameClashes {
+		fmt.Printf("%s\n", clash)
+	}
+	if dryRun {
+		fmt.Printf("Would have loaded %s (%s): %s.\n", path, mode, summary)
+	} else {
+		fmt.Printf("Loaded %s (%s): %s.\n", path, mode, summary)
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"repo.joyrex.net/ejstacey/ysm/rule"
+	"repo.joyrex.net/ejstacey/ysm/tag"
+)
+
+func runRule(args []string) error {
+	if len(args) == 0 {
+		return newUsageError("missing rule subcommand")
+	}
+
+	switch args[0] {
+	case "list":
+		return runRuleList(args[1:])
+	case "add":
+		return runRuleAdd(args[1:])
+	case "rm":
+		return runRuleRm(args[1:])
+	case "preview":
+		return runRuleApply(args[1:], true)
+	case "apply":
+		return runRuleApply(args[1:], false)
+	}
+
+	return newUsageError("unknown rule subcommand %q", args[0])
+}
+
+func runRuleList(args []string) error {
+	fs := flag.NewFlagSet("rule list", flag.ContinueOnError)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return newUsageError("rule list takes no arguments")
+	}
+
+	e, err := loadEnv(false)
+	if err != nil {
+		return err
+	}
+
+	var rules rule.Rules
+	err = rules.Load()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\tTAG\tFIELD\tTYPE\tPATTERN\n")
+	for _, r := range rules.All() {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", r.Id(), e.tags.Path(r.TagId()), r.Field(), r.MatchType(), r.Pattern())
+	}
+
+	return w.Flush()
+}
+
+func runRuleAdd(args []string) error {
+	fs := flag.NewFlagSet("rule add", flag.ContinueOnError)
+	field := fs.String("field", "any", "what to match against: name, description or any")
+	regex := fs.Bool("regex", false, "the pattern is a regular expression rather than a keyword")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return newUsageError("rule add takes a tag name and a pattern")
+	}
+
+	var matchType = rule.MatchKeyword
+	if *regex {
+		matchType = rule.MatchRegex
+	}
+
+	e, err := loadEnv(true)
+	if err != nil {
+		return err
+	}
+
+	tagInfo, err := findTag(e.tags, positional[0])
+	if err != nil {
+		return err
+	}
+
+	r, err := rule.New(tagInfo.Id(), rule.Field(*field), matchType, positional[1])
+	if err != nil {
+		return newUsageError("%v", err)
+	}
+
+	err = r.Save()
+	if err != nil {
+		return fmt.Errorf("adding rule: %w", err)
+	}
+
+	fmt.Printf("Added rule %d: %s => %s\n", r.Id(), r, e.tags.Path(tagInfo.Id()))
+	fmt.Printf("Run 'ysm rule preview %d' to see which channels it would tag.\n", r.Id())
+
+	return nil
+}
+
+func runRuleRm(args []string) error {
+	fs := flag.NewFlagSet("rule rm", flag.ContinueOnError)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return newUsageError("rule rm takes exactly one rule id")
+	}
+
+	_, err = loadEnv(true)
+	if err != nil {
+		return err
+	}
+
+	r, err := findRule(positional[0])
+	if err != nil {
+		return err
+	}
+
+	err = r.Delete()
+	if err != nil {
+		return fmt.Errorf("deleting rule: %w", err)
+	}
+
+	fmt.Printf("Removed rule %d. The tags it already added have been kept.\n", r.Id())
+
+	return nil
+}
+
+// runRuleApply shows the tags the rules would add and, unless preview is
+// set, adds them. Either can be limited to some rules by giving their ids.
+func runRuleApply(args []string, preview bool) error {
+	var name = "rule apply"
+	if preview {
+		name = "rule preview"
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only show what would be tagged, the same as rule preview")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		preview = true
+	}
+
+	e, err := loadEnv(!preview)
+	if err != nil {
+		return err
+	}
+
+	var rules rule.Rules
+	err = rules.Load()
+	if err != nil {
+		return err
+	}
+
+	var ruleIds []int64
+	for _, arg := range positional {
+		r, err := findRule(arg)
+		if err != nil {
+			return err
+		}
+		ruleIds = append(ruleIds, r.Id())
+	}
+
+	matches, err := rules.Preview(e.channels, ruleIds...)
+	if err != nil {
+		return err
+	}
+
+	printMatches(matches, e.tags)
+	if len(matches) == 0 {
+		fmt.Printf("No channels to tag.\n")
+		return nil
+	}
+	if preview {
+		fmt.Printf("%d tags would be added.\n", len(matches))
+		return nil
+	}
+
+	err = rule.Apply(matches)
+	if err != nil {
+		return fmt.Errorf("applying rules: %w", err)
+	}
+	fmt.Printf("Added %d tags.\n", len(matches))
+
+	return nil
+}
+
+func printMatches(matches []rule.Match, tags tag.Tags) {
+	for _, m := range matches {
+		var ids []string
+		for _, id := range m.RuleIds {
+			ids = append(ids, strconv.FormatInt(id, 10))
+		}
+		fmt.Printf("+ %s: %s (rule %s)\n", m.Channel.Name(), tags.Path(m.TagId), strings.Join(ids, ", "))
+	}
+}
+
+// findRule looks a rule up by its id.
+func findRule(id string) (rule.Rule, error) {
+	ruleId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return rule.Rule{}, newUsageError("%q is not a rule id", id)
+	}
+
+	var rules rule.Rules
+	err = rules.Load()
+	if err != nil {
+		return rule.Rule{}, err
+	}
+
+	r, ok := rules.ById(ruleId)
+	if !ok {
+		return rule.Rule{}, fmt.Errorf("no rule with id %d", ruleId)
+	}
+
+	return r, nil
+}
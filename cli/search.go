@@ -0,0 +1,73 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/x/term"
+	"repo.joyrex.net/ejstacey/ysm/channel"
+)
+
+func runSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	limit := fs.Int("n", 20, "how many channels to show at most")
+	raw := fs.Bool("raw", false, "use the words as an sqlite fts5 query, with AND, OR, NOT, \"phrases\" and prefix*")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return newUsageError("search needs something to search for")
+	}
+	if *limit < 1 {
+		return newUsageError("-n has to be at least 1")
+	}
+
+	e, err := loadEnv(false)
+	if err != nil {
+		return err
+	}
+
+	// matches are bold on a terminal, and left as they are otherwise
+	var start, end string
+	if term.IsTerminal(os.Stdout.Fd()) {
+		start, end = "\x1b[1m", "\x1b[0m"
+	}
+
+	results, err := channel.Search(strings.Join(positional, " "), *raw, start, end, *limit)
+	if err != nil {
+		return fmt.Errorf("searching: %w", err)
+	}
+	if len(results) == 0 {
+		fmt.Println("No channels found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\tNAME\tMATCH\n")
+	for _, result := range results {
+		chanInfo := e.channels.ById()[result.ChannelId]
+		name := chanInfo.Name()
+		if chanInfo.Unsubscribed() {
+			name += " (unsubscribed)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", result.ChannelId, name, strings.Join(strings.Fields(result.Snippet), " "))
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,205 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/rule"
+	"repo.joyrex.net/ejstacey/ysm/tag"
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+var errSyncAborted = errors.New("sync aborted, no changes were applied")
+
+func runSync(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only show what would change, don't apply anything")
+	interactive := fs.Bool("interactive", false, "ask before applying the changes, either all at once or one by one")
+	status := fs.Bool("status", false, "show when the last sync happened, don't sync")
+	file := fs.String("file", "", "read the subscription list from this json file instead of the configured source")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return newUsageError("sync takes no arguments")
+	}
+	if *dryRun && *interactive {
+		return newUsageError("-dry-run and -interactive can't be used together")
+	}
+
+	if *status {
+		if *dryRun || *interactive {
+			return newUsageError("-status can't be used with -dry-run or -interactive")
+		}
+		e, err := loadEnv(false)
+		if err != nil {
+			return err
+		}
+		src, err := syncSource(e.settings, *file)
+		if err != nil {
+			return err
+		}
+		return printSyncStatus(src)
+	}
+
+	e, err := loadEnv(!*dryRun)
+	if err != nil {
+		return err
+	}
+
+	src, err := syncSource(e.settings, *file)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Loading a fresh list from %s.\n\n", src.Name())
+	list, err := channel.LoadChannels(src, e.settings.MaxSubscriptions, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	cs := e.channels.Diff(list)
+	return reviewAndApply(&e.channels, e.tags, cs, *dryRun, *interactive)
+}
+
+// reviewAndApply prints the changeset and applies it to the db, after asking
+// which changes to accept if interactive is set. The auto-tagging rules are
+// run afterwards. Applying it also records the sync, so that's skipped for
+// a dry run or when the review is abandoned.
+func reviewAndApply(channels *channel.Channels, tags tag.Tags, cs channel.Changeset, dryRun bool, interactive bool) error {
+	for _, ch := range cs.Changes {
+		fmt.Printf("%s\n", ch)
+	}
+	if !cs.Complete {
+		fmt.Printf("The retrieved subscription list looks incomplete (%s), so no channels will be marked as unsubscribed.\n", cs.Incomplete)
+	}
+	if len(cs.Changes) == 0 {
+		fmt.Printf("No changes.\n")
+	} else {
+		fmt.Printf("%d changes.\n", len(cs.Changes))
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	if len(cs.Changes) == 0 {
+		err := channels.ApplyChanges(cs)
+		if err != nil {
+			return fmt.Errorf("recording the sync: %w", err)
+		}
+		return nil
+	}
+
+	if interactive {
+		err := promptChanges(&cs)
+		if err != nil {
+			return err
+		}
+	} else {
+		cs.AcceptAll()
+	}
+
+	err := channels.ApplyChanges(cs)
+	if err != nil {
+		return fmt.Errorf("applying changes: %w", err)
+	}
+	fmt.Printf("Applied %d of %d changes.\n", len(cs.Accepted()), len(cs.Changes))
+
+	matches, err := rule.ApplyAll()
+	if err != nil {
+		return fmt.Errorf("applying auto-tagging rules: %w", err)
+	}
+	printMatches(matches, tags)
+	if len(matches) > 0 {
+		fmt.Printf("Auto-tagging rules added %d tags.\n", len(matches))
+	}
+
+	return nil
+}
+
+func promptChanges(cs *channel.Changeset) error {
+	reader := bufio.NewReader(os.Stdin)
+
+	answer, err := prompt(reader, "Apply changes? [a]ll, [p]er item, [q]uit: ")
+	if err != nil {
+		return err
+	}
+	switch answer {
+	case "a", "all":
+		cs.AcceptAll()
+		return nil
+	case "p", "per item":
+	default:
+		return errSyncAborted
+	}
+
+	for i := range cs.Changes {
+		answer, err := prompt(reader, fmt.Sprintf("%s - apply? [y]es, [n]o, [q]uit: ", cs.Changes[i]))
+		if err != nil {
+			return err
+		}
+		switch answer {
+		case "y", "yes":
+			cs.Changes[i].Accepted = true
+		case "n", "no":
+		default:
+			return errSyncAborted
+		}
+	}
+
+	return nil
+}
+
+func prompt(reader *bufio.Reader, question string) (string, error) {
+	fmt.Print(question)
+	answer, err := reader.ReadString('\n')
+	if err != nil && answer == "" {
+		return "", err
+	}
+	return strings.ToLower(strings.TrimSpace(answer)), nil
+}
+
+// syncSource returns the source set up in the settings, or a file source if
+// file was given on the command line.
+func syncSource(settings utils.Settings, file string) (channel.SubscriptionSource, error) {
+	if file != "" {
+		return channel.NewFileSource(file, 0), nil
+	}
+	return channel.NewSource(settings)
+}
+
+func printSyncStatus(src channel.SubscriptionSource) error {
+	state, err := channel.LoadSyncState(src.Name())
+	if err != nil {
+		return err
+	}
+
+	if state.LastSync.IsZero() {
+		fmt.Printf("Never synced from %s.\n", state.Source)
+		return nil
+	}
+
+	fmt.Printf("Last synced from %s at %s.\n", state.Source, state.LastSync.Local().Format(time.DateTime))
+	fmt.Printf("%d channels in %d pages, %d of them unchanged since the sync before.\n", state.ChannelCount, state.PageCount, state.UnchangedPages)
+	return nil
+}
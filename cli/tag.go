@@ -0,0 +1,438 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"repo.joyrex.net/ejstacey/ysm/history"
+	"repo.joyrex.net/ejstacey/ysm/tag"
+)
+
+var hexColourRegexp = regexp.MustCompile(`^[0-9A-Fa-f]{6}$`)
+
+func runTag(args []string) error {
+	if len(args) == 0 {
+		return newUsageError("missing tag subcommand")
+	}
+
+	switch args[0] {
+	case "list":
+		return runTagList(args[1:])
+	case "add":
+		return runTagAdd(args[1:])
+	case "rm":
+		return runTagRm(args[1:])
+	case "move":
+		return runTagMove(args[1:])
+	case "rename":
+		return runTagRename(args[1:])
+	case "merge":
+		return runTagMerge(args[1:])
+	case "split":
+		return runTagSplit(args[1:])
+	case "unalias":
+		return runTagUnalias(args[1:])
+	case "visibility":
+		return runTagVisibility(args[1:])
+	}
+
+	return newUsageError("unknown tag subcommand %q", args[0])
+}
+
+func runTagList(args []string) error {
+	fs := flag.NewFlagSet("tag list", flag.ContinueOnError)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return newUsageError("tag list takes no arguments")
+	}
+
+	e, err := loadEnv(false)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "NAME\tCHANNELS\tVISIBILITY\tOLD NAMES\tDESCRIPTION\n")
+	for _, tagInfo := range e.tags.Tree() {
+		// sub-tags are indented under their parent
+		name := strings.Repeat("  ", e.tags.Depth(tagInfo.Id())) + tagInfo.Name()
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", name, len(tagInfo.Channels()), tagInfo.Visibility(), strings.Join(e.tags.AliasesOf(tagInfo.Id()), ", "), tagInfo.Description())
+	}
+
+	return w.Flush()
+}
+
+func runTagAdd(args []string) error {
+	fs := flag.NewFlagSet("tag add", flag.ContinueOnError)
+	description := fs.String("description", "", "description of the tag")
+	fgColour := fs.String("fg", "", "foreground colour as a hex value, eg FFFFFF")
+	bgColour := fs.String("bg", "", "background colour as a hex value, eg FF0000")
+	parentName := fs.String("parent", "", "name of the tag to put the new tag under")
+	visibility := fs.String("visibility", tag.VisibilityPublic, "public, unlisted (left off the generated page, but not its channels) or private (left off along with its channels)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return newUsageError("tag add takes exactly one tag name")
+	}
+	if !slices.Contains(tag.Visibilities, *visibility) {
+		return newUsageError("visibility should be one of %s", strings.Join(tag.Visibilities, ", "))
+	}
+	for _, colour := range []string{*fgColour, *bgColour} {
+		if colour != "" && !hexColourRegexp.MatchString(colour) {
+			return newUsageError("%q is not a 6 digit hex colour", colour)
+		}
+	}
+
+	e, err := loadEnv(true)
+	if err != nil {
+		return err
+	}
+
+	if _, err := findTag(e.tags, positional[0]); err == nil {
+		return fmt.Errorf("tag %q already exists", positional[0])
+	}
+
+	var parent tag.Tag
+	if *parentName != "" {
+		parent, err = findTag(e.tags, *parentName)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the new tag and its settings are one step in the history. End is
+	// deferred as well so whatever was done is kept if something fails
+	history.Begin(fmt.Sprintf("create tag %q", positional[0]))
+	defer history.End()
+
+	var newTag tag.Tag
+	err = newTag.New()
+	if err != nil {
+		return fmt.Errorf("creating new tag: %w", err)
+	}
+	err = newTag.SetName(positional[0])
+	if err != nil {
+		return fmt.Errorf("updating tag name: %w", err)
+	}
+	if *description != "" {
+		err = newTag.SetDescription(*description)
+		if err != nil {
+			return fmt.Errorf("updating tag description: %w", err)
+		}
+	}
+	if *fgColour != "" {
+		err = newTag.SetFgColour(*fgColour)
+		if err != nil {
+			return fmt.Errorf("updating tag fgColour: %w", err)
+		}
+	}
+	if *bgColour != "" {
+		err = newTag.SetBgColour(*bgColour)
+		if err != nil {
+			return fmt.Errorf("updating tag bgColour: %w", err)
+		}
+	}
+	if parent.Id() != 0 {
+		err = newTag.SetParentId(parent.Id())
+		if err != nil {
+			return fmt.Errorf("updating tag parent: %w", err)
+		}
+	}
+	if *visibility != tag.VisibilityPublic {
+		err = newTag.SetVisibility(*visibility)
+		if err != nil {
+			return fmt.Errorf("updating tag visibility: %w", err)
+		}
+	}
+
+	err = history.End()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Added tag: %s\n", newTag.Name())
+
+	return nil
+}
+
+func runTagRm(args []string) error {
+	fs := flag.NewFlagSet("tag rm", flag.ContinueOnError)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return newUsageError("tag rm takes exactly one tag name")
+	}
+
+	e, err := loadEnv(true)
+	if err != nil {
+		return err
+	}
+
+	tagInfo, err := findTagByName(e.tags, positional[0])
+	if err != nil {
+		return err
+	}
+
+	err = tagInfo.Delete()
+	if err != nil {
+		return fmt.Errorf("deleting tag: %w", err)
+	}
+
+	fmt.Printf("Removed tag: %s\n", tagInfo.Name())
+
+	return nil
+}
+
+func runTagMove(args []string) error {
+	fs := flag.NewFlagSet("tag move", flag.ContinueOnError)
+	top := fs.Bool("top", false, "move the tag to the top level")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *top && len(positional) != 1 {
+		return newUsageError("tag move -top takes exactly one tag name")
+	}
+	if !*top && len(positional) != 2 {
+		return newUsageError("tag move takes a tag name and the name of its new parent, or -top")
+	}
+
+	e, err := loadEnv(true)
+	if err != nil {
+		return err
+	}
+
+	tagInfo, err := findTag(e.tags, positional[0])
+	if err != nil {
+		return err
+	}
+
+	var parent tag.Tag
+	if !*top {
+		parent, err = findTag(e.tags, positional[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tagInfo.SetParentId(parent.Id())
+	if err != nil {
+		return fmt.Errorf("moving tag: %w", err)
+	}
+
+	err = e.tags.Load()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Moved tag: %s\n", e.tags.Path(tagInfo.Id()))
+
+	return nil
+}
+
+// findTagByName is findTag for commands that throw a tag away, which only go
+// by its current name so an old one can't take out the tag it now finds.
+func findTagByName(tags tag.Tags, name string) (tag.Tag, error) {
+	tagInfo, err := findTag(tags, name)
+	if err != nil {
+		return tagInfo, err
+	}
+	if !strings.EqualFold(tagInfo.Name(), name) {
+		return tag.Tag{}, fmt.Errorf("%q is an old name of %q, use its current name", name, tagInfo.Name())
+	}
+
+	return tagInfo, nil
+}
+
+func runTagRename(args []string) error {
+	fs := flag.NewFlagSet("tag rename", flag.ContinueOnError)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return newUsageError("tag rename takes a tag name and its new name")
+	}
+
+	e, err := loadEnv(true)
+	if err != nil {
+		return err
+	}
+
+	tagInfo, err := findTagByName(e.tags, positional[0])
+	if err != nil {
+		return err
+	}
+	if other, err := findTag(e.tags, positional[1]); err == nil && other.Id() != tagInfo.Id() && strings.EqualFold(other.Name(), positional[1]) {
+		return fmt.Errorf("tag %q already exists", other.Name())
+	}
+
+	oldName := tagInfo.Name()
+	err = tagInfo.Rename(positional[1])
+	if err != nil {
+		return fmt.Errorf("renaming tag: %w", err)
+	}
+
+	fmt.Printf("Renamed tag %s to %s, %s still finds it\n", oldName, tagInfo.Name(), oldName)
+
+	return nil
+}
+
+func runTagMerge(args []string) error {
+	fs := flag.NewFlagSet("tag merge", flag.ContinueOnError)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return newUsageError("tag merge takes the name of the tag to merge and the tag to merge it into")
+	}
+
+	e, err := loadEnv(true)
+	if err != nil {
+		return err
+	}
+
+	from, err := findTagByName(e.tags, positional[0])
+	if err != nil {
+		return err
+	}
+	into, err := findTag(e.tags, positional[1])
+	if err != nil {
+		return err
+	}
+
+	gained, err := from.Merge(into)
+	if err != nil {
+		return fmt.Errorf("merging tag: %w", err)
+	}
+
+	fmt.Printf("Merged tag %s into %s: %d channels newly tagged %s, %d already were\n", from.Name(), into.Name(), gained, into.Name(), len(from.Channels())-gained)
+
+	return nil
+}
+
+func runTagSplit(args []string) error {
+	fs := flag.NewFlagSet("tag split", flag.ContinueOnError)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 3 {
+		return newUsageError("tag split takes a tag name, the name of the new tag and the channels to move to it")
+	}
+
+	e, err := loadEnv(true)
+	if err != nil {
+		return err
+	}
+
+	from, err := findTag(e.tags, positional[0])
+	if err != nil {
+		return err
+	}
+	if _, err := findTag(e.tags, positional[1]); err == nil {
+		return fmt.Errorf("tag %q already exists", positional[1])
+	}
+
+	var channelIds []string
+	for _, idOrName := range positional[2:] {
+		chanInfo, err := findChannel(e.channels, idOrName)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(channelIds, chanInfo.Id()) {
+			channelIds = append(channelIds, chanInfo.Id())
+		}
+	}
+
+	newTag, err := from.Split(positional[1], channelIds)
+	if err != nil {
+		return fmt.Errorf("splitting tag: %w", err)
+	}
+
+	fmt.Printf("Moved %d channels from %s to the new tag %s\n", len(channelIds), from.Name(), newTag.Name())
+
+	return nil
+}
+
+func runTagUnalias(args []string) error {
+	fs := flag.NewFlagSet("tag unalias", flag.ContinueOnError)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return newUsageError("tag unalias takes exactly one old tag name")
+	}
+
+	_, err = loadEnv(true)
+	if err != nil {
+		return err
+	}
+
+	err = tag.RemoveAlias(positional[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s no longer finds a tag\n", positional[0])
+
+	return nil
+}
+
+func runTagVisibility(args []string) error {
+	fs := flag.NewFlagSet("tag visibility", flag.ContinueOnError)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return newUsageError("tag visibility takes a tag name and public, unlisted or private")
+	}
+	if !slices.Contains(tag.Visibilities, positional[1]) {
+		return newUsageError("visibility should be one of %s", strings.Join(tag.Visibilities, ", "))
+	}
+
+	e, err := loadEnv(true)
+	if err != nil {
+		return err
+	}
+
+	tagInfo, err := findTag(e.tags, positional[0])
+	if err != nil {
+		return err
+	}
+
+	err = tagInfo.SetVisibility(positional[1])
+	if err != nil {
+		return fmt.Errorf("updating tag visibility: %w", err)
+	}
+
+	fmt.Printf("Tag %s is now %s\n", tagInfo.Name(), tagInfo.Visibility())
+
+	return nil
+}
@@ -0,0 +1,108 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+// Package feed reads the public rss (atom, really) feeds youtube has of each
+// channel's uploads, which needs no api quota or login.
+package feed
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultBaseUrl is where youtube serves channel feeds from.
+const DefaultBaseUrl = "https://www.youtube.com/feeds/videos.xml"
+
+// ErrNotFound is returned when there's no feed for a channel, which happens
+// when the channel has been deleted or terminated.
+var ErrNotFound = errors.New("no feed for the channel")
+
+// Video is an upload listed in a channel's feed.
+type Video struct {
+	Id          string
+	Title       string
+	PublishedAt time.Time
+}
+
+// Url is the address of the video on youtube.
+func (v Video) Url() string {
+	return "https://www.youtube.com/watch?v=" + url.QueryEscape(v.Id)
+}
+
+type Client struct {
+	baseUrl string
+	http    *http.Client
+}
+
+// NewClient returns a client reading feeds from baseUrl, which is
+// DefaultBaseUrl unless it's a stand-in like feedtest's.
+func NewClient(baseUrl string) *Client {
+	return &Client{baseUrl: baseUrl, http: &http.Client{Timeout: 20 * time.Second}}
+}
+
+// the parts of youtube's atom feeds that are used
+type atomFeed struct {
+	Entries []struct {
+		VideoId   string `xml:"http://www.youtube.com/xml/schemas/2015 videoId"`
+		Title     string `xml:"http://www.w3.org/2005/Atom title"`
+		Published string `xml:"http://www.w3.org/2005/Atom published"`
+	} `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+// Latest returns the newest upload in the channel's feed, or the zero Video
+// if the channel hasn't uploaded anything.
+func (c *Client) Latest(channelId string) (Video, error) {
+	var latest Video
+
+	response, err := c.http.Get(c.baseUrl + "?channel_id=" + url.QueryEscape(channelId))
+	if err != nil {
+		return latest, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return latest, ErrNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		return latest, fmt.Errorf("feed request failed: %s", response.Status)
+	}
+
+	// feeds only hold the last 15 uploads, so this is plenty
+	body, err := io.ReadAll(io.LimitReader(response.Body, 4<<20))
+	if err != nil {
+		return latest, err
+	}
+
+	var feed atomFeed
+	err = xml.Unmarshal(body, &feed)
+	if err != nil {
+		return latest, fmt.Errorf("unable to read the feed: %w", err)
+	}
+
+	// the newest is normally first, but don't count on it
+	for _, entry := range feed.Entries {
+		published, err := time.Parse(time.RFC3339, entry.Published)
+		if err != nil {
+			return latest, fmt.Errorf("video %s has a bad published date: %w", entry.VideoId, err)
+		}
+		if latest.Id == "" || published.After(latest.PublishedAt) {
+			latest = Video{Id: entry.VideoId, Title: entry.Title, PublishedAt: published}
+		}
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,105 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package feed_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/feed"
+	"repo.joyrex.net/ejstacey/ysm/feed/feedtest"
+)
+
+func TestLatest(t *testing.T) {
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	server := feedtest.NewServer(map[string][]feed.Video{
+		"UC111": {
+			{Id: "old", Title: "Old video", PublishedAt: day},
+			{Id: "new", Title: "New video", PublishedAt: day.Add(48 * time.Hour)},
+		},
+		"UC222": {},
+	})
+	defer server.Close()
+	client := feed.NewClient(server.URL())
+
+	latest, err := client.Latest("UC111")
+	if err != nil {
+		t.Fatalf("Latest(UC111): %v", err)
+	}
+	if latest.Id != "new" || latest.Title != "New video" || !latest.PublishedAt.Equal(day.Add(48*time.Hour)) {
+		t.Errorf("Latest(UC111) = %+v, want the new video", latest)
+	}
+
+	latest, err = client.Latest("UC222")
+	if err != nil || latest.Id != "" {
+		t.Errorf("Latest(UC222) = %+v, %v, want no video for an empty feed", latest, err)
+	}
+
+	_, err = client.Latest("UCdeleted")
+	if !errors.Is(err, feed.ErrNotFound) {
+		t.Errorf("Latest(UCdeleted) error = %v, want ErrNotFound", err)
+	}
+
+	server.SetStatus("UC111", http.StatusInternalServerError)
+	_, err = client.Latest("UC111")
+	if err == nil || errors.Is(err, feed.ErrNotFound) {
+		t.Errorf("Latest(UC111) error = %v when the feed fails, want a non-ErrNotFound error", err)
+	}
+}
+
+// serveFeed answers every request with body as the feed.
+func serveFeed(t *testing.T, body string) *feed.Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/atom+xml; charset=UTF-8")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return feed.NewClient(server.URL + "/feeds/videos.xml")
+}
+
+const feedHead = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns="http://www.w3.org/2005/Atom">`
+
+func TestLatestOutOfOrder(t *testing.T) {
+	client := serveFeed(t, feedHead+`
+		<entry><yt:videoId>middle</yt:videoId><title>Middle</title><published>2025-03-02T00:00:00+00:00</published></entry>
+		<entry><yt:videoId>newest</yt:videoId><title>Newest</title><published>2025-03-03T00:00:00+00:00</published></entry>
+		<entry><yt:videoId>oldest</yt:videoId><title>Oldest</title><published>2025-03-01T00:00:00+00:00</published></entry>
+	</feed>`)
+
+	latest, err := client.Latest("UC111")
+	if err != nil {
+		t.Fatalf("Latest: %v", err)
+	}
+	if latest.Id != "newest" || latest.Title != "Newest" {
+		t.Errorf("Latest = %+v, want the newest video", latest)
+	}
+}
+
+func TestLatestBadDate(t *testing.T) {
+	client := serveFeed(t, feedHead+`
+		<entry><yt:videoId>good</yt:videoId><title>Good</title><published>2025-03-02T00:00:00+00:00</published></entry>
+		<entry><yt:videoId>bad</yt:videoId><title>Bad</title><published>last tuesday</published></entry>
+	</feed>`)
+
+	_, err := client.Latest("UC111")
+	if err == nil {
+		t.Errorf("Latest with a bad published date didn't fail")
+	}
+}
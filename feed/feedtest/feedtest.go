@@ -0,0 +1,137 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+// Package feedtest has a stand-in for youtube's channel feeds, so feed
+// checking can be exercised without the network.
+package feedtest
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/feed"
+)
+
+// Server is an http server answering feed requests the way youtube does, with
+// an atom feed of the channel's videos, newest first, or a 404 for channels
+// it doesn't know.
+type Server struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	videos   map[string][]feed.Video
+	statuses map[string]int
+	requests int
+}
+
+// NewServer starts a server with videos, keyed by channel id, as the uploads.
+// A channel with no videos has an empty feed. Close it when done.
+func NewServer(videos map[string][]feed.Video) *Server {
+	s := &Server{videos: videos, statuses: make(map[string]int)}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+func (s *Server) Close() { s.server.Close() }
+
+// URL is the base url to give feed.NewClient.
+func (s *Server) URL() string { return s.server.URL + "/feeds/videos.xml" }
+
+// Requests counts the requests answered so far.
+func (s *Server) Requests() int { s.mu.Lock(); defer s.mu.Unlock(); return s.requests }
+
+// SetVideos replaces the uploads of a channel. nil removes the channel, so
+// its feed is a 404.
+func (s *Server) SetVideos(channelId string, videos []feed.Video) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if videos == nil {
+		delete(s.videos, channelId)
+		return
+	}
+	s.videos[channelId] = videos
+}
+
+// SetStatus makes the channel's feed fail with the http status code, like
+// youtube does now and then. 0 goes back to serving the feed.
+func (s *Server) SetStatus(channelId string, code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if code == 0 {
+		delete(s.statuses, channelId)
+		return
+	}
+	s.statuses[channelId] = code
+}
+
+// the feed youtube sends, cut down to what's needed
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Yt      string      `xml:"xmlns:yt,attr"`
+	Title   string      `xml:"title"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Id        string `xml:"id"`
+	VideoId   string `xml:"yt:videoId"`
+	Title     string `xml:"title"`
+	Published string `xml:"published"`
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+
+	if r.URL.Path != "/feeds/videos.xml" {
+		http.NotFound(w, r)
+		return
+	}
+	if code, ok := s.statuses[r.FormValue("channel_id")]; ok {
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+	videos, ok := s.videos[r.FormValue("channel_id")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	videos = slices.Clone(videos)
+	slices.SortFunc(videos, func(a, b feed.Video) int { return b.PublishedAt.Compare(a.PublishedAt) })
+
+	out := atomFeed{Yt: "http://www.youtube.com/xml/schemas/2015", Title: r.FormValue("channel_id")}
+	for _, video := range videos {
+		out.Entries = append(out.Entries, atomEntry{
+			Id:        "yt:video:" + video.Id,
+			VideoId:   video.Id,
+			Title:     video.Title,
+			Published: video.PublishedAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	body, err := xml.MarshalIndent(out, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=UTF-8")
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
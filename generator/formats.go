@@ -0,0 +1,456 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package generator
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/tag"
+)
+
+const (
+	FormatHtml     = "html"
+	FormatJson     = "json"
+	FormatCsv      = "csv"
+	FormatMarkdown = "markdown"
+	FormatAtom     = "atom"
+	FormatSite     = "site"
+)
+
+// Formats are the formats the generator can write. A site is never picked
+// from the output file's extension, it has to be asked for.
+var Formats = []string{FormatHtml, FormatJson, FormatCsv, FormatMarkdown, FormatAtom, FormatSite}
+
+// jsonVersion is the version of the json output, bumped whenever a field is
+// changed or removed. Adding fields doesn't change it.
+const jsonVersion = 1
+
+// atomEntries is how many of the most recently tagged channels go in the
+// atom feed.
+const atomEntries = 50
+
+// FormatFor returns the format to write outputFile in: format if it's set,
+// otherwise whatever the file's extension says, or html if it doesn't say
+// anything.
+func FormatFor(format string, outputFile string) (string, error) {
+	if format != "" {
+		if !slices.Contains(Formats, format) {
+			return "", fmt.Errorf("unknown format %q, it has to be one of %s", format, strings.Join(Formats, ", "))
+		}
+		return format, nil
+	}
+
+	switch strings.ToLower(filepath.Ext(outputFile)) {
+	case ".json":
+		return FormatJson, nil
+	case ".csv":
+		return FormatCsv, nil
+	case ".md", ".markdown":
+		return FormatMarkdown, nil
+	case ".atom", ".xml":
+		return FormatAtom, nil
+	}
+
+	return FormatHtml, nil
+}
+
+type jsonDocument struct {
+	Version     int           `json:"version"`
+	Title       string        `json:"title"`
+	GeneratedAt time.Time     `json:"generatedAt"`
+	Channels    []jsonChannel `json:"channels"`
+	Tags        []jsonTag     `json:"tags"`
+}
+
+type jsonChannel struct {
+	Id          string           `json:"id"`
+	Name        string           `json:"name"`
+	Url         string           `json:"url"`
+	Description string           `json:"description"`
+	Notes       string           `json:"notes"`
+	NotesHTML   string           `json:"notesHtml"`
+	Tags        []jsonChannelTag `json:"tags"`
+	TaggedAt    *time.Time       `json:"taggedAt,omitempty"`
+	// Details is left out for channels that haven't been enriched, and
+	// LatestVideo for ones without uploads or whose feed hasn't been read.
+	Details       *jsonDetails `json:"details,omitempty"`
+	LatestVideo   *jsonVideo   `json:"latestVideo,omitempty"`
+	FeedCheckedAt *time.Time   `json:"feedCheckedAt,omitempty"`
+}
+
+type jsonChannelTag struct {
+	Id       int64      `json:"id"`
+	Name     string     `json:"name"`
+	TaggedAt *time.Time `json:"taggedAt,omitempty"`
+}
+
+type jsonDetails struct {
+	AvatarUrl string `json:"avatarUrl,omitempty"`
+	// SubscriberCount is left out for channels that hide it.
+	SubscriberCount *int64     `json:"subscriberCount,omitempty"`
+	VideoCount      int64      `json:"videoCount"`
+	Country         string     `json:"country,omitempty"`
+	Handle          string     `json:"handle,omitempty"`
+	CreatedAt       *time.Time `json:"createdAt,omitempty"`
+	EnrichedAt      time.Time  `json:"enrichedAt"`
+}
+
+type jsonVideo struct {
+	Id          string    `json:"id"`
+	Title       string    `json:"title"`
+	Url         string    `json:"url"`
+	PublishedAt time.Time `json:"publishedAt"`
+}
+
+type jsonTag struct {
+	Id          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	FgColour    string `json:"fgColour"`
+	BgColour    string `json:"bgColour"`
+	// ParentId is left out for top level tags.
+	ParentId int64 `json:"parentId,omitempty"`
+}
+
+// timeOrNil is t, or nil if it's zero, for fields that are left out when
+// they're not known.
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func (g Generator) writeJson(w io.Writer) error {
+	doc := jsonDocument{
+		Version:     jsonVersion,
+		Title:       g.Title,
+		GeneratedAt: time.Now().UTC().Truncate(time.Second),
+		Channels:    make([]jsonChannel, 0, len(g.Channels)),
+		Tags:        make([]jsonTag, 0, len(g.Tags)),
+	}
+
+	for _, chanInfo := range g.Channels {
+		c := jsonChannel{
+			Id:            chanInfo.Id,
+			Name:          chanInfo.Name,
+			Url:           chanInfo.Url,
+			Description:   chanInfo.Description,
+			Notes:         chanInfo.Notes,
+			NotesHTML:     string(chanInfo.NotesHTML),
+			Tags:          make([]jsonChannelTag, 0, len(chanInfo.Tags)),
+			TaggedAt:      timeOrNil(chanInfo.TaggedAt),
+			FeedCheckedAt: timeOrNil(chanInfo.FeedCheckedAt),
+		}
+		for _, tagInfo := range chanInfo.Tags {
+			c.Tags = append(c.Tags, jsonChannelTag{Id: tagInfo.Id, Name: tagInfo.Name, TaggedAt: timeOrNil(tagInfo.TaggedAt)})
+		}
+		if chanInfo.Enriched() {
+			c.Details = &jsonDetails{
+				AvatarUrl:  chanInfo.AvatarUrl,
+				VideoCount: chanInfo.VideoCount,
+				Country:    chanInfo.Country,
+				Handle:     chanInfo.CustomUrl,
+				CreatedAt:  timeOrNil(chanInfo.CreatedAt),
+				EnrichedAt: chanInfo.EnrichedAt,
+			}
+			if !chanInfo.SubscribersHidden {
+				c.Details.SubscriberCount = &chanInfo.SubscriberCount
+			}
+		}
+		if chanInfo.LatestVideo.Id != "" {
+			c.LatestVideo = &jsonVideo{
+				Id:          chanInfo.LatestVideo.Id,
+				Title:       chanInfo.LatestVideo.Title,
+				Url:         chanInfo.LatestVideo.Url(),
+				PublishedAt: chanInfo.LatestVideo.PublishedAt,
+			}
+		}
+		doc.Channels = append(doc.Channels, c)
+	}
+
+	for _, tagInfo := range g.Tags {
+		doc.Tags = append(doc.Tags, jsonTag{
+			Id:          tagInfo.Id,
+			Name:        tagInfo.Name,
+			Description: tagInfo.Description,
+			FgColour:    tagInfo.FgColour,
+			BgColour:    tagInfo.BgColour,
+			ParentId:    tagInfo.ParentId,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// tagNames is the names of tags, separated with "; " since tag names can have
+// commas in them.
+func tagNames(tags []tag.ExportTag) string {
+	var names []string
+	for _, tagInfo := range tags {
+		names = append(names, tagInfo.Name)
+	}
+	return strings.Join(names, "; ")
+}
+
+func formatTime(t time.Time, layout string) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(layout)
+}
+
+// writeCsv writes a row for each channel. Counts and dates that aren't known
+// are left empty.
+func (g Generator) writeCsv(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	err := cw.Write([]string{"id", "name", "url", "description", "notes", "tags", "subscribers", "videos", "country", "handle", "createdAt", "lastUploadAt", "lastUploadTitle", "lastUploadUrl"})
+	if err != nil {
+		return err
+	}
+
+	for _, chanInfo := range g.Channels {
+		var subscribers, videos string
+		if chanInfo.Enriched() {
+			videos = strconv.FormatInt(chanInfo.VideoCount, 10)
+			if !chanInfo.SubscribersHidden {
+				subscribers = strconv.FormatInt(chanInfo.SubscriberCount, 10)
+			}
+		}
+
+		var lastUploadUrl string
+		if chanInfo.LatestVideo.Id != "" {
+			lastUploadUrl = chanInfo.LatestVideo.Url()
+		}
+
+		err = cw.Write([]string{
+			chanInfo.Id,
+			chanInfo.Name,
+			chanInfo.Url,
+			chanInfo.Description,
+			chanInfo.Notes,
+			tagNames(chanInfo.Tags),
+			subscribers,
+			videos,
+			chanInfo.Country,
+			chanInfo.CustomUrl,
+			formatTime(chanInfo.CreatedAt, time.RFC3339),
+			formatTime(chanInfo.LatestVideo.PublishedAt, time.RFC3339),
+			chanInfo.LatestVideo.Title,
+			lastUploadUrl,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, "#", `\#`,
+)
+
+// writeMarkdown writes a section for each tag, in tree order with sub-tags a
+// heading level down, listing the channels with that tag, and one for the
+// untagged channels at the end. A channel with several tags is listed under
+// each of them.
+func (g Generator) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", markdownEscaper.Replace(g.Title))
+	fmt.Fprintf(&b, "Generated %s.\n", g.GenerateDateTime)
+
+	writeChannels := func(channels []channel.ExportChannel) {
+		var items []string
+		var withNotes bool
+		for _, chanInfo := range channels {
+			var item strings.Builder
+			fmt.Fprintf(&item, "- [%s](%s)", markdownEscaper.Replace(chanInfo.Name), chanInfo.Url)
+			if description, _, _ := strings.Cut(chanInfo.Description, "\n"); strings.TrimSpace(description) != "" {
+				fmt.Fprintf(&item, ": %s", markdownEscaper.Replace(strings.TrimSpace(description)))
+			}
+			item.WriteString("\n")
+
+			// the notes are markdown already, so they go in as they are,
+			// indented to be part of the list item
+			if notes := strings.TrimSpace(chanInfo.Notes); notes != "" {
+				withNotes = true
+				for _, line := range strings.Split(notes, "\n") {
+					if strings.TrimSpace(line) == "" {
+						item.WriteString("\n")
+						continue
+					}
+					fmt.Fprintf(&item, "\n  %s", line)
+				}
+				item.WriteString("\n")
+			}
+			items = append(items, item.String())
+		}
+
+		// notes take up several paragraphs, so with any of them the list
+		// has blank lines between the items
+		b.WriteString("\n")
+		if withNotes {
+			b.WriteString(strings.Join(items, "\n"))
+		} else {
+			b.WriteString(strings.Join(items, ""))
+		}
+	}
+
+	for _, tagInfo := range g.Tags {
+		var channels []channel.ExportChannel
+		for _, chanInfo := range g.Channels {
+			if slices.ContainsFunc(chanInfo.Tags, func(t tag.ExportTag) bool { return t.Id == tagInfo.Id }) {
+				channels = append(channels, chanInfo)
+			}
+		}
+
+		fmt.Fprintf(&b, "\n%s %s\n", strings.Repeat("#", min(tagInfo.Depth+2, 6)), markdownEscaper.Replace(tagInfo.Name))
+		if tagInfo.Description != "" {
+			fmt.Fprintf(&b, "\n%s\n", markdownEscaper.Replace(tagInfo.Description))
+		}
+		writeChannels(channels)
+	}
+
+	var untagged []channel.ExportChannel
+	for _, chanInfo := range g.Channels {
+		if len(chanInfo.Tags) == 0 {
+			untagged = append(untagged, chanInfo)
+		}
+	}
+	if len(untagged) > 0 {
+		b.WriteString("\n## Untagged\n")
+		writeChannels(untagged)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title     string      `xml:"title"`
+	Id        string      `xml:"id"`
+	Updated   string      `xml:"updated"`
+	Author    atomAuthor  `xml:"author"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	Id         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Link       atomLink       `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary,omitempty"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// writeAtom writes an atom feed of the most recently tagged channels, newest
+// first, so followers can see what's been added. Channels tagged before ysm
+// recorded when aren't in it. Each channel's id is its url, so a channel
+// tagged again shows up as an update rather than a new entry.
+func (g Generator) writeAtom(w io.Writer) error {
+	var tagged []channel.ExportChannel
+	for _, chanInfo := range g.Channels {
+		if !chanInfo.TaggedAt.IsZero() {
+			tagged = append(tagged, chanInfo)
+		}
+	}
+	slices.SortStableFunc(tagged, func(a, b channel.ExportChannel) int {
+		return b.TaggedAt.Compare(a.TaggedAt)
+	})
+	if len(tagged) > atomEntries {
+		tagged = tagged[:atomEntries]
+	}
+
+	feed := atomFeed{
+		Title:     g.Title,
+		Id:        "urn:ysm:" + url.PathEscape(g.Title),
+		Updated:   time.Now().UTC().Format(time.RFC3339),
+		Author:    atomAuthor{Name: g.Title},
+		Generator: "ysm",
+	}
+	if len(tagged) > 0 {
+		feed.Updated = tagged[0].TaggedAt.UTC().Format(time.RFC3339)
+	}
+
+	for _, chanInfo := range tagged {
+		// the tags it was given most recently first
+		tags := slices.Clone(chanInfo.Tags)
+		slices.SortStableFunc(tags, func(a, b tag.ExportTag) int {
+			return b.TaggedAt.Compare(a.TaggedAt)
+		})
+
+		var names []string
+		for _, tagInfo := range tags {
+			names = append(names, tagInfo.Name)
+		}
+
+		entry := atomEntry{
+			Title:   chanInfo.Name,
+			Id:      chanInfo.Url,
+			Updated: chanInfo.TaggedAt.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: chanInfo.Url},
+			Summary: strings.TrimSpace("Tagged " + strings.Join(names, ", ") + ". " + chanInfo.Description),
+		}
+		for _, name := range names {
+			entry.Categories = append(entry.Categories, atomCategory{Term: name})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(feed)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,257 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package generator
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/feed"
+	"repo.joyrex.net/ejstacey/ysm/tag"
+)
+
+func day(d int) time.Time {
+	return time.Date(2025, 1, d, 12, 0, 0, 0, time.UTC)
+}
+
+// testGenerator has an enriched channel with a recent upload and two tags,
+// one that hides its subscriber count and an untagged one that hasn't been
+// enriched.
+func testGenerator() Generator {
+	music := tag.ExportTag{Id: 1, Name: "music"}
+	synthwave := tag.ExportTag{Id: 2, Name: "synthwave", ParentId: 1}
+	retro := tag.ExportTag{Id: 3, Name: "retro; 80s"}
+
+	synthGuy := channel.ExportChannel{
+		Id:            "UC1",
+		Name:          "Synth Guy",
+		Url:           "https://www.youtube.com/channel/UC1",
+		Description:   "Synths, mostly",
+		Notes:         "Watch *later*",
+		NotesHTML:     "<p>Watch <em>later</em></p>\n",
+		TaggedAt:      day(3),
+		LatestVideo:   feed.Video{Id: "vid1", Title: "New track", PublishedAt: day(5)},
+		FeedCheckedAt: day(6),
+	}
+	synthwave.TaggedAt = day(3)
+	retro.TaggedAt = day(1)
+	synthGuy.Tags = []tag.ExportTag{retro, synthwave}
+	synthGuy.Details = channel.Details{SubscriberCount: 1200, VideoCount: 40, Country: "CA", CustomUrl: "@synthguy", CreatedAt: day(1), EnrichedAt: day(6)}
+
+	shy := channel.ExportChannel{
+		Id:       "UC2",
+		Name:     "Shy",
+		Url:      "https://www.youtube.com/channel/UC2",
+		TaggedAt: day(4),
+	}
+	music.TaggedAt = day(4)
+	shy.Tags = []tag.ExportTag{music}
+	shy.Details = channel.Details{SubscribersHidden: true, VideoCount: 3, EnrichedAt: day(6)}
+
+	untagged := channel.ExportChannel{
+		Id:   "UC3",
+		Name: "Untagged, \"quoted\"",
+		Url:  "https://www.youtube.com/channel/UC3",
+	}
+
+	return Generator{
+		Title:    "My subs",
+		Channels: []channel.ExportChannel{synthGuy, shy, untagged},
+		Tags:     []tag.ExportTag{music, synthwave, retro},
+	}
+}
+
+func TestFormatFor(t *testing.T) {
+	tests := []struct {
+		format     string
+		outputFile string
+		want       string
+	}{
+		{"", "subs.html", FormatHtml},
+		{"", "subs", FormatHtml},
+		{"", "subs.JSON", FormatJson},
+		{"", "subs.csv", FormatCsv},
+		{"", "subs.md", FormatMarkdown},
+		{"", "subs.markdown", FormatMarkdown},
+		{"", "subs.atom", FormatAtom},
+		{"", "feed.xml", FormatAtom},
+		// a format that's asked for wins over the extension
+		{FormatCsv, "subs.json", FormatCsv},
+		{FormatSite, "site", FormatSite},
+	}
+
+	for _, test := range tests {
+		got, err := FormatFor(test.format, test.outputFile)
+		if err != nil {
+			t.Errorf("%q %q: %v", test.format, test.outputFile, err)
+		} else if got != test.want {
+			t.Errorf("%q %q: got %s, want %s", test.format, test.outputFile, got, test.want)
+		}
+	}
+
+	_, err := FormatFor("pdf", "subs.pdf")
+	if err == nil || !strings.Contains(err.Error(), `unknown format "pdf"`) {
+		t.Errorf("pdf: got error %v, want an unknown format", err)
+	}
+}
+
+func TestWriteCsv(t *testing.T) {
+	var b strings.Builder
+	err := testGenerator().writeCsv(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(strings.NewReader(b.String())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"id", "name", "url", "description", "notes", "tags", "subscribers", "videos", "country", "handle", "createdAt", "lastUploadAt", "lastUploadTitle", "lastUploadUrl"},
+		{"UC1", "Synth Guy", "https://www.youtube.com/channel/UC1", "Synths, mostly", "Watch *later*", "retro; 80s; synthwave", "1200", "40", "CA", "@synthguy", "2025-01-01T12:00:00Z", "2025-01-05T12:00:00Z", "New track", "https://www.youtube.com/watch?v=vid1"},
+		// hidden subscriber counts are left empty, not 0
+		{"UC2", "Shy", "https://www.youtube.com/channel/UC2", "", "", "music", "", "3", "", "", "", "", "", ""},
+		// and so is everything that isn't known
+		{"UC3", `Untagged, "quoted"`, "https://www.youtube.com/channel/UC3", "", "", "", "", "", "", "", "", "", "", ""},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d:\n%s", len(rows), len(want), b.String())
+	}
+	for i := range want {
+		if !slices.Equal(rows[i], want[i]) {
+			t.Errorf("row %d is %q, want %q", i, rows[i], want[i])
+		}
+	}
+}
+
+func TestWriteJson(t *testing.T) {
+	var b strings.Builder
+	err := testGenerator().writeJson(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc jsonDocument
+	err = json.Unmarshal([]byte(b.String()), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Version != jsonVersion || doc.Title != "My subs" || len(doc.Channels) != 3 || len(doc.Tags) != 3 {
+		t.Fatalf("got version %d, title %q, %d channels and %d tags:\n%s", doc.Version, doc.Title, len(doc.Channels), len(doc.Tags), b.String())
+	}
+
+	synthGuy, shy, untagged := doc.Channels[0], doc.Channels[1], doc.Channels[2]
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"notes html", synthGuy.NotesHTML, "<p>Watch <em>later</em></p>\n"},
+		{"tags", fmt.Sprint(synthGuy.Tags[0].Name, " ", *synthGuy.Tags[0].TaggedAt), "retro; 80s 2025-01-01 12:00:00 +0000 UTC"},
+		{"subscribers", *synthGuy.Details.SubscriberCount, int64(1200)},
+		{"handle", synthGuy.Details.Handle, "@synthguy"},
+		{"latest video", *synthGuy.LatestVideo, jsonVideo{Id: "vid1", Title: "New track", Url: "https://www.youtube.com/watch?v=vid1", PublishedAt: day(5)}},
+		{"hidden subscribers", shy.Details.SubscriberCount, (*int64)(nil)},
+		{"hidden subscribers' videos", shy.Details.VideoCount, int64(3)},
+		{"no details", untagged.Details, (*jsonDetails)(nil)},
+		{"no latest video", untagged.LatestVideo, (*jsonVideo)(nil)},
+		{"no tagged at", untagged.TaggedAt, (*time.Time)(nil)},
+		{"untagged tags", untagged.Tags, []jsonChannelTag{}},
+		{"parent id", doc.Tags[1].ParentId, int64(1)},
+	}
+	for _, test := range tests {
+		if fmt.Sprint(test.got) != fmt.Sprint(test.want) {
+			t.Errorf("%s is %v, want %v", test.name, test.got, test.want)
+		}
+	}
+
+	// fields that aren't known are left out, not null
+	for _, field := range []string{`"details": null`, `"latestVideo": null`, `"parentId": 0`} {
+		if strings.Contains(b.String(), field) {
+			t.Errorf("json has %s:\n%s", field, b.String())
+		}
+	}
+	if !strings.Contains(b.String(), `"tags": []`) {
+		t.Errorf("json has no empty tag list for the untagged channel:\n%s", b.String())
+	}
+}
+
+func TestWriteAtom(t *testing.T) {
+	g := testGenerator()
+	var b strings.Builder
+	err := g.writeAtom(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var feed atomFeed
+	err = xml.Unmarshal([]byte(b.String()), &feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// newest first, the untagged channel left out, and the tags most
+	// recently given first
+	want := []atomEntry{
+		{
+			Title:      "Shy",
+			Id:         "https://www.youtube.com/channel/UC2",
+			Updated:    "2025-01-04T12:00:00Z",
+			Link:       atomLink{Href: "https://www.youtube.com/channel/UC2"},
+			Categories: []atomCategory{{Term: "music"}},
+			Summary:    "Tagged music.",
+		},
+		{
+			Title:      "Synth Guy",
+			Id:         "https://www.youtube.com/channel/UC1",
+			Updated:    "2025-01-03T12:00:00Z",
+			Link:       atomLink{Href: "https://www.youtube.com/channel/UC1"},
+			Categories: []atomCategory{{Term: "synthwave"}, {Term: "retro; 80s"}},
+			Summary:    "Tagged synthwave, retro; 80s. Synths, mostly",
+		},
+	}
+	if feed.Title != "My subs" || feed.Updated != "2025-01-04T12:00:00Z" {
+		t.Errorf("feed is %q updated %s, want My subs updated with the newest entry", feed.Title, feed.Updated)
+	}
+	if fmt.Sprint(feed.Entries) != fmt.Sprint(want) {
+		t.Errorf("entries are\n%v\nwant\n%v", feed.Entries, want)
+	}
+
+	// only the most recent atomEntries
+	g.Channels = nil
+	for i := range atomEntries + 10 {
+		g.Channels = append(g.Channels, channel.ExportChannel{Id: fmt.Sprint(i), TaggedAt: day(1).Add(time.Duration(i) * time.Hour)})
+	}
+	b.Reset()
+	err = g.writeAtom(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed = atomFeed{}
+	err = xml.Unmarshal([]byte(b.String()), &feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Entries) != atomEntries || feed.Entries[0].Updated != "2025-01-03T23:00:00Z" {
+		t.Errorf("feed has %d entries, the first updated %s, want %d from 2025-01-03T23:00:00Z", len(feed.Entries), feed.Entries[0].Updated, atomEntries)
+	}
+}
@@ -0,0 +1,227 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package generator
+
+import (
+	"cmp"
+	"fmt"
+	"html/template"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/devkvlt/hexer"
+	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/markdown"
+	"repo.joyrex.net/ejstacey/ysm/tag"
+)
+
+// Funcs are the functions templates can use on top of the standard ones.
+var Funcs = template.FuncMap{
+	"markdown":   markdown.ToHTML,
+	"date":       formatDate,
+	"contrast":   contrastColour,
+	"slugify":    slugify,
+	"groupByTag": groupByTag,
+	"sortBy":     sortBy,
+	"truncate":   truncate,
+}
+
+// TagGroup is a tag with the channels tagged with it, from groupByTag.
+type TagGroup struct {
+	// Tag is empty for the group of untagged channels.
+	Tag      tag.ExportTag
+	Channels []channel.ExportChannel
+}
+
+// formatDate formats t with a go layout, like {{.TaggedAt | date
+// "2006-01-02"}}. Times that aren't known come out empty.
+func formatDate(layout string, t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(layout)
+}
+
+// contrastColour returns the text colour, FFFFFF or 000000, that's easiest
+// to read on the colour hex, worked out the same way as the TUI's tags. It's
+// empty if hex isn't a colour.
+func contrastColour(hex string) string {
+	inverted, err := hexer.Invert("#" + strings.TrimPrefix(hex, "#"))
+	if err != nil {
+		return ""
+	}
+	lightness, err := hexer.Lightness(inverted)
+	if err != nil {
+		return ""
+	}
+
+	if lightness > 50 {
+		return "FFFFFF"
+	}
+	return "000000"
+}
+
+// slugify makes s into something that can go in a url or a file name, like
+// "Lo-Fi & Chill" to "lo-fi-chill". Letters with accents are kept.
+func slugify(s string) string {
+	var b strings.Builder
+	var dash bool
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if dash && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		default:
+			dash = true
+		}
+	}
+
+	return b.String()
+}
+
+// groupByTag groups channels under each of their tags, sorted by tag name,
+// with the untagged channels in a last group without a tag. A channel with
+// several tags is in each of their groups.
+func groupByTag(channels []channel.ExportChannel) []TagGroup {
+	var groups []TagGroup
+	var untagged []channel.ExportChannel
+	for _, chanInfo := range channels {
+		if len(chanInfo.Tags) == 0 {
+			untagged = append(untagged, chanInfo)
+			continue
+		}
+		for _, chanTag := range chanInfo.Tags {
+			i := slices.IndexFunc(groups, func(g TagGroup) bool { return g.Tag.Id == chanTag.Id })
+			if i == -1 {
+				// when the first channel got the tag means nothing for the group
+				chanTag.TaggedAt = time.Time{}
+				groups = append(groups, TagGroup{Tag: chanTag})
+				i = len(groups) - 1
+			}
+			groups[i].Channels = append(groups[i].Channels, chanInfo)
+		}
+	}
+
+	slices.SortFunc(groups, func(a, b TagGroup) int {
+		return cmp.Or(strings.Compare(strings.ToLower(a.Tag.Name), strings.ToLower(b.Tag.Name)), cmp.Compare(a.Tag.Id, b.Tag.Id))
+	})
+	if len(untagged) != 0 {
+		groups = append(groups, TagGroup{Channels: untagged})
+	}
+
+	return groups
+}
+
+// sortBy returns a sorted copy of a list of channels, tags or tag groups, by
+// the named field, like {{range sortBy "SubscriberCount" .Channels}}. A "-"
+// in front sorts the other way. Text is sorted ignoring case.
+func sortBy(field string, list any) (any, error) {
+	descending := strings.HasPrefix(field, "-")
+	field = strings.TrimPrefix(field, "-")
+
+	value := reflect.ValueOf(list)
+	if value.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("can't sort a %s", value.Kind())
+	}
+	sorted := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+	reflect.Copy(sorted, value)
+
+	elemType := value.Type().Elem()
+	for elemType.Kind() == reflect.Pointer {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("can't sort a list of %s by a field", elemType)
+	}
+	if _, ok := elemType.FieldByName(field); !ok {
+		return nil, fmt.Errorf("%s has no field %q", elemType.Name(), field)
+	}
+
+	var key = func(i int) reflect.Value {
+		return reflect.Indirect(sorted.Index(i)).FieldByName(field)
+	}
+	var keys = make([]reflect.Value, sorted.Len())
+	var order = make([]int, sorted.Len())
+	for i := range keys {
+		keys[i] = key(i)
+		order[i] = i
+	}
+
+	var err error
+	slices.SortStableFunc(order, func(a, b int) int {
+		c, compareErr := compareValues(keys[a], keys[b])
+		if compareErr != nil {
+			err = compareErr
+		}
+		if descending {
+			return -c
+		}
+		return c
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", field, err)
+	}
+
+	result := reflect.MakeSlice(value.Type(), len(order), len(order))
+	for i, j := range order {
+		result.Index(i).Set(sorted.Index(j))
+	}
+
+	return result.Interface(), nil
+}
+
+func compareValues(a, b reflect.Value) (int, error) {
+	if at, ok := a.Interface().(time.Time); ok {
+		return at.Compare(b.Interface().(time.Time)), nil
+	}
+
+	switch a.Kind() {
+	case reflect.String:
+		return strings.Compare(strings.ToLower(a.String()), strings.ToLower(b.String())), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp.Compare(a.Uint(), b.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(a.Float(), b.Float()), nil
+	case reflect.Bool:
+		return cmp.Compare(boolInt(a.Bool()), boolInt(b.Bool())), nil
+	}
+
+	return 0, fmt.Errorf("can't sort by a %s", a.Type())
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// truncate cuts s down to n characters, ending it with "…" if anything was
+// cut off, like {{.Description | truncate 100}}.
+func truncate(n int, s string) string {
+	runes := []rune(s)
+	if n < 1 || len(runes) <= n {
+		return s
+	}
+
+	return strings.TrimRightFunc(string(runes[:n-1]), unicode.IsSpace) + "…"
+}
@@ -0,0 +1,156 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package generator
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/tag"
+)
+
+func exportChannel(id, name string, subscribers int64, tags ...tag.ExportTag) channel.ExportChannel {
+	var chanInfo = channel.ExportChannel{Id: id, Name: name, Tags: tags}
+	chanInfo.SubscriberCount = subscribers
+	return chanInfo
+}
+
+func names(channels []channel.ExportChannel) []string {
+	var names []string
+	for _, chanInfo := range channels {
+		names = append(names, chanInfo.Name)
+	}
+	return names
+}
+
+func TestSortBy(t *testing.T) {
+	channels := []channel.ExportChannel{
+		exportChannel("UC1", "beta", 20),
+		exportChannel("UC2", "Alpha", 300),
+		exportChannel("UC3", "gamma", 20),
+	}
+	channels[0].TaggedAt = time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	channels[2].TaggedAt = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		field string
+		want  []string
+	}{
+		// text ignores case
+		{"Name", []string{"Alpha", "beta", "gamma"}},
+		{"-Name", []string{"gamma", "beta", "Alpha"}},
+		// ties keep their order, either way round
+		{"SubscriberCount", []string{"beta", "gamma", "Alpha"}},
+		{"-SubscriberCount", []string{"Alpha", "beta", "gamma"}},
+		{"TaggedAt", []string{"Alpha", "gamma", "beta"}},
+		{"SubscribersHidden", []string{"beta", "Alpha", "gamma"}},
+	}
+
+	for _, test := range tests {
+		sorted, err := sortBy(test.field, channels)
+		if err != nil {
+			t.Errorf("%s: %v", test.field, err)
+			continue
+		}
+		if got := names(sorted.([]channel.ExportChannel)); !slices.Equal(got, test.want) {
+			t.Errorf("%s: sorted %q, want %q", test.field, got, test.want)
+		}
+	}
+
+	if got := names(channels); !slices.Equal(got, []string{"beta", "Alpha", "gamma"}) {
+		t.Errorf("sortBy changed the list it was given to %q", got)
+	}
+}
+
+func TestSortByPointers(t *testing.T) {
+	a, b := exportChannel("UC1", "b", 0), exportChannel("UC2", "a", 0)
+
+	sorted, err := sortBy("Name", []*channel.ExportChannel{&a, &b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sorted.([]*channel.ExportChannel); got[0].Id != "UC2" || got[1].Id != "UC1" {
+		t.Errorf("sorted %s, %s, want UC2, UC1", got[0].Id, got[1].Id)
+	}
+}
+
+func TestSortByErrors(t *testing.T) {
+	tests := []struct {
+		field string
+		list  any
+		want  string
+	}{
+		{"Name", "not a list", "can't sort a string"},
+		{"Name", []string{"a", "b"}, "can't sort a list of string by a field"},
+		{"Colour", []channel.ExportChannel{{}}, `ExportChannel has no field "Colour"`},
+		{"Tags", []channel.ExportChannel{{}, {}}, "Tags: can't sort by a []tag.ExportTag"},
+	}
+
+	for _, test := range tests {
+		_, err := sortBy(test.field, test.list)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s on %T: got error %v, want one containing %q", test.field, test.list, err, test.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		n    int
+		s    string
+		want string
+	}{
+		{10, "short", "short"},
+		{5, "exact", "exact"},
+		{6, "a longer line", "a lon…"},
+		// spaces before the cut are dropped
+		{3, "ab cd", "ab…"},
+		// characters, not bytes
+		{4, "héllo wörld", "hél…"},
+		{0, "anything", "anything"},
+	}
+
+	for _, test := range tests {
+		if got := truncate(test.n, test.s); got != test.want {
+			t.Errorf("truncate %d %q is %q, want %q", test.n, test.s, got, test.want)
+		}
+	}
+}
+
+func TestGroupByTag(t *testing.T) {
+	music := tag.ExportTag{Id: 1, Name: "music", TaggedAt: time.Now()}
+	cooking := tag.ExportTag{Id: 2, Name: "Cooking"}
+	food := tag.ExportTag{Id: 3, Name: "food"}
+
+	groups := groupByTag([]channel.ExportChannel{
+		exportChannel("UC1", "Chef John", 0, cooking, food),
+		exportChannel("UC2", "Nobody", 0),
+		exportChannel("UC3", "Synth Guy", 0, music),
+		exportChannel("UC4", "Speedy", 0, food),
+	})
+
+	var got []string
+	for _, g := range groups {
+		got = append(got, g.Tag.Name+": "+strings.Join(names(g.Channels), ", "))
+		if !g.Tag.TaggedAt.IsZero() {
+			t.Errorf("group %s has TaggedAt %s, want it zero", g.Tag.Name, g.Tag.TaggedAt)
+		}
+	}
+	want := []string{"Cooking: Chef John", "food: Chef John, Speedy", "music: Synth Guy", ": Nobody"}
+	if !slices.Equal(got, want) {
+		t.Errorf("groups are %q, want %q", got, want)
+	}
+}
@@ -13,55 +13,393 @@ You should have received a copy of the GNU General Public License along with thi
 package generator
 
 import (
+	"fmt"
 	"html/template"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/markdown"
+	"repo.joyrex.net/ejstacey/ysm/query"
 	"repo.joyrex.net/ejstacey/ysm/tag"
 	"repo.joyrex.net/ejstacey/ysm/utils"
 )
 
 type Generator struct {
-	Channels         []channel.ExportChannel
-	Tags             []tag.ExportTag
-	Title            string
-	OutputFile       string
-	TemplateFile     string
+	Channels []channel.ExportChannel
+	// Tags is every tag in tree order, TagTree is the top level tags with
+	// their sub-tags in Children.
+	Tags         []tag.ExportTag
+	TagTree      []tag.ExportTag
+	Title        string
+	OutputFile   string
+	TemplateFile string
+	// Format is what to write, one of Formats. The template is only used
+	// for html and site.
+	Format string
+	// AssetDir is the css and javascript copied into a site.
+	AssetDir         string
 	GenerateDateTime string
+	// Query, if set before LoadEntries, limits the output to the channels
+	// matching it.
+	Query query.Query
+	// ShownTags, if set before LoadEntries, are the only tags listed, see
+	// ShownTags. The rest are left off the channels, and tagged channels
+	// without any of the shown tags are left out.
+	ShownTags map[int64]bool
+	// pageTags are the tags whose site pages list each channel, by channel
+	// id: its tags and the ones above them, including unlisted ones that
+	// are left off Tags.
+	pageTags map[string]map[int64]bool
 }
 
 var t *template.Template
 
-func (g Generator) LoadTemplateFile() {
+// ShownTags returns the tags to list when only show are wanted: show and
+// their sub-tags, which are true, and the tags above them, which are false
+// since they're only there to place them in the tree. It's nil if show is
+// empty, when every tag is listed.
+func ShownTags(tags tag.Tags, show []string) (map[int64]bool, error) {
+	if len(show) == 0 {
+		return nil, nil
+	}
+
+	shown := make(map[int64]bool)
+	for _, name := range show {
+		tagInfo, ok := tags.Find(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("no tag named %q", name)
+		}
+		shown[tagInfo.Id()] = true
+		for _, id := range tags.Descendants(tagInfo.Id()) {
+			shown[id] = true
+		}
+		for _, id := range tags.Ancestors(tagInfo.Id()) {
+			if _, ok := shown[id]; !ok {
+				shown[id] = false
+			}
+		}
+	}
+
+	return shown, nil
+}
+
+// HiddenTags returns the tags in hide, which are left out along with their
+// channels on top of the private ones.
+func HiddenTags(tags tag.Tags, hide []string) (map[int64]int, error) {
+	hiddenTags := make(map[int64]int)
+	for _, name := range hide {
+		tagInfo, ok := tags.Find(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("no tag named %q", name)
+		}
+		hiddenTags[tagInfo.Id()] = 1
+	}
+
+	return hiddenTags, nil
+}
+
+// FromProfile sets up a generator for a profile, returning it with the tags
+// to leave out, ready for LoadEntries.
+func FromProfile(p utils.GeneratorProfile, tags tag.Tags) (Generator, map[int64]int, error) {
+	var err error
+
+	gen := Generator{
+		Title:        p.Title,
+		OutputFile:   p.OutputFile,
+		TemplateFile: p.TemplateFile,
+		AssetDir:     p.AssetDir,
+	}
+
+	gen.Format, err = FormatFor(p.Format, p.OutputFile)
+	if err != nil {
+		return Generator{}, nil, err
+	}
+
+	gen.Query, err = query.Parse(p.Query, tags)
+	if err != nil {
+		return Generator{}, nil, fmt.Errorf("bad query: %w", err)
+	}
+
+	gen.ShownTags, err = ShownTags(tags, p.ShowTags)
+	if err != nil {
+		return Generator{}, nil, err
+	}
+
+	hiddenTags, err := HiddenTags(tags, p.HideTags)
+	if err != nil {
+		return Generator{}, nil, err
+	}
+
+	return gen, hiddenTags, nil
+}
+
+// LoadEntries fills in the channels and tags to export. Unsubscribed channels
+// and channels tagged with any of the hidden tags or not matching the query
+// are left out, as are the hidden tags themselves. Private tags are always
+// hidden. Unlisted tags are left out, but their channels aren't, and so are
+// the tags that aren't in ShownTags. Hiding or unlisting a tag does the same
+// to its sub-tags.
+func (g *Generator) LoadEntries(channels channel.Channels, tags tag.Tags, hiddenTags map[int64]int) {
+	var unlistedTags = make(map[int64]int)
+	for _, tagInfo := range tags.ById() {
+		switch tagInfo.Visibility() {
+		case tag.VisibilityPrivate:
+			hiddenTags[tagInfo.Id()] = 1
+		case tag.VisibilityUnlisted:
+			unlistedTags[tagInfo.Id()] = 1
+		}
+		if _, ok := g.ShownTags[tagInfo.Id()]; g.ShownTags != nil && !ok {
+			unlistedTags[tagInfo.Id()] = 1
+		}
+	}
+	for _, tagId := range slices.Collect(maps.Keys(hiddenTags)) {
+		for _, descendant := range tags.Descendants(tagId) {
+			hiddenTags[descendant] = 1
+		}
+	}
+	for _, tagId := range slices.Collect(maps.Keys(unlistedTags)) {
+		for _, descendant := range tags.Descendants(tagId) {
+			unlistedTags[descendant] = 1
+		}
+	}
+	// unlisted tags are hidden from the tag list either way
+	for tagId := range hiddenTags {
+		delete(unlistedTags, tagId)
+	}
+
+	pages := channelPages(channels)
+	g.pageTags = make(map[string]map[int64]bool)
+	genChannels := make([]channel.ExportChannel, 0, len(channels.ByName()))
+	for _, chanInfo := range channels.ByName() {
+		if chanInfo.Unsubscribed() || !g.Query.Match(chanInfo) {
+			continue
+		}
+
+		tmpChan := channel.ExportChannel{
+			Id:            chanInfo.Id(),
+			Name:          chanInfo.Name(),
+			Url:           chanInfo.Url(),
+			FeedUrl:       chanInfo.FeedUrl(),
+			Description:   chanInfo.Description(),
+			Page:          pages[chanInfo.Id()],
+			Notes:         chanInfo.Notes(),
+			NotesHTML:     markdown.ToHTML(chanInfo.Notes()),
+			Details:       chanInfo.Details(),
+			LatestVideo:   chanInfo.LatestVideo(),
+			FeedCheckedAt: chanInfo.FeedCheckedAt(),
+		}
+		var tmpTags = make(map[string]tag.ExportTag)
+		var includeTag bool = true
+		var hasShownTag bool
+
+		for _, tagId := range chanInfo.Tags() {
+			// don't include the channels tagged with hidden tags
+			_, ok := hiddenTags[tagId]
+			if ok {
+				includeTag = false
+				break
+			}
+			if g.ShownTags[tagId] {
+				hasShownTag = true
+			}
+			if _, ok := unlistedTags[tagId]; ok {
+				continue
+			}
+
+			tagInfo := tags.ById()[tagId]
+
+			tmpTag := tag.ExportTag{
+				Id:          tagInfo.Id(),
+				Name:        tagInfo.Name(),
+				Description: tagInfo.Description(),
+				FgColour:    tagInfo.FgColour(),
+				BgColour:    tagInfo.BgColour(),
+				ParentId:    tagInfo.ParentId(),
+				Depth:       tags.Depth(tagInfo.Id()),
+				Ancestors:   tags.Ancestors(tagInfo.Id()),
+				Page:        tagPage(tagInfo.Id()),
+				TaggedAt:    chanInfo.TaggedAt(tagId),
+			}
+			tmpTags[tmpTag.Name] = tmpTag
+			if tmpTag.TaggedAt.After(tmpChan.TaggedAt) {
+				tmpChan.TaggedAt = tmpTag.TaggedAt
+			}
+		}
+		if !includeTag {
+			continue
+		}
+		// untagged channels are still included when only some tags are shown
+		if g.ShownTags != nil && len(chanInfo.Tags()) != 0 && !hasShownTag {
+			continue
+		}
+		sortedTags := slices.Sorted(maps.Keys(tmpTags))
+		for _, tmpTag := range sortedTags {
+			tmpChan.Tags = append(tmpChan.Tags, tmpTags[tmpTag])
+		}
+
+		pageTags := make(map[int64]bool)
+		for _, tagId := range chanInfo.Tags() {
+			pageTags[tagId] = true
+			for _, ancestor := range tags.Ancestors(tagId) {
+				pageTags[ancestor] = true
+			}
+		}
+		g.pageTags[chanInfo.Id()] = pageTags
+
+		genChannels = append(genChannels, tmpChan)
+	}
+
+	var genTags []tag.ExportTag
+	var genTagTree []tag.ExportTag
+	var exportTag func(tagInfo tag.Tag) tag.ExportTag
+	exportTag = func(tagInfo tag.Tag) tag.ExportTag {
+		tmpTag := tag.ExportTag{
+			Id:          tagInfo.Id(),
+			Name:        tagInfo.Name(),
+			Description: tagInfo.Description(),
+			FgColour:    tagInfo.FgColour(),
+			BgColour:    tagInfo.BgColour(),
+			ParentId:    tagInfo.ParentId(),
+			Depth:       tags.Depth(tagInfo.Id()),
+			Ancestors:   tags.Ancestors(tagInfo.Id()),
+			Page:        tagPage(tagInfo.Id()),
+		}
+		genTags = append(genTags, tmpTag)
+
+		for _, child := range tags.Children(tagInfo.Id()) {
+			if _, ok := hiddenTags[child.Id()]; ok {
+				continue
+			}
+			if _, ok := unlistedTags[child.Id()]; ok {
+				continue
+			}
+			tmpTag.Children = append(tmpTag.Children, exportTag(child))
+		}
+		return tmpTag
+	}
+	for _, tagInfo := range tags.Tree() {
+		if _, ok := hiddenTags[tagInfo.Id()]; ok {
+			continue
+		}
+		if _, ok := unlistedTags[tagInfo.Id()]; ok {
+			continue
+		}
+		// sub-tags are done along with their parent
+		if tagInfo.ParentId() != 0 && tags.ById()[tagInfo.ParentId()].Id() != 0 {
+			continue
+		}
+		genTagTree = append(genTagTree, exportTag(tagInfo))
+	}
+
+	slices.SortFunc(genChannels, func(a, b channel.ExportChannel) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+
+	g.Channels = genChannels
+	g.Tags = genTags
+	g.TagTree = genTagTree
+}
+
+// LoadTemplateFile reads and parses the template, if the format uses one.
+// For a site, it's every template in the site template directory. The other
+// .tmpl files next to the template are loaded too, so it can use them with
+// {{template "file.tmpl" .}} or any templates they {{define}}. The
+// template's own definitions win over theirs.
+func (g Generator) LoadTemplateFile() error {
+	if g.Format == FormatSite {
+		return g.loadSiteTemplates()
+	}
+	if g.Format != FormatHtml && g.Format != "" {
+		return nil
+	}
+
 	input, err := os.ReadFile(g.TemplateFile)
-	utils.HandleError(err, "Unable to open template.")
+	if err != nil {
+		return err
+	}
+
+	t = template.New("default").Funcs(Funcs)
+
+	others, err := filepath.Glob(filepath.Join(filepath.Dir(g.TemplateFile), "*.tmpl"))
+	if err != nil {
+		return err
+	}
+	for _, other := range others {
+		if same, err := sameFile(other, g.TemplateFile); err != nil || same {
+			continue
+		}
+
+		otherInput, err := os.ReadFile(other)
+		if err != nil {
+			return err
+		}
+		_, err = t.New(filepath.Base(other)).Parse(string(otherInput))
+		if err != nil {
+			return err
+		}
+	}
 
-	t = template.Must(template.New("default").Parse(string(input)))
+	_, err = t.Parse(string(input))
+	return err
 }
 
-func (g Generator) GenerateOutputFile() {
+func sameFile(a string, b string) (bool, error) {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+
+	return os.SameFile(aInfo, bInfo), nil
+}
+
+func (g Generator) GenerateOutputFile() (err error) {
+	g.GenerateDateTime = time.Now().Format(time.UnixDate)
+
+	if g.Format == FormatSite {
+		return g.writeSite()
+	}
+
 	dir := filepath.Dir(g.OutputFile)
 	result, err := utils.FileDirExists(dir)
-	utils.HandleError(err, "Checking for existence of output directory.")
+	if err != nil {
+		return err
+	}
 	if !result {
 		os.MkdirAll(dir, 0755)
 	}
 
 	fo, err := os.Create(g.OutputFile)
-	utils.HandleError(err, "Unable to open output file.")
+	if err != nil {
+		return err
+	}
 
 	// close fo on exit and check for its returned error
 	defer func() {
-		err := fo.Close()
-		utils.HandleError(err, "Unable to close output file.")
+		closeErr := fo.Close()
+		if err == nil {
+			err = closeErr
+		}
 	}()
 
-	g.GenerateDateTime = time.Now().Format(time.UnixDate)
-
-	err = t.Execute(fo, g)
-	if err != nil {
-		panic(err)
+	switch g.Format {
+	case FormatJson:
+		return g.writeJson(fo)
+	case FormatCsv:
+		return g.writeCsv(fo)
+	case FormatMarkdown:
+		return g.writeMarkdown(fo)
+	case FormatAtom:
+		return g.writeAtom(fo)
 	}
+
+	return t.Execute(fo, g)
 }
@@ -0,0 +1,285 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"html/template"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	cp "github.com/otiai10/copy"
+	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/tag"
+)
+
+// sitePages are the templates a site needs, each rendered with a sitePage.
+// Any other templates in the directory are there for them to use.
+var sitePages = []string{"index.tmpl", "tag.tmpl", "channel.tmpl"}
+
+// siteAssetDir is where the css and javascript go in a site.
+const siteAssetDir = "assets"
+
+// siteManifest lists the tag and channel pages written by the last run,
+// one per line, next to the index. Those are the only pages that are ever
+// removed, so anything else put in the same directories is left alone.
+const siteManifest = ".ysm-pages"
+
+// sitePage is what each page of a site is rendered with. The whole site's
+// channels and tags are there too, for menus and the like.
+type sitePage struct {
+	Generator
+	// Heading is the page's own title, the same as Title on the index.
+	Heading string
+	// Root is the way back to the top of the site from the page, "" on the
+	// index and "../" everywhere else. Pages and asset paths are relative to
+	// the top.
+	Root string
+	// Tag, with its sub-tags in Children, and TagChannels, the channels
+	// tagged with it or one of its sub-tags, are only set on tag pages.
+	Tag         tag.ExportTag
+	TagChannels []channel.ExportChannel
+	// Channel is only set on channel pages.
+	Channel channel.ExportChannel
+}
+
+var unsafePageName = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+func tagPage(id int64) string {
+	return fmt.Sprintf("tags/%d.html", id)
+}
+
+// channelPages names each channel's page after its id. Youtube ids are safe
+// as file names, but ones from other sources might not be, so those get a
+// hash of the id on the end to tell apart ids like "a.b" and "a_b". So does
+// an id whose page is already taken, which also covers ids that only differ
+// in case on a case insensitive file system. The ids are gone through in
+// order, so pages keep their names from run to run.
+func channelPages(channels channel.Channels) map[string]string {
+	var pages = make(map[string]string)
+	var taken = make(map[string]bool)
+	for _, id := range slices.Sorted(maps.Keys(channels.ById())) {
+		name := unsafePageName.ReplaceAllString(id, "_")
+		if name != id || taken[strings.ToLower(name)] {
+			name = fmt.Sprintf("%s-%08x", name, crc32.ChecksumIEEE([]byte(id)))
+		}
+		taken[strings.ToLower(name)] = true
+		pages[id] = "channels/" + name + ".html"
+	}
+
+	return pages
+}
+
+// siteTemplateDir is TemplateFile if it's a directory, otherwise the "site"
+// directory next to it, so the usual template setting finds the site
+// templates that come with ysm.
+func (g Generator) siteTemplateDir() string {
+	info, err := os.Stat(g.TemplateFile)
+	if err == nil && info.IsDir() {
+		return g.TemplateFile
+	}
+
+	return filepath.Join(filepath.Dir(g.TemplateFile), "site")
+}
+
+func (g Generator) loadSiteTemplates() error {
+	dir := g.siteTemplateDir()
+
+	var err error
+	t, err = template.New("site").Funcs(Funcs).ParseGlob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return fmt.Errorf("site templates in %s: %w", dir, err)
+	}
+
+	for _, name := range sitePages {
+		if t.Lookup(name) == nil {
+			return fmt.Errorf("site templates in %s: there's no %s", dir, name)
+		}
+	}
+
+	return nil
+}
+
+// writeSite writes the index to OutputFile, with a page for every tag and
+// channel under the same directory and the assets copied in. Pages the last
+// run wrote, for tags and channels that have gone or are now hidden, are
+// removed.
+func (g Generator) writeSite() error {
+	root := filepath.Dir(g.OutputFile)
+
+	var written []string
+	err := writeSitePage(g.OutputFile, "index.tmpl", sitePage{Generator: g, Heading: g.Title})
+	if err != nil {
+		return err
+	}
+
+	// the tree has the tags with their sub-tags filled in. Unlisted tags
+	// aren't in it, so they don't get a page, but their channels are still
+	// on the pages of the tags above them.
+	var walk func(tags []tag.ExportTag) error
+	walk = func(tags []tag.ExportTag) error {
+		for _, tagInfo := range tags {
+			page := sitePage{Generator: g, Heading: tagInfo.Name, Root: "../", Tag: tagInfo}
+			for _, chanInfo := range g.Channels {
+				if g.pageTags[chanInfo.Id][tagInfo.Id] {
+					page.TagChannels = append(page.TagChannels, chanInfo)
+				}
+			}
+
+			file := filepath.Join(root, filepath.FromSlash(tagInfo.Page))
+			err := writeSitePage(file, "tag.tmpl", page)
+			if err != nil {
+				return err
+			}
+			written = append(written, tagInfo.Page)
+
+			err = walk(tagInfo.Children)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err = walk(g.TagTree)
+	if err != nil {
+		return err
+	}
+
+	for _, chanInfo := range g.Channels {
+		file := filepath.Join(root, filepath.FromSlash(chanInfo.Page))
+		err = writeSitePage(file, "channel.tmpl", sitePage{Generator: g, Heading: chanInfo.Name, Root: "../", Channel: chanInfo})
+		if err != nil {
+			return err
+		}
+		written = append(written, chanInfo.Page)
+	}
+
+	err = removeStalePages(root, written)
+	if err != nil {
+		return err
+	}
+
+	return g.copySiteAssets(filepath.Join(root, siteAssetDir))
+}
+
+func writeSitePage(file string, name string, page sitePage) (err error) {
+	err = os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return err
+	}
+
+	fo, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	// close fo on exit and check for its returned error
+	defer func() {
+		closeErr := fo.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	err = t.ExecuteTemplate(fo, name, page)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	return nil
+}
+
+// removeStalePages removes the pages in the site's manifest that weren't
+// just written, so a channel that's been hidden since the last run doesn't
+// keep its page, then lists the written ones in the manifest for next time.
+// Without a manifest nothing is removed.
+func removeStalePages(root string, written []string) error {
+	manifest := filepath.Join(root, siteManifest)
+	data, err := os.ReadFile(manifest)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	for _, page := range strings.Split(string(data), "\n") {
+		page = strings.TrimSpace(page)
+		if page == "" || slices.Contains(written, page) {
+			continue
+		}
+		// only ever a page the generator could have written
+		dir, name := path.Split(path.Clean(page))
+		if (dir != "tags/" && dir != "channels/") || path.Ext(name) != ".html" {
+			continue
+		}
+		err = os.Remove(filepath.Join(root, filepath.FromSlash(dir), name))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return os.WriteFile(manifest, []byte(strings.Join(written, "\n")+"\n"), 0644)
+}
+
+// copySiteAssets copies AssetDir into dest. The asset directory is usually
+// where the other generated files go too, and may even hold the site, so
+// anything the generator could have written is left out.
+func (g Generator) copySiteAssets(dest string) error {
+	if g.AssetDir == "" {
+		return nil
+	}
+
+	site, err := filepath.Abs(filepath.Dir(dest))
+	if err != nil {
+		return err
+	}
+	assetDir, err := filepath.Abs(g.AssetDir)
+	if err != nil {
+		return err
+	}
+
+	var skipDirs []string
+	for _, dir := range []string{siteAssetDir, "tags", "channels"} {
+		skipDirs = append(skipDirs, filepath.Join(site, dir))
+	}
+	if site != assetDir {
+		skipDirs = append(skipDirs, site)
+	}
+
+	return cp.Copy(g.AssetDir, dest, cp.Options{
+		Skip: func(info os.FileInfo, src string, _ string) (bool, error) {
+			abs, err := filepath.Abs(src)
+			if err != nil {
+				return false, err
+			}
+			if info.IsDir() {
+				return slices.Contains(skipDirs, abs), nil
+			}
+			if info.Name() == siteManifest {
+				return true, nil
+			}
+
+			switch strings.ToLower(filepath.Ext(src)) {
+			case ".html", ".json", ".csv", ".md", ".markdown", ".atom", ".xml":
+				return true, nil
+			}
+			return false, nil
+		},
+	})
+}
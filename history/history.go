@@ -0,0 +1,695 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+// Package history keeps a journal of the edits made to tags, notes and tag
+// links, so they can be undone, redone and reviewed.
+//
+// Each entry in the journal holds the rows an edit changed, as they were
+// before and after it. Undoing an entry puts the rows back how they were
+// before, as long as they haven't been changed again since.
+package history
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+// maxEntries is how many entries are kept, older ones are dropped.
+const maxEntries = 1000
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+	ErrConflict      = errors.New("changed since")
+)
+
+// the tables edits are journaled for, anything else in an entry is refused
+var tables = map[string]bool{
+	"channels":        true,
+	"tags":            true,
+	"links":           true,
+	"rules":           true,
+	"rule_exceptions": true,
+	"tag_aliases":     true,
+}
+
+var columnRegexp = regexp.MustCompile(`^[A-Za-z]+$`)
+
+// Change is one row changed by an edit. Before is nil if the row was added
+// and After is nil if it was deleted. Otherwise they only hold the columns
+// that changed.
+type Change struct {
+	Table  string         `json:"table"`
+	Key    map[string]any `json:"key"`
+	Before map[string]any `json:"before"`
+	After  map[string]any `json:"after"`
+}
+
+func (c Change) String() string {
+	var key = formatRow(c.Key)
+	// the key columns are already shown
+	withoutKey := func(row map[string]any) map[string]any {
+		row = maps.Clone(row)
+		for column := range c.Key {
+			delete(row, column)
+		}
+		return row
+	}
+	switch {
+	case c.Before == nil:
+		return strings.TrimSpace(fmt.Sprintf("added to %s: %s %s", c.Table, key, formatRow(withoutKey(c.After))))
+	case c.After == nil:
+		return strings.TrimSpace(fmt.Sprintf("deleted from %s: %s %s", c.Table, key, formatRow(withoutKey(c.Before))))
+	}
+
+	var parts []string
+	for _, column := range slices.Sorted(maps.Keys(c.After)) {
+		parts = append(parts, fmt.Sprintf("%s %s -> %s", column, formatValue(c.Before[column]), formatValue(c.After[column])))
+	}
+	return fmt.Sprintf("changed in %s: %s %s", c.Table, key, strings.Join(parts, ", "))
+}
+
+type Entry struct {
+	Id          int64
+	At          time.Time
+	Description string
+	Changes     []Change
+	Undone      bool
+}
+
+// txTimeout is how long an edit, or a group of them, has to be recorded in.
+const txTimeout = 30 * time.Second
+
+// group is the entry being built between Begin and End, and groupTx the
+// transaction its edits share, started by the first one.
+var group *Entry
+var groupDepth int
+var groupTx *sql.Tx
+var groupCancel context.CancelFunc
+
+// Begin starts grouping everything recorded until the matching End into one
+// entry, so it's undone in one go. Begins can be nested, only the outermost
+// description is used.
+func Begin(description string) {
+	if groupDepth == 0 {
+		group = &Entry{Description: description}
+	}
+	groupDepth++
+}
+
+// End finishes the group started by Begin, and commits its edits along with
+// the entry for them.
+func End() error {
+	if groupDepth == 0 {
+		return nil
+	}
+	groupDepth--
+	if groupDepth > 0 {
+		return nil
+	}
+
+	g, tx, txCancel := group, groupTx, groupCancel
+	group, groupTx, groupCancel = nil, nil, nil
+	if tx == nil {
+		return nil
+	}
+	defer txCancel()
+	defer tx.Rollback()
+
+	if len(g.Changes) != 0 {
+		encoded, err := json.Marshal(g.Changes)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err = insertEntry(ctx, tx, g.Description, encoded)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Watcher snapshots rows before an edit, and records how they changed after
+// it. The edit is made through Tx, so it's committed along with its entry in
+// the journal, or not at all. Rows are recorded in the order they were
+// watched, which is the order they're redone in, and undone in reverse, so
+// watch rows that others depend on last.
+type Watcher struct {
+	tx     *sql.Tx
+	cancel context.CancelFunc
+	// grouped edits share the group's transaction, each in a savepoint
+	grouped bool
+	done    bool
+	rows    []watchedRow
+}
+
+type watchedRow struct {
+	table  string
+	key    map[string]any
+	before map[string]any
+}
+
+// Watch starts an edit. Close should be deferred straight after, to throw
+// the edit away if it isn't recorded.
+func Watch() (*Watcher, error) {
+	if group != nil {
+		if groupTx == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), txTimeout)
+			tx, err := utils.DbConn.BeginTx(ctx, nil)
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			groupTx, groupCancel = tx, cancel
+		}
+
+		_, err := groupTx.Exec("savepoint watch")
+		if err != nil {
+			return nil, err
+		}
+		return &Watcher{tx: groupTx, grouped: true}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), txTimeout)
+	tx, err := utils.DbConn.BeginTx(ctx, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &Watcher{tx: tx, cancel: cancel}, nil
+}
+
+// Tx is the transaction the edit has to be made in.
+func (w *Watcher) Tx() *sql.Tx { return w.tx }
+
+// Close rolls the edit back if it hasn't been recorded.
+func (w *Watcher) Close() {
+	if w.done {
+		return
+	}
+	w.done = true
+
+	if w.grouped {
+		w.tx.Exec("rollback to watch")
+		w.tx.Exec("release watch")
+		return
+	}
+	w.tx.Rollback()
+	w.cancel()
+}
+
+// Row snapshots the row with the key, which doesn't have to exist yet.
+func (w *Watcher) Row(table string, key map[string]any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	before, err := snapshot(ctx, w.tx, table, key)
+	if err != nil {
+		return err
+	}
+	w.rows = append(w.rows, watchedRow{table: table, key: key, before: before})
+	return nil
+}
+
+// Inserted watches a row that has just been added, so it had no snapshot.
+func (w *Watcher) Inserted(table string, key map[string]any) {
+	w.rows = append(w.rows, watchedRow{table: table, key: key})
+}
+
+// Rows snapshots every row matching where, identifying them by keyColumns.
+func (w *Watcher) Rows(table string, keyColumns []string, where string, args ...any) error {
+	if err := checkTable(table); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := w.tx.QueryContext(ctx, "select * from "+table+" where "+where, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row, err := scanRow(rows)
+		if err != nil {
+			return err
+		}
+		var key = make(map[string]any)
+		for _, column := range keyColumns {
+			key[column] = row[column]
+		}
+		w.rows = append(w.rows, watchedRow{table: table, key: key, before: row})
+	}
+
+	return rows.Err()
+}
+
+// Record snapshots the watched rows again, journals the ones that changed
+// and commits the edit. Nothing is journaled if nothing changed. Within a
+// group, the changes are added to the group's entry and committed by End.
+func (w *Watcher) Record(description string) error {
+	if w.done {
+		return errors.New("edit already recorded or thrown away")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var changes []Change
+	for _, watched := range w.rows {
+		after, err := snapshot(ctx, w.tx, watched.table, watched.key)
+		if err != nil {
+			return err
+		}
+
+		before, after, changed := diffRows(watched.before, after)
+		if changed {
+			changes = append(changes, Change{Table: watched.table, Key: watched.key, Before: before, After: after})
+		}
+	}
+	w.rows = nil
+
+	if w.grouped {
+		_, err := w.tx.ExecContext(ctx, "release watch")
+		if err != nil {
+			return err
+		}
+		w.done = true
+		group.Changes = append(group.Changes, changes...)
+		return nil
+	}
+
+	if len(changes) != 0 {
+		encoded, err := json.Marshal(changes)
+		if err != nil {
+			return err
+		}
+		err = insertEntry(ctx, w.tx, description, encoded)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := w.tx.Commit()
+	if err != nil {
+		return err
+	}
+	w.done = true
+	w.cancel()
+	return nil
+}
+
+// insertEntry adds an entry to the journal. Anything that was undone can't
+// be redone after that.
+func insertEntry(ctx context.Context, tx *sql.Tx, description string, changes []byte) error {
+	_, err := tx.ExecContext(ctx, "delete from history where undone = 1")
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, "insert into history (at, description, changes) values (:at, :description, :changes)", time.Now().UTC().Format(time.RFC3339), description, string(changes))
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "delete from history where id <= :id", id-maxEntries)
+	return err
+}
+
+// List returns up to limit entries, newest first, including undone ones.
+func List(limit int) ([]Entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := utils.DbConn.QueryContext(ctx, "select id, at, description, changes, undone from history order by id desc limit :limit", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// Get returns the entry with the id.
+func Get(id int64) (Entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	row := utils.DbConn.QueryRowContext(ctx, "select id, at, description, changes, undone from history where id = :id", id)
+	entry, err := scanEntry(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entry, fmt.Errorf("no history entry %d", id)
+	}
+	return entry, err
+}
+
+// Undo puts back the rows changed by the newest entry that hasn't been
+// undone, and returns it.
+func Undo() (Entry, error) {
+	return step("select id, at, description, changes, undone from history where undone = 0 order by id desc limit 1", true, ErrNothingToUndo)
+}
+
+// Redo makes the changes of the oldest undone entry again, and returns it.
+func Redo() (Entry, error) {
+	return step("select id, at, description, changes, undone from history where undone = 1 order by id limit 1", false, ErrNothingToRedo)
+}
+
+func step(selectSql string, undo bool, none error) (Entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := utils.DbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer tx.Rollback()
+
+	entry, err := scanEntry(tx.QueryRowContext(ctx, selectSql))
+	if errors.Is(err, sql.ErrNoRows) {
+		return entry, none
+	}
+	if err != nil {
+		return entry, err
+	}
+
+	if undo {
+		err = applyChanges(ctx, tx, inverse(entry.Changes), false)
+	} else {
+		err = applyChanges(ctx, tx, entry.Changes, false)
+	}
+	if err != nil {
+		return entry, err
+	}
+
+	_, err = tx.ExecContext(ctx, "update history set undone = :undone where id = :id", undo, entry.Id)
+	if err != nil {
+		return entry, err
+	}
+	entry.Undone = undo
+
+	return entry, tx.Commit()
+}
+
+// Revert puts back the rows changed by any entry that hasn't been undone, as
+// a new entry, so the revert can be undone too. If the rows have been
+// changed again since, it fails with ErrConflict unless force is set.
+func Revert(id int64, force bool) (Entry, error) {
+	entry, err := Get(id)
+	if err != nil {
+		return entry, err
+	}
+	if entry.Undone {
+		return entry, fmt.Errorf("history entry %d has been undone already", id)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := utils.DbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return entry, err
+	}
+	defer tx.Rollback()
+
+	changes := inverse(entry.Changes)
+	err = applyChanges(ctx, tx, changes, force)
+	if err != nil {
+		return entry, err
+	}
+
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return entry, err
+	}
+	err = insertEntry(ctx, tx, fmt.Sprintf("revert %d: %s", entry.Id, entry.Description), encoded)
+	if err != nil {
+		return entry, err
+	}
+
+	return entry, tx.Commit()
+}
+
+// inverse returns the changes that undo changes.
+func inverse(changes []Change) []Change {
+	var inverted []Change
+	for _, c := range slices.Backward(changes) {
+		inverted = append(inverted, Change{Table: c.Table, Key: c.Key, Before: c.After, After: c.Before})
+	}
+	return inverted
+}
+
+// applyChanges takes each changed row from Before to After, checking it's
+// still how Before says unless force is set.
+func applyChanges(ctx context.Context, tx *sql.Tx, changes []Change, force bool) error {
+	for _, c := range changes {
+		err := checkTable(c.Table)
+		if err != nil {
+			return err
+		}
+		for _, row := range []map[string]any{c.Key, c.Before, c.After} {
+			for column := range row {
+				if !columnRegexp.MatchString(column) {
+					return fmt.Errorf("bad column name %q in history", column)
+				}
+			}
+		}
+
+		current, err := snapshot(ctx, tx, c.Table, c.Key)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case c.After == nil && current == nil:
+			// already gone, which is all deleting it would do
+			continue
+		case c.Before == nil && current != nil && !force:
+			return fmt.Errorf("%s %s was added %w", c.Table, formatRow(c.Key), ErrConflict)
+		case c.Before != nil && current == nil:
+			return fmt.Errorf("%s %s was deleted %w", c.Table, formatRow(c.Key), ErrConflict)
+		case c.Before != nil && !force:
+			for column, value := range c.Before {
+				if formatValue(value) != formatValue(current[column]) {
+					return fmt.Errorf("%s %s %s has %w", c.Table, formatRow(c.Key), column, ErrConflict)
+				}
+			}
+		}
+
+		where, whereArgs := keyWhere(c.Key)
+		switch {
+		case c.After == nil:
+			_, err = tx.ExecContext(ctx, "delete from "+c.Table+" where "+where, whereArgs...)
+		case current == nil:
+			var row = maps.Clone(c.After)
+			maps.Copy(row, c.Key)
+			columns := slices.Sorted(maps.Keys(row))
+			var args []any
+			for _, column := range columns {
+				args = append(args, sqlValue(row[column]))
+			}
+			_, err = tx.ExecContext(ctx, fmt.Sprintf("insert into %s (%s) values (%s)", c.Table, strings.Join(columns, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")), args...)
+		default:
+			var sets []string
+			var args []any
+			for _, column := range slices.Sorted(maps.Keys(c.After)) {
+				sets = append(sets, column+" = ?")
+				args = append(args, sqlValue(c.After[column]))
+			}
+			if len(sets) == 0 {
+				continue
+			}
+			_, err = tx.ExecContext(ctx, "update "+c.Table+" set "+strings.Join(sets, ", ")+" where "+where, append(args, whereArgs...)...)
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", c.Table, formatRow(c.Key), err)
+		}
+	}
+
+	return nil
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// snapshot returns the row with the key, or nil if there isn't one.
+func snapshot(ctx context.Context, q querier, table string, key map[string]any) (map[string]any, error) {
+	if err := checkTable(table); err != nil {
+		return nil, err
+	}
+
+	where, args := keyWhere(key)
+	rows, err := q.QueryContext(ctx, "select * from "+table+" where "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	return scanRow(rows)
+}
+
+func scanRow(rows *sql.Rows) (map[string]any, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	err = rows.Scan(pointers...)
+	if err != nil {
+		return nil, err
+	}
+
+	var row = make(map[string]any)
+	for i, column := range columns {
+		if b, ok := values[i].([]byte); ok {
+			values[i] = string(b)
+		}
+		// the driver turns text that looks like a time into one, which
+		// wouldn't compare equal to the text kept in the journal
+		if t, ok := values[i].(time.Time); ok {
+			values[i] = t.Format(time.RFC3339Nano)
+		}
+		row[column] = values[i]
+	}
+	return row, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEntry(row scanner) (Entry, error) {
+	var entry Entry
+	var at, changes string
+	err := row.Scan(&entry.Id, &at, &entry.Description, &changes, &entry.Undone)
+	if err != nil {
+		return entry, err
+	}
+
+	entry.At, err = time.Parse(time.RFC3339, at)
+	if err != nil {
+		return entry, err
+	}
+
+	dec := json.NewDecoder(strings.NewReader(changes))
+	dec.UseNumber()
+	err = dec.Decode(&entry.Changes)
+	return entry, err
+}
+
+// diffRows returns the columns that differ between before and after, or the
+// whole rows if one of them doesn't exist.
+func diffRows(before map[string]any, after map[string]any) (map[string]any, map[string]any, bool) {
+	if before == nil || after == nil {
+		return before, after, before != nil || after != nil
+	}
+
+	var changedBefore = make(map[string]any)
+	var changedAfter = make(map[string]any)
+	for column, value := range after {
+		if formatValue(before[column]) != formatValue(value) {
+			changedBefore[column] = before[column]
+			changedAfter[column] = value
+		}
+	}
+
+	return changedBefore, changedAfter, len(changedAfter) > 0
+}
+
+func keyWhere(key map[string]any) (string, []any) {
+	var parts []string
+	var args []any
+	for _, column := range slices.Sorted(maps.Keys(key)) {
+		parts = append(parts, column+" = ?")
+		args = append(args, sqlValue(key[column]))
+	}
+	return strings.Join(parts, " and "), args
+}
+
+func checkTable(table string) error {
+	if !tables[table] {
+		return fmt.Errorf("history doesn't know about table %q", table)
+	}
+	return nil
+}
+
+// sqlValue turns numbers read back from the journal's json into something
+// the db takes.
+func sqlValue(value any) any {
+	if n, ok := value.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i
+		}
+		if f, err := n.Float64(); err == nil {
+			return f
+		}
+		return n.String()
+	}
+	return value
+}
+
+func formatValue(value any) string {
+	switch v := sqlValue(value).(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("%q", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func formatRow(row map[string]any) string {
+	var parts []string
+	for _, column := range slices.Sorted(maps.Keys(row)) {
+		parts = append(parts, column+"="+formatValue(row[column]))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,152 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package history
+
+import (
+	"path/filepath"
+	"testing"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+// openTestDb opens a fresh db with a channel to edit.
+func openTestDb(t *testing.T) {
+	t.Helper()
+
+	utils.InitDb(filepath.Join(t.TempDir(), "ysm.db"), 1)
+	t.Cleanup(func() {
+		utils.DbConn.Close()
+		utils.DbConn = nil
+	})
+
+	_, err := utils.DbConn.Exec("insert into channels (id, name, notes) values ('UC1', 'Alpha', 'old')")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setNotes edits the channel's notes through a watcher, recording it unless
+// record is false.
+func setNotes(t *testing.T, notes string, record bool) {
+	t.Helper()
+
+	w, err := Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	err = w.Row("channels", map[string]any{"id": "UC1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Tx().Exec("update channels set notes = ? where id = 'UC1'", notes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record {
+		err = w.Record("set notes")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func notes(t *testing.T) string {
+	t.Helper()
+
+	var notes string
+	err := utils.DbConn.QueryRow("select notes from channels where id = 'UC1'").Scan(&notes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return notes
+}
+
+func entries(t *testing.T) []Entry {
+	t.Helper()
+
+	entries, err := List(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestRecordCommitsEditWithEntry(t *testing.T) {
+	openTestDb(t)
+
+	setNotes(t, "new", true)
+	if got := notes(t); got != "new" {
+		t.Fatalf("notes are %q, want new", got)
+	}
+	if got := entries(t); len(got) != 1 || len(got[0].Changes) != 1 {
+		t.Fatalf("journal has %v, want one entry with one change", got)
+	}
+
+	_, err := Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := notes(t); got != "old" {
+		t.Errorf("notes are %q after undo, want old", got)
+	}
+}
+
+func TestCloseThrowsAwayUnrecordedEdit(t *testing.T) {
+	openTestDb(t)
+
+	setNotes(t, "new", false)
+	if got := notes(t); got != "old" {
+		t.Errorf("notes are %q, want the edit rolled back", got)
+	}
+	if got := entries(t); len(got) != 0 {
+		t.Errorf("journal has %v, want nothing", got)
+	}
+}
+
+func TestGroupCommitsAtEnd(t *testing.T) {
+	openTestDb(t)
+
+	Begin("edit channel")
+	setNotes(t, "first", true)
+	setNotes(t, "thrown away", false)
+	setNotes(t, "second", true)
+
+	// nothing is committed until End
+	if got := notes(t); got != "old" {
+		t.Errorf("notes are %q before End, want old", got)
+	}
+
+	err := End()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := notes(t); got != "second" {
+		t.Errorf("notes are %q, want second", got)
+	}
+	got := entries(t)
+	if len(got) != 1 || got[0].Description != "edit channel" || len(got[0].Changes) != 2 {
+		t.Fatalf("journal has %v, want one edit channel entry with two changes", got)
+	}
+
+	_, err = Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := notes(t); got != "old" {
+		t.Errorf("notes are %q after undo, want old", got)
+	}
+}
@@ -15,10 +15,13 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
 
 	_ "github.com/ncruces/go-sqlite3/driver"
 	_ "github.com/ncruces/go-sqlite3/embed"
 	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/cli"
+	"repo.joyrex.net/ejstacey/ysm/rule"
 	"repo.joyrex.net/ejstacey/ysm/tag"
 	"repo.joyrex.net/ejstacey/ysm/tui"
 	"repo.joyrex.net/ejstacey/ysm/utils"
@@ -26,9 +29,14 @@ import (
 
 func main() {
 	var installFlag = flag.Bool("install", false, "install the package files into their expected locations of your user environment")
+	flag.Usage = func() {
+		cli.Run([]string{"help"})
+	}
 	flag.Parse()
 	if *installFlag {
 		utils.InstallApp()
+	} else if flag.NArg() > 0 {
+		os.Exit(cli.Run(flag.Args()))
 	} else {
 		var channels channel.Channels
 		var tags tag.Tags
@@ -40,22 +48,39 @@ func main() {
 
 		utils.BackupDbFile(settings.DbFile, settings.BackupCopies)
 
-		utils.InitDb(settings.DbFile)
+		utils.InitDb(settings.DbFile, settings.BackupCopies)
 
 		fmt.Printf("Loading existing DB channel entries.\n")
 		channels.LoadEntriesFromDb()
 
-		if settings.Refresh || len(channels.ById()) == 0 {
-			fmt.Printf("No existing DB entries found or Refresh was set to True in settings.json. Loading a fresh list from YouTube.\n\n")
-			var newChannels = channel.LoadChannelsYoutube()
-			channels.CompareAndUpdateChannelsDb(newChannels)
-			channels.LoadEntriesFromDb()
+		src, err := channel.NewSource(settings)
+		utils.HandleError(err, "Unable to set up the subscription source")
+
+		var syncChanges channel.Changeset
+		if settings.Refresh.Due(channel.LastSync(src)) || len(channels.ById()) == 0 {
+			fmt.Printf("No existing DB entries found or a refresh is due (Refresh is %s in settings.json). Loading a fresh list from %s.\n\n", settings.Refresh, src.Name())
+			list, err := channel.LoadChannels(src, settings.MaxSubscriptions, os.Stdout)
+			utils.HandleError(err, "Unable to load the subscription list")
+			// nothing worth reviewing on the first load, or if there are no
+			// changes, but applying records the sync
+			if settings.ReviewSync && len(channels.ById()) != 0 {
+				syncChanges = channels.Diff(list)
+			}
+			if len(syncChanges.Changes) == 0 {
+				channels.CompareAndUpdateChannelsDb(list)
+				matches, err := rule.ApplyAll()
+				utils.HandleError(err, "Unable to apply the auto-tagging rules")
+				if len(matches) > 0 {
+					fmt.Printf("Auto-tagging rules added %d tags.\n", len(matches))
+				}
+				channels.LoadEntriesFromDb()
+			}
 		}
 
 		fmt.Printf("Loading existing DB tag entries.\n")
 		tags.LoadEntriesFromDb()
 
-		tui.StartTea(channels, tags, settings)
+		tui.StartTea(channels, tags, settings, syncChanges)
 	}
 }
 
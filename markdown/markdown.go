@@ -0,0 +1,230 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+// Package markdown renders channel notes, which are written in markdown, as
+// html for the generated page and as styled text for the terminal.
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+)
+
+// notes are usually written a line at a time, so a line break in them is kept
+// as one instead of being joined up with the next line like in a paragraph.
+// Raw html in notes is left out, not passed through.
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(html.WithHardWraps()),
+)
+
+var (
+	headingStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#5dade2"))
+	boldStyle    = lipgloss.NewStyle().Bold(true)
+	italicStyle  = lipgloss.NewStyle().Italic(true)
+	strikeStyle  = lipgloss.NewStyle().Strikethrough(true)
+	codeStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#F1C40F"))
+	linkStyle    = lipgloss.NewStyle().Underline(true).Foreground(lipgloss.Color("#5dade2"))
+	quoteStyle   = lipgloss.NewStyle().Faint(true)
+)
+
+// ToHTML renders source as html.
+func ToHTML(source string) template.HTML {
+	var buf bytes.Buffer
+	err := md.Convert([]byte(source), &buf)
+	if err != nil {
+		// goldmark only fails if writing to buf does, but just in case,
+		// the notes are still better shown as they are than not at all
+		return template.HTML(template.HTMLEscapeString(source))
+	}
+
+	return template.HTML(strings.TrimSpace(buf.String()))
+}
+
+// ToTerminal renders source as styled text wrapped to width.
+func ToTerminal(source string, width int) string {
+	src := []byte(source)
+	doc := md.Parser().Parse(text.NewReader(src))
+
+	r := terminalRenderer{source: src}
+	return r.blocks(doc, max(width, 10))
+}
+
+type terminalRenderer struct {
+	source []byte
+}
+
+// blocks renders the children of n, which are all blocks, with a blank line
+// between them.
+func (r terminalRenderer) blocks(n ast.Node, width int) string {
+	var out []string
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		out = append(out, r.block(child, width))
+	}
+
+	return strings.Join(out, "\n\n")
+}
+
+func (r terminalRenderer) block(n ast.Node, width int) string {
+	switch n := n.(type) {
+	case *ast.Heading:
+		return ansi.Wordwrap(headingStyle.Render(strings.Repeat("#", n.Level)+" "+r.inlines(n)), width, "")
+
+	case *ast.Paragraph, *ast.TextBlock:
+		return ansi.Wordwrap(r.inlines(n), width, "")
+
+	case *ast.List:
+		return r.list(n, width)
+
+	case *ast.Blockquote:
+		lines := strings.Split(r.blocks(n, width-2), "\n")
+		for i := range lines {
+			lines[i] = quoteStyle.Render("│ ") + lines[i]
+		}
+		return strings.Join(lines, "\n")
+
+	case *ast.FencedCodeBlock, *ast.CodeBlock, *ast.HTMLBlock:
+		var lines []string
+		for i := 0; i < n.Lines().Len(); i++ {
+			line := n.Lines().At(i)
+			lines = append(lines, "  "+codeStyle.Render(strings.TrimRight(string(line.Value(r.source)), "\n")))
+		}
+		return strings.Join(lines, "\n")
+
+	case *ast.ThematicBreak:
+		return quoteStyle.Render(strings.Repeat("─", width))
+
+	case *extast.Table:
+		var rows []string
+		for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+			var cells []string
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				cells = append(cells, r.inlines(cell))
+			}
+			line := strings.Join(cells, quoteStyle.Render(" │ "))
+			if _, ok := row.(*extast.TableHeader); ok {
+				line = boldStyle.Render(line)
+			}
+			rows = append(rows, line)
+		}
+		return strings.Join(rows, "\n")
+	}
+
+	return r.blocks(n, width)
+}
+
+// list renders a list, with each item's lines after its first indented to
+// line up with the text after the bullet or number.
+func (r terminalRenderer) list(n *ast.List, width int) string {
+	var items []string
+	number := n.Start
+	for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+		bullet := "• "
+		if n.IsOrdered() {
+			bullet = fmt.Sprintf("%d%c ", number, n.Marker)
+			number++
+		}
+		indent := strings.Repeat(" ", ansi.StringWidth(bullet))
+
+		lines := strings.Split(r.blocks(item, width-len(indent)), "\n")
+		for i := range lines {
+			if i == 0 {
+				lines[i] = bullet + lines[i]
+			} else if lines[i] != "" {
+				lines[i] = indent + lines[i]
+			}
+		}
+		items = append(items, strings.Join(lines, "\n"))
+	}
+
+	if n.IsTight {
+		return strings.Join(items, "\n")
+	}
+	return strings.Join(items, "\n\n")
+}
+
+// inlines renders the inline children of n.
+func (r terminalRenderer) inlines(n ast.Node) string {
+	var b strings.Builder
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		b.WriteString(r.inline(child))
+	}
+
+	return b.String()
+}
+
+func (r terminalRenderer) inline(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Text:
+		s := string(n.Value(r.source))
+		if n.SoftLineBreak() || n.HardLineBreak() {
+			s += "\n"
+		}
+		return s
+
+	case *ast.String:
+		return string(n.Value)
+
+	case *ast.CodeSpan:
+		return codeStyle.Render(r.inlines(n))
+
+	case *ast.Emphasis:
+		if n.Level == 2 {
+			return boldStyle.Render(r.inlines(n))
+		}
+		return italicStyle.Render(r.inlines(n))
+
+	case *extast.Strikethrough:
+		return strikeStyle.Render(r.inlines(n))
+
+	case *ast.Link:
+		label := r.inlines(n)
+		url := string(n.Destination)
+		if ansi.Strip(label) == url {
+			return linkStyle.Render(url)
+		}
+		return label + " (" + linkStyle.Render(url) + ")"
+
+	case *ast.AutoLink:
+		return linkStyle.Render(string(n.URL(r.source)))
+
+	case *ast.Image:
+		return "[image: " + r.inlines(n) + "]"
+
+	case *extast.TaskCheckBox:
+		if n.IsChecked {
+			return "[x] "
+		}
+		return "[ ] "
+
+	case *ast.RawHTML:
+		var b strings.Builder
+		for i := 0; i < n.Segments.Len(); i++ {
+			segment := n.Segments.At(i)
+			b.Write(segment.Value(r.source))
+		}
+		return b.String()
+	}
+
+	return r.inlines(n)
+}
@@ -0,0 +1,236 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+// Package opml reads and writes channel lists as OPML, the format feed readers
+// use for their subscription lists. Every youtube channel has an rss feed of
+// its uploads, so each channel is an rss outline pointing at that feed.
+package opml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/tag"
+)
+
+type document struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    head     `xml:"head"`
+	Body    body     `xml:"body"`
+}
+
+type head struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type body struct {
+	Outlines []outline `xml:"outline"`
+}
+
+type outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XmlUrl   string    `xml:"xmlUrl,attr,omitempty"`
+	HtmlUrl  string    `xml:"htmlUrl,attr,omitempty"`
+	Outlines []outline `xml:"outline"`
+}
+
+// Export writes the subscribed channels as OPML, with a folder outline for
+// each tag holding the channels with that tag, and the folders of its
+// sub-tags. A channel with several tags is in each of their folders. If
+// onlyTags isn't empty, only the folders for those tags (and their sub-tags)
+// are written; otherwise untagged channels go at the top level after the
+// folders.
+func Export(w io.Writer, channels channel.Channels, tags tag.Tags, onlyTags map[int64]bool, title string) error {
+	var byTag = make(map[int64][]channel.Channel)
+	var untagged []channel.Channel
+	for _, name := range slices.Sorted(maps.Keys(channels.ByName())) {
+		chanInfo := channels.ByName()[name]
+		if chanInfo.Unsubscribed() {
+			continue
+		}
+
+		if len(chanInfo.Tags()) == 0 {
+			untagged = append(untagged, chanInfo)
+		}
+		for _, tagId := range chanInfo.Tags() {
+			byTag[tagId] = append(byTag[tagId], chanInfo)
+		}
+	}
+
+	var doc = document{
+		Version: "2.0",
+		Head: head{
+			Title:       title,
+			DateCreated: time.Now().Format(time.RFC1123Z),
+		},
+	}
+
+	var folder func(tagInfo tag.Tag) (outline, bool)
+	folder = func(tagInfo tag.Tag) (outline, bool) {
+		var o = outline{Text: tagInfo.Name(), Title: tagInfo.Name()}
+		for _, child := range tags.Children(tagInfo.Id()) {
+			if childFolder, ok := folder(child); ok {
+				o.Outlines = append(o.Outlines, childFolder)
+			}
+		}
+		for _, chanInfo := range byTag[tagInfo.Id()] {
+			o.Outlines = append(o.Outlines, channelOutline(chanInfo))
+		}
+		return o, len(o.Outlines) != 0
+	}
+
+	for _, tagInfo := range tags.Tree() {
+		if len(onlyTags) != 0 {
+			// start from the chosen tags that aren't already under another
+			// chosen tag
+			if !onlyTags[tagInfo.Id()] || slices.ContainsFunc(tags.Ancestors(tagInfo.Id()), func(id int64) bool { return onlyTags[id] }) {
+				continue
+			}
+		} else if tagInfo.ParentId() != 0 && tags.ById()[tagInfo.ParentId()].Id() != 0 {
+			continue
+		}
+
+		if o, ok := folder(tagInfo); ok {
+			doc.Body.Outlines = append(doc.Body.Outlines, o)
+		}
+	}
+
+	if len(onlyTags) == 0 {
+		for _, chanInfo := range untagged {
+			doc.Body.Outlines = append(doc.Body.Outlines, channelOutline(chanInfo))
+		}
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(doc)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+func channelOutline(chanInfo channel.Channel) outline {
+	return outline{
+		Text:    chanInfo.Name(),
+		Title:   chanInfo.Name(),
+		Type:    "rss",
+		XmlUrl:  chanInfo.FeedUrl(),
+		HtmlUrl: chanInfo.Url(),
+	}
+}
+
+// Read returns the youtube channels in an OPML file, wherever they are in the
+// outline tree. Channels are recognised by a feed url with a channel_id, or
+// failing that a /channel/ page url. The number of rss outlines that aren't
+// youtube channels (or are, but only by user name) is returned too.
+func Read(r io.Reader) ([]channel.Channel, int, error) {
+	var doc document
+	err := xml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return nil, 0, err
+	}
+	if doc.XMLName.Local != "opml" {
+		return nil, 0, errors.New("not an opml file")
+	}
+
+	var channels []channel.Channel
+	var seen = make(map[string]bool)
+	var skipped = 0
+
+	var walk func(outlines []outline)
+	walk = func(outlines []outline) {
+		for _, o := range outlines {
+			walk(o.Outlines)
+
+			if o.XmlUrl == "" && o.HtmlUrl == "" {
+				continue
+			}
+
+			id := channelId(o)
+			if id == "" {
+				skipped++
+				continue
+			}
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			name := o.Title
+			if name == "" {
+				name = o.Text
+			}
+			channels = append(channels, channel.NewChannel(id, name, ""))
+		}
+	}
+	walk(doc.Body.Outlines)
+
+	return channels, skipped, nil
+}
+
+func channelId(o outline) string {
+	if feed, err := url.Parse(o.XmlUrl); err == nil && isYoutubeHost(feed.Host) {
+		if id := feed.Query().Get("channel_id"); id != "" {
+			return id
+		}
+	}
+
+	if page, err := url.Parse(o.HtmlUrl); err == nil && isYoutubeHost(page.Host) {
+		if _, id, found := strings.Cut(page.Path, "/channel/"); found {
+			id, _, _ = strings.Cut(id, "/")
+			return id
+		}
+	}
+
+	return ""
+}
+
+func isYoutubeHost(host string) bool {
+	host = strings.ToLower(host)
+	return host == "youtube.com" || strings.HasSuffix(host, ".youtube.com")
+}
+
+// ReadFile is Read for a named file.
+func ReadFile(path string) ([]channel.Channel, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	channels, skipped, err := Read(f)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	return channels, skipped, nil
+}
@@ -0,0 +1,417 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+// Package query parses and evaluates the channel query language, eg
+//
+//	tag:music AND NOT tag:hidden OR notes:"watch later"
+//
+// A term is a bare word or "quoted phrase", which matches the channel's name,
+// description or notes, or field:value, where field is one of tag, name,
+// desc (or description), notes, is or quiet. Terms are combined with AND, OR, NOT
+// and parentheses. NOT binds tightest, then AND, then OR, and terms next to
+// each other are ANDed together. Matching ignores case.
+package query
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/tag"
+)
+
+// Query is a parsed query. The zero Query matches every channel.
+type Query struct {
+	text string
+	root node
+}
+
+// Parse parses text into a query. Tag names are looked up in tags when the
+// query is parsed, so it needs parsing again if the tags change. An empty
+// text gives a query that matches everything.
+func Parse(text string, tags tag.Tags) (Query, error) {
+	tokens, err := lex(text)
+	if err != nil {
+		return Query{}, err
+	}
+	if len(tokens) == 0 {
+		return Query{}, nil
+	}
+
+	p := parser{tokens: tokens, tags: tags}
+	root, err := p.parseOr()
+	if err != nil {
+		return Query{}, err
+	}
+	if p.pos < len(p.tokens) {
+		return Query{}, p.errorf("unexpected %s", p.tokens[p.pos])
+	}
+
+	return Query{text: strings.TrimSpace(text), root: root}, nil
+}
+
+func (q Query) Empty() bool    { return q.root == nil }
+func (q Query) String() string { return q.text }
+
+// Match says whether the channel matches the query.
+func (q Query) Match(c channel.Channel) bool {
+	if q.root == nil {
+		return true
+	}
+	return q.root.match(c)
+}
+
+type node interface {
+	match(c channel.Channel) bool
+}
+
+type andNode struct{ left, right node }
+type orNode struct{ left, right node }
+type notNode struct{ inner node }
+
+func (n andNode) match(c channel.Channel) bool { return n.left.match(c) && n.right.match(c) }
+func (n orNode) match(c channel.Channel) bool  { return n.left.match(c) || n.right.match(c) }
+func (n notNode) match(c channel.Channel) bool { return !n.inner.match(c) }
+
+// textNode matches if any of the fields contain the value.
+type textNode struct {
+	value  string
+	fields []func(c channel.Channel) string
+}
+
+func (n textNode) match(c channel.Channel) bool {
+	for _, field := range n.fields {
+		if strings.Contains(strings.ToLower(field(c)), n.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// tagNode matches channels with any of the tags, which are a tag and all its
+// sub-tags.
+type tagNode struct {
+	tagIds map[int64]bool
+}
+
+func (n tagNode) match(c channel.Channel) bool {
+	return slices.ContainsFunc(c.Tags(), func(id int64) bool { return n.tagIds[id] })
+}
+
+type isNode struct {
+	test func(c channel.Channel) bool
+}
+
+func (n isNode) match(c channel.Channel) bool { return n.test(c) }
+
+// quietNode matches channels whose feed has been checked and that haven't
+// uploaded since since.
+type quietNode struct {
+	since time.Time
+}
+
+func (n quietNode) match(c channel.Channel) bool { return c.Quiet(n.since) }
+
+var (
+	nameField        = func(c channel.Channel) string { return c.Name() }
+	descriptionField = func(c channel.Channel) string { return c.Description() }
+	notesField       = func(c channel.Channel) string { return c.Notes() }
+)
+
+var textFields = map[string][]func(c channel.Channel) string{
+	"":            {nameField, descriptionField, notesField},
+	"name":        {nameField},
+	"desc":        {descriptionField},
+	"description": {descriptionField},
+	"notes":       {notesField},
+}
+
+var isTests = map[string]func(c channel.Channel) bool{
+	"tagged":       func(c channel.Channel) bool { return len(c.Tags()) != 0 },
+	"untagged":     func(c channel.Channel) bool { return len(c.Tags()) == 0 },
+	"subscribed":   func(c channel.Channel) bool { return !c.Unsubscribed() },
+	"unsubscribed": func(c channel.Channel) bool { return c.Unsubscribed() },
+	"archived":     func(c channel.Channel) bool { return c.Archived() },
+	"noted":        func(c channel.Channel) bool { return c.Notes() != "" },
+}
+
+type tokenKind int
+
+const (
+	tokenTerm tokenKind = iota
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind  tokenKind
+	pos   int
+	field string
+	value string
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	case tokenOpen:
+		return "'('"
+	case tokenClose:
+		return "')'"
+	}
+	if t.field != "" {
+		return fmt.Sprintf("%s:%q", t.field, t.value)
+	}
+	return fmt.Sprintf("%q", t.value)
+}
+
+// lex splits text into tokens. AND, OR and NOT are keywords in any case
+// unless they're quoted.
+func lex(text string) ([]token, error) {
+	var tokens []token
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenOpen, pos: i + 1})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenClose, pos: i + 1})
+			i++
+		default:
+			start := i
+			var field string
+			var word strings.Builder
+			var quoted bool
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' {
+				if runes[i] == '"' {
+					value, next, err := lexQuoted(runes, i)
+					if err != nil {
+						return nil, err
+					}
+					word.WriteString(value)
+					quoted = true
+					i = next
+					continue
+				}
+				if runes[i] == ':' && field == "" && !quoted {
+					field = strings.ToLower(word.String())
+					word.Reset()
+					i++
+					if field == "" {
+						return nil, fmt.Errorf("position %d: missing field name before ':'", i)
+					}
+					continue
+				}
+				word.WriteRune(runes[i])
+				i++
+			}
+
+			t := token{kind: tokenTerm, pos: start + 1, field: field, value: word.String()}
+			if field == "" && !quoted {
+				switch strings.ToUpper(t.value) {
+				case "AND":
+					t.kind = tokenAnd
+				case "OR":
+					t.kind = tokenOr
+				case "NOT":
+					t.kind = tokenNot
+				}
+			}
+			if t.kind == tokenTerm && t.value == "" && !quoted {
+				return nil, fmt.Errorf("position %d: missing value after %s:", start+1, field)
+			}
+			tokens = append(tokens, t)
+		}
+	}
+
+	return tokens, nil
+}
+
+// lexQuoted reads the quoted string starting at runes[start], which is the
+// opening quote. A \ escapes the next character. It returns the string and
+// the position after the closing quote.
+func lexQuoted(runes []rune, start int) (string, int, error) {
+	var b strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				b.WriteRune(runes[i])
+			}
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteRune(runes[i])
+		}
+	}
+	return "", 0, fmt.Errorf("position %d: unterminated quote", start+1)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	tags   tag.Tags
+}
+
+func (p *parser) errorf(format string, a ...any) error {
+	if p.pos >= len(p.tokens) {
+		return fmt.Errorf("end of query: "+format, a...)
+	}
+	return fmt.Errorf("position %d: "+format, append([]any{p.tokens[p.pos].pos}, a...)...)
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != tokenOr {
+			return left, nil
+		}
+		p.pos++
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t, ok := p.peek()
+		if !ok {
+			return left, nil
+		}
+		switch t.kind {
+		case tokenAnd:
+			p.pos++
+		case tokenTerm, tokenNot, tokenOpen:
+			// terms next to each other are ANDed
+		default:
+			return left, nil
+		}
+
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left: left, right: right}
+	}
+}
+
+func (p *parser) parseNot() (node, error) {
+	t, ok := p.peek()
+	if ok && t.kind == tokenNot {
+		p.pos++
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner: inner}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, p.errorf("expected a search term")
+	}
+
+	switch t.kind {
+	case tokenOpen:
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		t, ok = p.peek()
+		if !ok || t.kind != tokenClose {
+			return nil, p.errorf("expected ')'")
+		}
+		p.pos++
+		return inner, nil
+	case tokenTerm:
+		n, err := p.term(t)
+		if err != nil {
+			return nil, err
+		}
+		p.pos++
+		return n, nil
+	}
+
+	return nil, p.errorf("expected a search term, not %s", t)
+}
+
+func (p *parser) term(t token) (node, error) {
+	if fields, ok := textFields[t.field]; ok {
+		return textNode{value: strings.ToLower(t.value), fields: fields}, nil
+	}
+
+	switch t.field {
+	case "tag":
+		tagInfo, ok := p.tags.Find(t.value)
+		if !ok {
+			return nil, p.errorf("no tag named %q", t.value)
+		}
+		return tagNode{tagIds: p.tags.WithDescendants(tagInfo.Id())}, nil
+	case "is":
+		test, ok := isTests[strings.ToLower(t.value)]
+		if !ok {
+			return nil, p.errorf("unknown is:%s, it must be one of tagged, untagged, subscribed, unsubscribed, archived or noted", t.value)
+		}
+		return isNode{test: test}, nil
+	case "quiet":
+		months, err := strconv.Atoi(t.value)
+		if err != nil || months < 1 {
+			return nil, p.errorf("quiet:%s must be a number of months", t.value)
+		}
+		return quietNode{since: channel.QuietSince(months)}, nil
+	}
+
+	return nil, p.errorf("unknown field %q, it must be one of tag, name, desc, notes, is or quiet", t.field)
+}
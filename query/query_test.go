@@ -0,0 +1,222 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package query
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/tag"
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+// openTestDb opens a fresh db with these tags and channels:
+//
+//	music > synthwave, gaming
+//	UC1 Alpha      "retro synths"  tagged synthwave, noted "watch later"
+//	UC2 Beta       "speedruns"     tagged gaming
+//	UC3 Gamma      "and or not"    untagged
+func openTestDb(t *testing.T) (channel.Channels, tag.Tags) {
+	t.Helper()
+
+	utils.InitDb(filepath.Join(t.TempDir(), "ysm.db"), 1)
+	t.Cleanup(func() {
+		utils.DbConn.Close()
+		utils.DbConn = nil
+	})
+
+	newTag := func(name string, parentId int64) int64 {
+		var tagInfo tag.Tag
+		err := tagInfo.New()
+		if err == nil {
+			err = tagInfo.SetName(name)
+		}
+		if err == nil && parentId != 0 {
+			err = tagInfo.SetParentId(parentId)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tagInfo.Id()
+	}
+	music := newTag("music", 0)
+	synthwave := newTag("synthwave", music)
+	gaming := newTag("gaming", 0)
+
+	var channels channel.Channels
+	channels.LoadEntriesFromDb()
+	cs := channels.Diff(channel.SubscriptionList{Channels: []channel.Channel{
+		channel.NewChannel("UC1", "Alpha", "retro synths"),
+		channel.NewChannel("UC2", "Beta", "speedruns"),
+		channel.NewChannel("UC3", "Gamma", "and or not"),
+	}})
+	cs.AcceptAll()
+	err := channels.ApplyChanges(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alpha := channels.ById()["UC1"]
+	err = alpha.SetTags([]int64{synthwave})
+	if err == nil {
+		err = alpha.SetNotes("Watch later")
+	}
+	if err == nil {
+		beta := channels.ById()["UC2"]
+		err = beta.SetTags([]int64{gaming})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	channels.LoadEntriesFromDb()
+	var tags tag.Tags
+	tags.LoadEntriesFromDb()
+
+	return channels, tags
+}
+
+// matching returns the names of the channels the query matches, sorted.
+func matching(t *testing.T, text string, channels channel.Channels, tags tag.Tags) []string {
+	t.Helper()
+
+	q, err := Parse(text, tags)
+	if err != nil {
+		t.Fatalf("%s: %v", text, err)
+	}
+
+	var names []string
+	for _, chanInfo := range channels.ById() {
+		if q.Match(chanInfo) {
+			names = append(names, chanInfo.Name())
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestMatch(t *testing.T) {
+	channels, tags := openTestDb(t)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Alpha", "Beta", "Gamma"}},
+		// a tag matches its sub-tags, but not the other way round
+		{"tag:music", []string{"Alpha"}},
+		{"tag:synthwave", []string{"Alpha"}},
+		{"tag:GAMING", []string{"Beta"}},
+		{"name:alp", []string{"Alpha"}},
+		{"desc:speed", []string{"Beta"}},
+		{"description:speed", []string{"Beta"}},
+		{"notes:watch", []string{"Alpha"}},
+		{"retro", []string{"Alpha"}},
+		{"is:tagged", []string{"Alpha", "Beta"}},
+		{"is:untagged", []string{"Gamma"}},
+		{"is:noted", []string{"Alpha"}},
+		{"is:subscribed", []string{"Alpha", "Beta", "Gamma"}},
+		{"is:unsubscribed", nil},
+		{"is:archived", nil},
+		// NOT goes first, then AND, then OR
+		{"tag:music OR tag:gaming AND is:untagged", []string{"Alpha"}},
+		{"(tag:music OR tag:gaming) AND is:tagged", []string{"Alpha", "Beta"}},
+		{"NOT tag:music AND NOT is:untagged", []string{"Beta"}},
+		{"NOT (tag:music OR tag:gaming)", []string{"Gamma"}},
+		{"NOT NOT tag:gaming", []string{"Beta"}},
+		{"tag:music or tag:gaming", []string{"Alpha", "Beta"}},
+		// terms next to each other are ANDed
+		{"is:tagged speed", []string{"Beta"}},
+		{"is:tagged NOT speed", []string{"Alpha"}},
+		// quoted phrases, and keywords that are quoted to search for them
+		{`notes:"watch later"`, []string{"Alpha"}},
+		{`"watch later"`, []string{"Alpha"}},
+		{`"retro synths" OR "speedruns"`, []string{"Alpha", "Beta"}},
+		{`"and"`, []string{"Gamma"}},
+		{`desc:"or" "not"`, []string{"Gamma"}},
+		{`"escaped \"quote\""`, nil},
+	}
+
+	for _, test := range tests {
+		got := matching(t, test.query, channels, tags)
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%s: matched %q, want %q", test.query, got, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	_, tags := openTestDb(t)
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"colour:red", `unknown field "colour"`},
+		{"tag:nonexistent", `no tag named "nonexistent"`},
+		{"is:weird", "unknown is:weird"},
+		{`notes:"watch later`, "unterminated quote"},
+		{"(tag:music", "expected ')'"},
+		{"tag:music)", "unexpected ')'"},
+		{"tag:music AND", "end of query: expected a search term"},
+		{"OR tag:music", "expected a search term, not OR"},
+		{":music", "missing field name"},
+		{"tag:", "missing value after tag:"},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.query, tags)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %v, want one containing %q", test.query, err, test.want)
+		}
+	}
+}
+
+func TestIsArchived(t *testing.T) {
+	channels, tags := openTestDb(t)
+
+	_, err := channel.Bulk([]string{"UC2"}, channel.BulkArchive, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	channels.LoadEntriesFromDb()
+
+	// gone from youtube, so unsubscribed but not archived
+	cs := channels.Diff(channel.SubscriptionList{Channels: []channel.Channel{
+		channel.NewChannel("UC2", "Beta", "speedruns"),
+		channel.NewChannel("UC3", "Gamma", "and or not"),
+	}})
+	cs.AcceptAll()
+	err = channels.ApplyChanges(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	channels.LoadEntriesFromDb()
+
+	for query, want := range map[string][]string{
+		"is:archived":                         {"Beta"},
+		"is:unsubscribed":                     {"Alpha", "Beta"},
+		"is:unsubscribed AND NOT is:archived": {"Alpha"},
+		"is:subscribed":                       {"Gamma"},
+	} {
+		got := matching(t, query, channels, tags)
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: matched %q, want %q", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rule
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+// Field is the part of a channel a rule looks at.
+type Field string
+
+const (
+	FieldName        Field = "name"
+	FieldDescription Field = "description"
+	FieldAny         Field = "any"
+)
+
+// MatchType is how a rule's pattern is matched against the field.
+type MatchType string
+
+const (
+	// MatchKeyword matches if the field contains the pattern, ignoring case.
+	MatchKeyword MatchType = "keyword"
+	// MatchRegex matches if the pattern, a go regular expression, matches
+	// anywhere in the field.
+	MatchRegex MatchType = "regex"
+)
+
+// Rule tags any channel whose name and/or description matches its pattern.
+type Rule struct {
+	id        int64
+	tagId     int64
+	field     Field
+	matchType MatchType
+	pattern   string
+	regex     *regexp.Regexp
+}
+
+func (r Rule) Id() int64            { return r.id }
+func (r Rule) TagId() int64         { return r.tagId }
+func (r Rule) Field() Field         { return r.field }
+func (r Rule) MatchType() MatchType { return r.matchType }
+func (r Rule) Pattern() string      { return r.pattern }
+
+func (r Rule) String() string {
+	return fmt.Sprintf("%s %s %q", r.field, r.matchType, r.pattern)
+}
+
+// New checks the parts of a rule and returns it, without saving it.
+func New(tagId int64, field Field, matchType MatchType, pattern string) (Rule, error) {
+	var r = Rule{tagId: tagId, field: field, matchType: matchType, pattern: pattern}
+
+	err := r.compile()
+	if err != nil {
+		return Rule{}, err
+	}
+
+	return r, nil
+}
+
+func (r *Rule) compile() error {
+	switch r.field {
+	case FieldName, FieldDescription, FieldAny:
+	default:
+		return fmt.Errorf("unknown rule field %q, it must be name, description or any", r.field)
+	}
+
+	if strings.TrimSpace(r.pattern) == "" {
+		return fmt.Errorf("rule pattern can't be empty")
+	}
+
+	switch r.matchType {
+	case MatchKeyword:
+		r.regex = nil
+	case MatchRegex:
+		regex, err := regexp.Compile(r.pattern)
+		if err != nil {
+			return fmt.Errorf("invalid regex %q: %w", r.pattern, err)
+		}
+		r.regex = regex
+	default:
+		return fmt.Errorf("unknown rule match type %q, it must be keyword or regex", r.matchType)
+	}
+
+	return nil
+}
+
+// Save adds the rule to the db.
+func (r *Rule) Save() error {
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var insertSql = "insert into rules (tagId, field, matchType, pattern, createdAt) values (:tagId, :field, :matchType, :pattern, :createdAt)"
+
+	res, err := utils.DbConn.ExecContext(ctx, insertSql, r.tagId, string(r.field), string(r.matchType), r.pattern, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+
+	r.id, err = res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes the rule. Links it has already made are left alone.
+func (r Rule) Delete() error {
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := utils.DbConn.ExecContext(ctx, "delete from rules where id = :id", r.id)
+	return err
+}
+
+// Matches says whether the rule would tag the channel.
+func (r Rule) Matches(c channel.Channel) bool {
+	var values []string
+	switch r.field {
+	case FieldName:
+		values = []string{c.Name()}
+	case FieldDescription:
+		values = []string{c.Description()}
+	case FieldAny:
+		values = []string{c.Name(), c.Description()}
+	}
+
+	for _, value := range values {
+		if r.regex != nil {
+			if r.regex.MatchString(value) {
+				return true
+			}
+		} else if strings.Contains(strings.ToLower(value), strings.ToLower(r.pattern)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Rules struct {
+	rules []Rule
+}
+
+func (rs Rules) All() []Rule { return rs.rules }
+
+func (rs Rules) ById(id int64) (Rule, bool) {
+	for _, r := range rs.rules {
+		if r.id == id {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+func (rs *Rules) LoadEntriesFromDb() {
+	err := rs.Load()
+	utils.HandleError(err, "Loading existing rules from db")
+}
+
+// Load replaces rs with the rules in the db. Unlike LoadEntriesFromDb it
+// returns an error rather than exiting when they can't be read.
+func (rs *Rules) Load() error {
+	rs.rules = nil
+
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var rulesText = "select id, tagId, field, matchType, pattern from rules order by id"
+
+	rows, err := utils.DbConn.QueryContext(ctx, rulesText)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var r Rule
+		var field, matchType string
+
+		err = rows.Scan(&r.id, &r.tagId, &field, &matchType, &r.pattern)
+		if err != nil {
+			return err
+		}
+
+		r.field = Field(field)
+		r.matchType = MatchType(matchType)
+		err = r.compile()
+		if err != nil {
+			// a broken rule shouldn't stop everything else from loading
+			fmt.Fprintf(os.Stderr, "Skipping rule %d: %v\n", r.id, err)
+			continue
+		}
+
+		rs.rules = append(rs.rules, r)
+	}
+
+	return rows.Err()
+}
+
+// Match is a tag that rules would add to a channel.
+type Match struct {
+	Channel channel.Channel
+	TagId   int64
+	RuleIds []int64
+}
+
+// Preview returns the links the rules would add to the channels. Channels
+// that already have the tag, unsubscribed channels and links that were
+// removed by hand after a rule made them are left out. If ruleIds are
+// given, only those rules are used.
+func (rs Rules) Preview(channels channel.Channels, ruleIds ...int64) ([]Match, error) {
+	exceptions, err := loadExceptions()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Match
+	var found = make(map[string]int)
+	for _, c := range channels.ById() {
+		if c.Unsubscribed() {
+			continue
+		}
+
+		for _, r := range rs.rules {
+			if len(ruleIds) > 0 && !slices.Contains(ruleIds, r.id) {
+				continue
+			}
+			if slices.Contains(c.Tags(), r.tagId) || exceptions[exceptionKey(c.Id(), r.tagId)] {
+				continue
+			}
+			if !r.Matches(c) {
+				continue
+			}
+
+			key := exceptionKey(c.Id(), r.tagId)
+			if i, ok := found[key]; ok {
+				matches[i].RuleIds = append(matches[i].RuleIds, r.id)
+				continue
+			}
+			found[key] = len(matches)
+			matches = append(matches, Match{Channel: c, TagId: r.tagId, RuleIds: []int64{r.id}})
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b Match) int {
+		if c := strings.Compare(strings.ToLower(a.Channel.Name()), strings.ToLower(b.Channel.Name())); c != 0 {
+			return c
+		}
+		return int(a.TagId - b.TagId)
+	})
+
+	return matches, nil
+}
+
+// Apply adds the matched links, marked as made by a rule, in one transaction.
+func Apply(matches []Match) error {
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := utils.DbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var insertSql = "insert or ignore into links (channelId, tagId, source) values (:channelId, :tagId, 'rule')"
+	for _, m := range matches {
+		_, err = tx.ExecContext(ctx, insertSql, m.Channel.Id(), m.TagId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ApplyAll loads the channels and rules from the db and applies every rule,
+// which is what happens after a sync. It returns what was added.
+func ApplyAll() ([]Match, error) {
+	var channels channel.Channels
+	err := channels.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var rs Rules
+	err = rs.Load()
+	if err != nil {
+		return nil, err
+	}
+	if len(rs.rules) == 0 {
+		return nil, nil
+	}
+
+	matches, err := rs.Preview(channels)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	err = Apply(matches)
+	if err != nil {
+		return nil, err
+	}
+
+	return matches, nil
+}
+
+func exceptionKey(channelId string, tagId int64) string {
+	return fmt.Sprintf("%s\x00%d", channelId, tagId)
+}
+
+// loadExceptions returns the channel and tag pairs that rules must not link.
+func loadExceptions() (map[string]bool, error) {
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := utils.DbConn.QueryContext(ctx, "select channelId, tagId from rule_exceptions")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exceptions = make(map[string]bool)
+	for rows.Next() {
+		var channelId string
+		var tagId int64
+		err = rows.Scan(&channelId, &tagId)
+		if err != nil {
+			return nil, err
+		}
+		exceptions[exceptionKey(channelId, tagId)] = true
+	}
+
+	return exceptions, rows.Err()
+}
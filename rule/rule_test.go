@@ -0,0 +1,161 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package rule
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		field     Field
+		matchType MatchType
+		pattern   string
+		want      string
+	}{
+		{FieldName, MatchKeyword, "synth", ""},
+		{FieldAny, MatchRegex, `^(lo-?fi|chill)\b`, ""},
+		{"title", MatchKeyword, "synth", `unknown rule field "title"`},
+		{FieldName, "glob", "synth*", `unknown rule match type "glob"`},
+		{FieldName, MatchKeyword, "  ", "rule pattern can't be empty"},
+		{FieldName, MatchRegex, "(synth", `invalid regex "(synth"`},
+	}
+
+	for _, test := range tests {
+		_, err := New(1, test.field, test.matchType, test.pattern)
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("%s %s %q: %v", test.field, test.matchType, test.pattern, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s %s %q: got error %v, want one containing %q", test.field, test.matchType, test.pattern, err, test.want)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	c := channel.NewChannel("UC1", "Synth Guy", "Retro synthwave and LoFi beats")
+
+	tests := []struct {
+		field     Field
+		matchType MatchType
+		pattern   string
+		want      bool
+	}{
+		// keywords ignore case
+		{FieldName, MatchKeyword, "synth", true},
+		{FieldName, MatchKeyword, "GUY", true},
+		{FieldName, MatchKeyword, "retro", false},
+		{FieldDescription, MatchKeyword, "lofi", true},
+		{FieldDescription, MatchKeyword, "guy", false},
+		{FieldAny, MatchKeyword, "guy", true},
+		{FieldAny, MatchKeyword, "beats", true},
+		{FieldAny, MatchKeyword, "cooking", false},
+		// regexes are taken as they are, so they're case sensitive
+		{FieldName, MatchRegex, "^Synth", true},
+		{FieldName, MatchRegex, "^synth", false},
+		{FieldName, MatchRegex, "(?i)^synth", true},
+		{FieldDescription, MatchRegex, `\bLo-?Fi\b`, true},
+		{FieldAny, MatchRegex, "Guy$", true},
+		{FieldAny, MatchRegex, "^Retro.*beats$", true},
+		{FieldAny, MatchRegex, "Guy Retro", false},
+	}
+
+	for _, test := range tests {
+		r, err := New(1, test.field, test.matchType, test.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Matches(c); got != test.want {
+			t.Errorf("%s: matched %v, want %v", r, got, test.want)
+		}
+	}
+}
+
+func TestPreview(t *testing.T) {
+	utils.InitDb(filepath.Join(t.TempDir(), "ysm.db"), 1)
+	t.Cleanup(func() {
+		utils.DbConn.Close()
+		utils.DbConn = nil
+	})
+
+	var channels channel.Channels
+	channels.LoadEntriesFromDb()
+	cs := channels.Diff(channel.SubscriptionList{Channels: []channel.Channel{
+		channel.NewChannel("UC1", "Synth Guy", "synthwave"),
+		channel.NewChannel("UC2", "Another Synth", "more synthwave"),
+		channel.NewChannel("UC3", "Chef John", "cooking"),
+	}})
+	cs.AcceptAll()
+	err := channels.ApplyChanges(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = utils.DbConn.Exec(`insert into tags (id, name) values (1, 'synthwave'), (2, 'music');
+	insert into links (channelId, tagId) values ('UC1', 1);
+	insert into rule_exceptions (channelId, tagId) values ('UC2', 2)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	channels.LoadEntriesFromDb()
+
+	var rs Rules
+	for i, parts := range []struct {
+		tagId   int64
+		pattern string
+	}{{1, "synth"}, {2, "synth"}, {2, "wave"}} {
+		r, err := New(parts.tagId, FieldAny, MatchKeyword, parts.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.id = int64(i + 1)
+		rs.rules = append(rs.rules, r)
+	}
+
+	tests := []struct {
+		ruleIds []int64
+		want    []string
+	}{
+		// UC1 already has synthwave and UC2 had music taken off by hand
+		{nil, []string{"Another Synth 1 [1]", "Synth Guy 2 [2 3]"}},
+		{[]int64{3}, []string{"Synth Guy 2 [3]"}},
+		{[]int64{1}, []string{"Another Synth 1 [1]"}},
+	}
+
+	for _, test := range tests {
+		matches, err := rs.Preview(channels, test.ruleIds...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, m := range matches {
+			got = append(got, fmt.Sprintf("%s %d %v", m.Channel.Name(), m.TagId, m.RuleIds))
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("rules %v: previewed %q, want %q", test.ruleIds, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package tag
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"repo.joyrex.net/ejstacey/ysm/history"
+)
+
+// Rename renames the tag and keeps its old name as an alias, so anything
+// still using the old name (a query, a -tag flag) finds the tag.
+func (t *Tag) Rename(name string) error {
+	oldName := t.name
+
+	history.Begin(fmt.Sprintf("rename tag %q to %q", oldName, name))
+	err := t.SetName(name)
+	if err == nil && !strings.EqualFold(oldName, name) {
+		err = t.addAlias(oldName)
+	}
+	endErr := history.End()
+	if err != nil {
+		return err
+	}
+
+	return endErr
+}
+
+func (t Tag) addAlias(name string) error {
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	w, err := history.Watch()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	err = w.Row("tag_aliases", map[string]any{"name": name})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Tx().ExecContext(ctx, "insert or replace into tag_aliases (name, tagId) values (:name, :tagId)", name, t.id)
+	if err != nil {
+		return err
+	}
+
+	return w.Record(fmt.Sprintf("add %q as an alias of tag %q", name, t.name))
+}
+
+// RemoveAlias stops an old name from finding the tag it was renamed or
+// merged into.
+func RemoveAlias(name string) error {
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	w, err := history.Watch()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	err = w.Row("tag_aliases", map[string]any{"name": name})
+	if err != nil {
+		return err
+	}
+
+	res, err := w.Tx().ExecContext(ctx, "delete from tag_aliases where name = :name", name)
+	if err != nil {
+		return err
+	}
+	removed, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return fmt.Errorf("there's no tag alias %q", name)
+	}
+
+	return w.Record(fmt.Sprintf("remove tag alias %q", name))
+}
+
+// Merge moves everything of t's onto into: its channels, rules, rule
+// exceptions, sub-tags and aliases. Channels that already had into keep their
+// link, which becomes manual if either link was. t is then deleted, and its
+// name becomes an alias of into. It returns how many channels gained into.
+func (t Tag) Merge(into Tag) (int, error) {
+	if t.id <= 0 || into.id <= 0 {
+		return 0, errors.New("cannot merge, missing tag id")
+	}
+	if t.id == into.id {
+		return 0, errors.New("a tag can't be merged into itself")
+	}
+
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	w, err := history.Watch()
+	if err != nil {
+		return 0, err
+	}
+	defer w.Close()
+
+	// into is moved out from under t first, or t's sub-tags could end up
+	// under one of their own sub-tags
+	var checkSql = `
+		with recursive ancestors(id) as (
+			select parentId from tags where id = :into
+			union
+			select tags.parentId from tags join ancestors on tags.id = ancestors.id where tags.parentId is not null
+		)
+		select count(*) from ancestors where id = :id
+	`
+	var underT int
+	err = w.Tx().QueryRowContext(ctx, checkSql, sql.Named("into", into.id), sql.Named("id", t.id)).Scan(&underT)
+	if err != nil {
+		return 0, err
+	}
+
+	var channelIds []string
+	rows, err := w.Tx().QueryContext(ctx, "select channelId from links where tagId = :id union select channelId from rule_exceptions where tagId = :id", t.id)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var channelId string
+		err = rows.Scan(&channelId)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		channelIds = append(channelIds, channelId)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// t's own row is watched last, so it's back before anything pointing
+	// at it is put back
+	err = w.Rows("links", []string{"channelId", "tagId"}, "tagId = ?", t.id)
+	if err == nil {
+		err = w.Rows("rule_exceptions", []string{"channelId", "tagId"}, "tagId = ?", t.id)
+	}
+	for _, channelId := range channelIds {
+		if err != nil {
+			break
+		}
+		var key = map[string]any{"channelId": channelId, "tagId": into.id}
+		err = w.Row("links", key)
+		if err == nil {
+			err = w.Row("rule_exceptions", key)
+		}
+	}
+	if err == nil {
+		err = w.Rows("rules", []string{"id"}, "tagId = ?", t.id)
+	}
+	if err == nil {
+		err = w.Rows("tag_aliases", []string{"name"}, "tagId = ?", t.id)
+	}
+	if err == nil {
+		err = w.Row("tag_aliases", map[string]any{"name": t.name})
+	}
+	if err == nil {
+		err = w.Rows("tags", []string{"id"}, "parentId = ? and id != ?", t.id, into.id)
+	}
+	if err == nil {
+		err = w.Row("tags", map[string]any{"id": into.id})
+	}
+	if err == nil {
+		err = w.Row("tags", map[string]any{"id": t.id})
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var gained int
+	err = w.Tx().QueryRowContext(ctx, "select count(*) from links where tagId = :from and channelId not in (select channelId from links where tagId = :into)", t.id, into.id).Scan(&gained)
+	if err != nil {
+		return 0, err
+	}
+
+	var parent any
+	if t.parentId != 0 {
+		parent = t.parentId
+	}
+
+	type statement struct {
+		query string
+		args  []any
+	}
+	var statements = []statement{
+		{`insert into links (channelId, tagId, source, createdAt)
+			select channelId, :into, source, createdAt from links where tagId = :from
+			on conflict (channelId, tagId) do update set source = 'manual' where excluded.source = 'manual'`, []any{sql.Named("into", into.id), sql.Named("from", t.id)}},
+		{"insert or ignore into rule_exceptions (channelId, tagId) select channelId, :into from rule_exceptions where tagId = :from", []any{sql.Named("into", into.id), sql.Named("from", t.id)}},
+		{"update rules set tagId = :into where tagId = :from", []any{sql.Named("into", into.id), sql.Named("from", t.id)}},
+		{"update tag_aliases set tagId = :into where tagId = :from", []any{sql.Named("into", into.id), sql.Named("from", t.id)}},
+		{"insert or replace into tag_aliases (name, tagId) values (:name, :into)", []any{sql.Named("name", t.name), sql.Named("into", into.id)}},
+	}
+	if underT > 0 {
+		statements = append(statements, statement{"update tags set parentId = :parentId where id = :into", []any{sql.Named("parentId", parent), sql.Named("into", into.id)}})
+	}
+	statements = append(statements,
+		statement{"update tags set parentId = :into where parentId = :from and id != :into", []any{sql.Named("into", into.id), sql.Named("from", t.id)}},
+		// the links and rule exceptions left go with it through the cascades
+		statement{"delete from tags where id = :from", []any{sql.Named("from", t.id)}},
+	)
+
+	for _, s := range statements {
+		_, err = w.Tx().ExecContext(ctx, s.query, s.args...)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = w.Record(fmt.Sprintf("merge tag %q into %q", t.name, into.name))
+	if err != nil {
+		return 0, err
+	}
+
+	return gained, nil
+}
+
+// Split moves some of the tag's channels to a new tag called name, which is
+// put next to it with the same colours and visibility, and returns the new tag.
+func (t Tag) Split(name string, channelIds []string) (Tag, error) {
+	var newTag Tag
+
+	if t.id <= 0 {
+		return newTag, errors.New("cannot split, missing tag id")
+	}
+	if len(channelIds) == 0 {
+		return newTag, errors.New("pick at least one channel to move to the new tag")
+	}
+	for _, channelId := range channelIds {
+		if !slices.Contains(t.channels, channelId) {
+			return newTag, fmt.Errorf("channel %s isn't tagged with %q", channelId, t.name)
+		}
+	}
+
+	history.Begin(fmt.Sprintf("split %d channels from tag %q into %q", len(channelIds), t.name, name))
+	err := newTag.split(t, name, channelIds)
+	endErr := history.End()
+	if err != nil {
+		return newTag, err
+	}
+
+	return newTag, endErr
+}
+
+func (t *Tag) split(from Tag, name string, channelIds []string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("the new tag needs a name")
+	}
+	err := t.validateName(0, name)
+	if err != nil {
+		return fmt.Errorf("there's already a tag named %q", name)
+	}
+
+	err = t.New()
+	if err != nil {
+		return err
+	}
+	err = t.SetName(name)
+	if err != nil {
+		return err
+	}
+	err = t.SetFgColour(from.fgColour)
+	if err != nil {
+		return err
+	}
+	err = t.SetBgColour(from.bgColour)
+	if err != nil {
+		return err
+	}
+	if from.parentId != 0 {
+		err = t.SetParentId(from.parentId)
+		if err != nil {
+			return err
+		}
+	}
+	// so splitting a private tag doesn't make any of its channels public
+	if from.visibility != VisibilityPublic && from.visibility != "" {
+		err = t.SetVisibility(from.visibility)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	w, err := history.Watch()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	for _, channelId := range channelIds {
+		for _, tagId := range []int64{from.id, t.id} {
+			err = w.Row("links", map[string]any{"channelId": channelId, "tagId": tagId})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, channelId := range channelIds {
+		_, err = w.Tx().ExecContext(ctx, "update links set tagId = :to where channelId = :channelId and tagId = :from", t.id, channelId, from.id)
+		if err != nil {
+			return fmt.Errorf("channel %s: %w", channelId, err)
+		}
+	}
+
+	err = w.Record(fmt.Sprintf("move channels from tag %q to %q", from.name, name))
+	if err != nil {
+		return err
+	}
+
+	t.channels = slices.Sorted(slices.Values(channelIds))
+
+	return nil
+}
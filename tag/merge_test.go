@@ -0,0 +1,255 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package tag
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"repo.joyrex.net/ejstacey/ysm/history"
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+// openTestDb opens a fresh db with these tags, and channels UC1 to UC5:
+//
+//	1 music > 2 synthwave > 3 arp
+//	          4 chiptune
+//	5 retro
+//
+// synthwave has UC1 and UC3 by hand and UC2 and UC4 from its rule, and UC5
+// was taken off it after the rule tagged it. retro has UC2 by hand and UC3
+// from a rule.
+func openTestDb(t *testing.T) {
+	t.Helper()
+
+	utils.InitDb(filepath.Join(t.TempDir(), "ysm.db"), 1)
+	t.Cleanup(func() {
+		utils.DbConn.Close()
+		utils.DbConn = nil
+	})
+
+	_, err := utils.DbConn.Exec(`
+		insert into channels (id, name) values ('UC1', 'One'), ('UC2', 'Two'), ('UC3', 'Three'), ('UC4', 'Four'), ('UC5', 'Five');
+		insert into tags (id, name, parentId, bgColour, fgColour) values
+			(1, 'music', null, '', ''), (2, 'synthwave', 1, 'FF00FF', '000000'), (3, 'arp', 2, '', ''),
+			(4, 'chiptune', 1, '', ''), (5, 'retro', null, '', '');
+		insert into links (channelId, tagId, source) values
+			('UC1', 2, 'manual'), ('UC2', 2, 'rule'), ('UC3', 2, 'manual'), ('UC4', 2, 'rule'),
+			('UC2', 5, 'manual'), ('UC3', 5, 'rule');
+		insert into rules (tagId, field, matchType, pattern) values (2, 'any', 'keyword', 'synth');
+		insert into rule_exceptions (channelId, tagId) values ('UC5', 2);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadTags(t *testing.T) Tags {
+	t.Helper()
+
+	var tags Tags
+	err := tags.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tags
+}
+
+// query returns the rows of a query, each row's columns joined with spaces.
+func query(t *testing.T, text string) []string {
+	t.Helper()
+
+	rows, err := utils.DbConn.Query(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for rows.Next() {
+		var values = make([]any, len(columns))
+		var pointers = make([]any, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		err = rows.Scan(pointers...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var parts []string
+		for _, value := range values {
+			parts = append(parts, fmt.Sprint(value))
+		}
+		got = append(got, strings.Join(parts, " "))
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestMerge(t *testing.T) {
+	openTestDb(t)
+
+	tags := loadTags(t)
+	gained, err := tags.ById()[2].Merge(tags.ById()[5])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gained != 2 {
+		t.Errorf("%d channels gained retro, want UC1 and UC4", gained)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// either link being manual makes it manual
+		{"select channelId, source from links where tagId = 5 order by channelId", []string{"UC1 manual", "UC2 manual", "UC3 manual", "UC4 rule"}},
+		{"select count(*) from links where tagId = 2", []string{"0"}},
+		{"select channelId, tagId from rule_exceptions", []string{"UC5 5"}},
+		{"select tagId, pattern from rules", []string{"5 synth"}},
+		{"select name, tagId from tag_aliases", []string{"synthwave 5"}},
+		{"select id, name, parentId from tags order by id", []string{"1 music <nil>", "3 arp 5", "4 chiptune 1", "5 retro <nil>"}},
+	}
+	for _, test := range tests {
+		if got := query(t, test.query); !slices.Equal(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.query, got, test.want)
+		}
+	}
+
+	if found, ok := loadTags(t).Find("SynthWave"); !ok || found.Id() != 5 {
+		t.Errorf("synthwave finds %v, want retro", found)
+	}
+
+	_, err = history.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := query(t, "select channelId, tagId, source from links order by tagId, channelId"); !slices.Equal(got, []string{
+		"UC1 2 manual", "UC2 2 rule", "UC3 2 manual", "UC4 2 rule", "UC2 5 manual", "UC3 5 rule",
+	}) {
+		t.Errorf("links are %q after undo, want them as they were", got)
+	}
+	if got := query(t, "select id, parentId from tags where id in (2, 3)"); !slices.Equal(got, []string{"2 1", "3 2"}) {
+		t.Errorf("tags are %q after undo, want synthwave back with arp under it", got)
+	}
+}
+
+func TestMergeIntoSubTag(t *testing.T) {
+	openTestDb(t)
+
+	// arp is moved up to where music was, or music's sub-tags would end up
+	// under arp and arp under them
+	tags := loadTags(t)
+	_, err := tags.ById()[1].Merge(tags.ById()[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"2 synthwave 3", "3 arp <nil>", "4 chiptune 3", "5 retro <nil>"}
+	if got := query(t, "select id, name, parentId from tags order by id"); !slices.Equal(got, want) {
+		t.Errorf("tags are %q, want %q", got, want)
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	openTestDb(t)
+	tags := loadTags(t)
+
+	tests := []struct {
+		from, into Tag
+		want       string
+	}{
+		{tags.ById()[2], tags.ById()[2], "a tag can't be merged into itself"},
+		{Tag{}, tags.ById()[2], "missing tag id"},
+		{tags.ById()[2], Tag{}, "missing tag id"},
+	}
+	for _, test := range tests {
+		_, err := test.from.Merge(test.into)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%q into %q: got error %v, want one containing %q", test.from.Name(), test.into.Name(), err, test.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	openTestDb(t)
+
+	newTag, err := loadTags(t).ById()[2].Split("darksynth", []string{"UC2", "UC3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// the links keep where they came from
+		{"select channelId, source from links where tagId = 2 order by channelId", []string{"UC1 manual", "UC4 rule"}},
+		{fmt.Sprintf("select channelId, source from links where tagId = %d order by channelId", newTag.Id()), []string{"UC2 rule", "UC3 manual"}},
+		{fmt.Sprintf("select name, parentId, bgColour, fgColour from tags where id = %d", newTag.Id()), []string{"darksynth 1 FF00FF 000000"}},
+	}
+	for _, test := range tests {
+		if got := query(t, test.query); !slices.Equal(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.query, got, test.want)
+		}
+	}
+	if got := newTag.Channels(); !slices.Equal(got, []string{"UC2", "UC3"}) {
+		t.Errorf("new tag has channels %q, want UC2 and UC3", got)
+	}
+
+	// the whole split is one entry
+	_, err = history.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := query(t, "select count(*) from tags where name = 'darksynth' union all select count(*) from links where tagId = 2"); !slices.Equal(got, []string{"0", "4"}) {
+		t.Errorf("got %q after undo, want no darksynth and synthwave's 4 links back", got)
+	}
+}
+
+func TestSplitErrors(t *testing.T) {
+	openTestDb(t)
+	synthwave := loadTags(t).ById()[2]
+
+	tests := []struct {
+		name       string
+		channelIds []string
+		want       string
+	}{
+		{"darksynth", nil, "pick at least one channel"},
+		{"darksynth", []string{"UC1", "UC5"}, `channel UC5 isn't tagged with "synthwave"`},
+		{"  ", []string{"UC1"}, "the new tag needs a name"},
+		{"retro", []string{"UC1"}, `there's already a tag named "retro"`},
+	}
+	for _, test := range tests {
+		_, err := synthwave.Split(test.name, test.channelIds)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%q %q: got error %v, want one containing %q", test.name, test.channelIds, err, test.want)
+		}
+	}
+
+	if got := query(t, "select count(*) from tags"); !slices.Equal(got, []string{"5"}) {
+		t.Errorf("there are %q tags after the failed splits, want 5", got)
+	}
+}
@@ -17,9 +17,12 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"maps"
 	"slices"
+	"strings"
 	"time"
 
+	"repo.joyrex.net/ejstacey/ysm/history"
 	"repo.joyrex.net/ejstacey/ysm/utils"
 )
 
@@ -29,14 +32,40 @@ type ExportTag struct {
 	Description string
 	BgColour    string
 	FgColour    string
+	// ParentId is 0 for top level tags. Depth is how many parents the tag
+	// has, and Ancestors are their ids, nearest first.
+	ParentId  int64
+	Depth     int
+	Ancestors []int64
+	// Page is where the tag's page is in a generated site, relative to the
+	// top of the site.
+	Page string
+	// Children is only filled in for the tag tree in the generator.
+	Children []ExportTag
+	// TaggedAt is only filled in for a channel's tags in the generator, with
+	// when the channel was given the tag, or zero if that's not known.
+	TaggedAt time.Time
 }
 
+// Visibility is how a tag is treated by the generator. Private tags and the
+// channels tagged with them are left out. Unlisted tags are left out, but
+// their channels aren't.
+const (
+	VisibilityPublic   string = "public"
+	VisibilityPrivate  string = "private"
+	VisibilityUnlisted string = "unlisted"
+)
+
+var Visibilities = []string{VisibilityPublic, VisibilityUnlisted, VisibilityPrivate}
+
 type Tag struct {
 	id          int64
 	name        string
 	description string
 	bgColour    string
 	fgColour    string
+	parentId    int64
+	visibility  string
 	channels    []string
 }
 
@@ -47,6 +76,8 @@ func (t Tag) Name() string        { return t.name }
 func (t Tag) Description() string { return t.description }
 func (t Tag) BgColour() string    { return t.bgColour }
 func (t Tag) FgColour() string    { return t.fgColour }
+func (t Tag) ParentId() int64     { return t.parentId }
+func (t Tag) Visibility() string  { return t.visibility }
 func (t Tag) Channels() []string  { return t.channels }
 func (t *Tag) SetTitle(x string)  { t.SetName(x) }
 
@@ -68,9 +99,15 @@ func (t *Tag) New() error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	w, err := history.Watch()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
 	var insertSql = "insert into tags (name) values (:name)"
 
-	insertSth, err := utils.DbConn.PrepareContext(ctx, insertSql)
+	insertSth, err := w.Tx().PrepareContext(ctx, insertSql)
 	if err != nil {
 		return err
 	}
@@ -85,6 +122,13 @@ func (t *Tag) New() error {
 	if err != nil {
 		return err
 	}
+	t.visibility = VisibilityPublic
+
+	w.Inserted("tags", map[string]any{"id": t.id})
+	err = w.Record("create tag")
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -95,9 +139,42 @@ func (t *Tag) Delete() error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	// everything that goes with the tag, so it can all be put back. The tag
+	// itself is last so it's put back first.
+	w, err := history.Watch()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	err = w.Rows("links", []string{"channelId", "tagId"}, "tagId = ?", t.id)
+	if err != nil {
+		return err
+	}
+	err = w.Rows("rules", []string{"id"}, "tagId = ?", t.id)
+	if err != nil {
+		return err
+	}
+	err = w.Rows("rule_exceptions", []string{"channelId", "tagId"}, "tagId = ?", t.id)
+	if err != nil {
+		return err
+	}
+	err = w.Rows("tag_aliases", []string{"name"}, "tagId = ?", t.id)
+	if err != nil {
+		return err
+	}
+	err = w.Rows("tags", []string{"id"}, "parentId = ?", t.id)
+	if err != nil {
+		return err
+	}
+	err = w.Row("tags", map[string]any{"id": t.id})
+	if err != nil {
+		return err
+	}
+
 	var deleteSql = "delete from tags where id = :id"
 
-	deleteSth, err := utils.DbConn.PrepareContext(ctx, deleteSql)
+	deleteSth, err := w.Tx().PrepareContext(ctx, deleteSql)
 	if err != nil {
 		return err
 	}
@@ -108,7 +185,7 @@ func (t *Tag) Delete() error {
 		return err
 	}
 
-	return nil
+	return w.Record(fmt.Sprintf("delete tag %q", t.name))
 }
 
 func (t *Tag) SetName(x string) error {
@@ -126,9 +203,24 @@ func (t *Tag) SetName(x string) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	w, err := history.Watch()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	err = w.Row("tag_aliases", map[string]any{"name": x})
+	if err != nil {
+		return err
+	}
+	err = w.Row("tags", map[string]any{"id": t.id})
+	if err != nil {
+		return err
+	}
+
 	var updateSql = "update tags set name = :name where id = :id"
 
-	updateSth, err := utils.DbConn.PrepareContext(ctx, updateSql)
+	updateSth, err := w.Tx().PrepareContext(ctx, updateSql)
 	if err != nil {
 		return err
 	}
@@ -138,6 +230,17 @@ func (t *Tag) SetName(x string) error {
 		return err
 	}
 
+	// a tag's own name wins over another tag's old one
+	_, err = w.Tx().ExecContext(ctx, "delete from tag_aliases where name = :name", x)
+	if err != nil {
+		return err
+	}
+
+	err = w.Record(fmt.Sprintf("rename tag %q to %q", t.name, x))
+	if err != nil {
+		return err
+	}
+
 	t.name = x
 
 	return nil
@@ -153,9 +256,20 @@ func (t *Tag) SetDescription(x string) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	w, err := history.Watch()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	err = w.Row("tags", map[string]any{"id": t.id})
+	if err != nil {
+		return err
+	}
+
 	var updateSql = "update tags set description = :description where id = :id"
 
-	updateSth, err := utils.DbConn.PrepareContext(ctx, updateSql)
+	updateSth, err := w.Tx().PrepareContext(ctx, updateSql)
 	if err != nil {
 		return err
 	}
@@ -165,6 +279,11 @@ func (t *Tag) SetDescription(x string) error {
 		return err
 	}
 
+	err = w.Record(fmt.Sprintf("set description of tag %q", t.name))
+	if err != nil {
+		return err
+	}
+
 	t.description = x
 
 	return nil
@@ -180,9 +299,20 @@ func (t *Tag) SetBgColour(x string) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	w, err := history.Watch()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	err = w.Row("tags", map[string]any{"id": t.id})
+	if err != nil {
+		return err
+	}
+
 	var updateSql = "update tags set bgColour = :colour where id = :id"
 
-	updateSth, err := utils.DbConn.PrepareContext(ctx, updateSql)
+	updateSth, err := w.Tx().PrepareContext(ctx, updateSql)
 	if err != nil {
 		return err
 	}
@@ -192,6 +322,11 @@ func (t *Tag) SetBgColour(x string) error {
 		return err
 	}
 
+	err = w.Record(fmt.Sprintf("set background colour of tag %q", t.name))
+	if err != nil {
+		return err
+	}
+
 	t.bgColour = x
 
 	return nil
@@ -207,9 +342,20 @@ func (t *Tag) SetFgColour(x string) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	w, err := history.Watch()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	err = w.Row("tags", map[string]any{"id": t.id})
+	if err != nil {
+		return err
+	}
+
 	var updateSql = "update tags set fgColour = :colour where id = :id"
 
-	updateSth, err := utils.DbConn.PrepareContext(ctx, updateSql)
+	updateSth, err := w.Tx().PrepareContext(ctx, updateSql)
 	if err != nil {
 		return err
 	}
@@ -219,11 +365,139 @@ func (t *Tag) SetFgColour(x string) error {
 		return err
 	}
 
+	err = w.Record(fmt.Sprintf("set foreground colour of tag %q", t.name))
+	if err != nil {
+		return err
+	}
+
 	t.fgColour = x
 
 	return nil
 }
 
+// SetParentId moves the tag under another tag, or to the top level if x is 0.
+// A tag can't be moved under itself or any of its descendants.
+func (t *Tag) SetParentId(x int64) error {
+	if t.id <= 0 {
+		return errors.New("cannot set parent, missing id")
+	}
+
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	w, err := history.Watch()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	var parent any
+	if x != 0 {
+		// walk up from the new parent, if this tag is on the way it'd be a loop
+		var checkSql = `
+			with recursive ancestors(id) as (
+				select :parentId
+				union
+				select tags.parentId from tags join ancestors on tags.id = ancestors.id where tags.parentId is not null
+			)
+			select count(*) from ancestors where id = :id
+		`
+		var loops int
+		err := w.Tx().QueryRowContext(ctx, checkSql, x, t.id).Scan(&loops)
+		if err != nil {
+			return err
+		}
+		if loops != 0 {
+			return errors.New("a tag can't be its own parent, or the parent of one of its parents")
+		}
+		parent = x
+	}
+
+	err = w.Row("tags", map[string]any{"id": t.id})
+	if err != nil {
+		return err
+	}
+
+	var updateSql = "update tags set parentId = :parentId where id = :id"
+
+	updateSth, err := w.Tx().PrepareContext(ctx, updateSql)
+	if err != nil {
+		return err
+	}
+
+	_, err = updateSth.ExecContext(ctx, parent, t.id)
+	if err != nil {
+		return err
+	}
+
+	err = w.Record(fmt.Sprintf("move tag %q", t.name))
+	if err != nil {
+		return err
+	}
+
+	t.parentId = x
+
+	return nil
+}
+
+// SetVisibility sets whether the generator shows the tag, one of
+// Visibilities.
+func (t *Tag) SetVisibility(x string) error {
+	if !slices.Contains(Visibilities, x) {
+		return fmt.Errorf("unknown visibility %q, should be one of %s", x, strings.Join(Visibilities, ", "))
+	}
+
+	if t.id <= 0 {
+		return errors.New("cannot set visibility, missing id")
+	}
+
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	w, err := history.Watch()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	err = w.Row("tags", map[string]any{"id": t.id})
+	if err != nil {
+		return err
+	}
+
+	var updateSql = "update tags set visibility = :visibility where id = :id"
+
+	updateSth, err := w.Tx().PrepareContext(ctx, updateSql)
+	if err != nil {
+		return err
+	}
+
+	_, err = updateSth.ExecContext(ctx, x, t.id)
+	if err != nil {
+		return err
+	}
+
+	err = w.Record(fmt.Sprintf("make tag %q %s", t.name, x))
+	if err != nil {
+		return err
+	}
+
+	t.visibility = x
+
+	return nil
+}
+
+// ruleExceptionSql is channel.RuleExceptionSql, which can't be used from here
+// without an import cycle.
+const ruleExceptionSql = `
+	insert or ignore into rule_exceptions (channelId, tagId)
+	select channelId, tagId from links where channelId = :channelId and tagId = :tagId and source = 'rule'
+`
+
 func (t *Tag) SetChannels(x []string) error {
 	if t.id <= 0 {
 		return errors.New("cannot set channels, missing tag id")
@@ -235,10 +509,30 @@ func (t *Tag) SetChannels(x []string) error {
 	defer cancel()
 
 	toAdd := utils.StringDifference(x, t.channels)
+	toDelete := utils.StringDifference(t.channels, x)
+
+	w, err := history.Watch()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	for _, channelId := range append(slices.Clone(toAdd), toDelete...) {
+		var key = map[string]any{"channelId": channelId, "tagId": t.id}
+		err := w.Row("links", key)
+		if err != nil {
+			return err
+		}
+		err = w.Row("rule_exceptions", key)
+		if err != nil {
+			return err
+		}
+	}
+
 	for _, channelId := range toAdd {
 		var insertSql = "insert into links (tagId, channelId) values (:tagId, :channelId)"
 
-		insertSth, err := utils.DbConn.PrepareContext(ctx, insertSql)
+		insertSth, err := w.Tx().PrepareContext(ctx, insertSql)
 		if err != nil {
 			return err
 		}
@@ -249,11 +543,16 @@ func (t *Tag) SetChannels(x []string) error {
 		}
 	}
 
-	toDelete := utils.StringDifference(t.channels, x)
 	for _, channelId := range toDelete {
+		// so the rule that made the link doesn't just make it again
+		_, err := w.Tx().ExecContext(ctx, ruleExceptionSql, channelId, t.id)
+		if err != nil {
+			return err
+		}
+
 		var deleteSql = "delete from links where tagId=:tagId and channelId=:channelId"
 
-		deleteSth, err := utils.DbConn.PrepareContext(ctx, deleteSql)
+		deleteSth, err := w.Tx().PrepareContext(ctx, deleteSql)
 		if err != nil {
 			return err
 		}
@@ -264,6 +563,11 @@ func (t *Tag) SetChannels(x []string) error {
 		}
 	}
 
+	err = w.Record(fmt.Sprintf("set channels of tag %q", t.name))
+	if err != nil {
+		return err
+	}
+
 	t.channels = x
 
 	return nil
@@ -302,6 +606,9 @@ func (t Tag) validateName(num int, name string) error {
 type Tags struct {
 	byId   map[int64]Tag
 	byName map[string]Tag
+	// aliases are the old names of renamed and merged tags, and the ids of
+	// the tags they now find
+	aliases map[string]int64
 }
 
 func (t Tags) ById() map[int64]Tag {
@@ -312,7 +619,162 @@ func (t Tags) ByName() map[string]Tag {
 	return t.byName
 }
 
+func (t Tags) Aliases() map[string]int64 {
+	return t.aliases
+}
+
+// AliasesOf returns the old names that find the tag, sorted.
+func (t Tags) AliasesOf(id int64) []string {
+	var names []string
+	for alias, tagId := range t.aliases {
+		if tagId == id {
+			names = append(names, alias)
+		}
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// Find looks a tag up by its name, ignoring case if there's no exact match,
+// and then by the names it used to have.
+func (t Tags) Find(name string) (Tag, bool) {
+	if tag, ok := t.byName[name]; ok {
+		return tag, true
+	}
+
+	for tagName, tag := range t.byName {
+		if strings.EqualFold(tagName, name) {
+			return tag, true
+		}
+	}
+
+	for alias, id := range t.aliases {
+		if tag, ok := t.byId[id]; ok && strings.EqualFold(alias, name) {
+			return tag, true
+		}
+	}
+
+	return Tag{}, false
+}
+
+// Children returns the tags directly under id (0 for the top level tags),
+// sorted by name.
+func (t Tags) Children(id int64) []Tag {
+	var children []Tag
+	for _, tag := range t.byId {
+		if tag.parentId == id {
+			children = append(children, tag)
+		}
+	}
+	slices.SortFunc(children, func(a, b Tag) int { return strings.Compare(a.name, b.name) })
+
+	return children
+}
+
+// Descendants returns the ids of every tag under id, at any depth.
+func (t Tags) Descendants(id int64) []int64 {
+	var descendants []int64
+	var queue = []int64{id}
+	for len(queue) != 0 {
+		for _, child := range t.Children(queue[0]) {
+			if child.id == id || slices.Contains(descendants, child.id) {
+				continue
+			}
+			descendants = append(descendants, child.id)
+			queue = append(queue, child.id)
+		}
+		queue = queue[1:]
+	}
+
+	return descendants
+}
+
+// Ancestors returns the ids of the parents of id, nearest first.
+func (t Tags) Ancestors(id int64) []int64 {
+	var ancestors []int64
+	for parentId := t.byId[id].parentId; parentId != 0; parentId = t.byId[parentId].parentId {
+		// a loop can only come from editing the db by hand, but don't hang on one
+		if slices.Contains(ancestors, parentId) || parentId == id {
+			break
+		}
+		ancestors = append(ancestors, parentId)
+	}
+
+	return ancestors
+}
+
+// Depth is how many parents the tag has.
+func (t Tags) Depth(id int64) int {
+	return len(t.Ancestors(id))
+}
+
+// Path is the tag's name with its parents' in front, like "Music > Synthwave".
+func (t Tags) Path(id int64) string {
+	var names = []string{t.byId[id].name}
+	for _, ancestorId := range t.Ancestors(id) {
+		names = append([]string{t.byId[ancestorId].name}, names...)
+	}
+
+	return strings.Join(names, " > ")
+}
+
+// Tree returns every tag in depth first order, each tag followed by its
+// children, with siblings sorted by name.
+func (t Tags) Tree() []Tag {
+	var tree []Tag
+	var seen = make(map[int64]bool)
+
+	var walk func(id int64)
+	walk = func(id int64) {
+		for _, child := range t.Children(id) {
+			if seen[child.id] {
+				continue
+			}
+			seen[child.id] = true
+			tree = append(tree, child)
+			walk(child.id)
+		}
+	}
+	walk(0)
+
+	// tags whose parent is missing or in a loop still need to show up
+	if len(tree) != len(t.byId) {
+		for _, name := range slices.Sorted(maps.Keys(t.byName)) {
+			if tag := t.byName[name]; !seen[tag.id] {
+				seen[tag.id] = true
+				tree = append(tree, tag)
+				walk(tag.id)
+			}
+		}
+	}
+
+	return tree
+}
+
+// WithDescendants returns ids along with all of their descendants, for
+// matching channels against tags the way tagging a channel "Synthwave" also
+// counts as tagging it "Music".
+func (t Tags) WithDescendants(ids ...int64) map[int64]bool {
+	var matched = make(map[int64]bool)
+	for _, id := range ids {
+		matched[id] = true
+		for _, descendant := range t.Descendants(id) {
+			matched[descendant] = true
+		}
+	}
+
+	return matched
+}
+
 func (t *Tags) LoadEntriesFromDb() {
+	err := t.Load()
+	utils.HandleError(err, "Loading existing tags from db")
+}
+
+// Load replaces t with the tags in the db. Unlike LoadEntriesFromDb it
+// returns an error rather than exiting when they can't be read.
+func (t *Tags) Load() error {
 	var tagsById = make(map[int64]Tag)
 	var tagsByName = make(map[string]Tag)
 
@@ -321,13 +783,17 @@ func (t *Tags) LoadEntriesFromDb() {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	var csText = "select id, name, description, bgColour, fgColour from tags"
+	var csText = "select id, name, description, bgColour, fgColour, ifnull(parentId, 0), visibility from tags"
 
 	csSth, err := utils.DbConn.PrepareContext(ctx, csText)
-	utils.HandleError(err, "Unable to prepare sqlSelect "+csText)
+	if err != nil {
+		return err
+	}
 
 	rows, err := csSth.QueryContext(ctx)
-	utils.HandleError(err, "Unable to run sqlSelect "+csText)
+	if err != nil {
+		return err
+	}
 
 	defer rows.Close()
 	for rows.Next() {
@@ -336,8 +802,10 @@ func (t *Tags) LoadEntriesFromDb() {
 		var tmpBgColour sql.NullString
 		var tmpFgColour sql.NullString
 
-		err = rows.Scan(&tag.id, &tag.name, &tmpDescription, &tmpBgColour, &tmpFgColour)
-		utils.HandleError(err, "Loading existing tag from db (row)")
+		err = rows.Scan(&tag.id, &tag.name, &tmpDescription, &tmpBgColour, &tmpFgColour, &tag.parentId, &tag.visibility)
+		if err != nil {
+			return err
+		}
 
 		if tmpDescription.Valid {
 			tag.description = tmpDescription.String
@@ -357,21 +825,26 @@ func (t *Tags) LoadEntriesFromDb() {
 			tag.fgColour = "#FFFFFF"
 		}
 
-		var linkText = "select * from links where tagId = :id"
+		var linkText = "select channelId from links where tagId = :id"
 
 		linkSth, err := utils.DbConn.PrepareContext(ctx, linkText)
-		utils.HandleError(err, "Unable to prepare sqlSelect "+linkText)
+		if err != nil {
+			return err
+		}
 
 		linkRows, err := linkSth.QueryContext(ctx, tag.id)
-		utils.HandleError(err, "Unable to run sqlSelect "+linkText)
+		if err != nil {
+			return err
+		}
 
 		defer linkRows.Close()
 		for linkRows.Next() {
 			var channelId string
-			var tagId int
 
-			err = linkRows.Scan(&channelId, &tagId)
-			utils.HandleError(err, "Loading existing links from db (row)")
+			err = linkRows.Scan(&channelId)
+			if err != nil {
+				return err
+			}
 
 			tag.channels = append(tag.channels, channelId)
 		}
@@ -379,8 +852,37 @@ func (t *Tags) LoadEntriesFromDb() {
 		tagsById[tag.id] = tag
 		tagsByName[tag.name] = tag
 	}
-	utils.HandleError(err, "Loading existing tag from db")
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	var aliases = make(map[string]int64)
+	var aliasText = "select name, tagId from tag_aliases"
+
+	aliasRows, err := utils.DbConn.QueryContext(ctx, aliasText)
+	if err != nil {
+		return err
+	}
+
+	defer aliasRows.Close()
+	for aliasRows.Next() {
+		var name string
+		var tagId int64
+
+		err = aliasRows.Scan(&name, &tagId)
+		if err != nil {
+			return err
+		}
+
+		aliases[name] = tagId
+	}
+	if err = aliasRows.Err(); err != nil {
+		return err
+	}
 
 	t.byId = tagsById
 	t.byName = tagsByName
+	t.aliases = aliases
+
+	return nil
 }
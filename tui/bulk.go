@@ -0,0 +1,224 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package tui
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+	"repo.joyrex.net/ejstacey/ysm/channel"
+)
+
+var bulkKeyList = map[string]key.Binding{
+	"nextKey": key.NewBinding(
+		key.WithKeys("down", "tab"),
+		key.WithHelp("<down>/<tab>", "next option"),
+	),
+	"prevKey": key.NewBinding(
+		key.WithKeys("up", "shift+tab"),
+		key.WithHelp("<up>/<shift-tab>", "previous option"),
+	),
+	"enterKey": key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("<enter>", "do it to all the selected channels"),
+	),
+	"escKey": key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("<esc>", "back out to channel view"),
+	),
+}
+
+type bulkKeyMap struct {
+	NextKey  key.Binding
+	PrevKey  key.Binding
+	EnterKey key.Binding
+	EscKey   key.Binding
+}
+
+func (k bulkKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.NextKey, k.PrevKey, k.EnterKey, k.EscKey}
+}
+func (k bulkKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.NextKey, k.PrevKey, k.EscKey},
+		{k.EnterKey},
+	}
+}
+
+func newBulkKeyMap() bulkKeyMap {
+	return bulkKeyMap{
+		NextKey:  bulkKeyList["nextKey"],
+		PrevKey:  bulkKeyList["prevKey"],
+		EnterKey: bulkKeyList["enterKey"],
+		EscKey:   bulkKeyList["escKey"],
+	}
+}
+
+// the buttons on the bulk page, which come after the tag name input
+var bulkButtons = []struct {
+	label  string
+	action channel.BulkAction
+}{
+	{"[ Add tag ]", channel.BulkAddTag},
+	{"[ Remove tag ]", channel.BulkRemoveTag},
+	{"[ Clear notes ]", channel.BulkClearNotes},
+	{"[ Archive ]", channel.BulkArchive},
+}
+
+func (m Model) createBulkTagInput() textinput.Model {
+	t := textinput.New()
+	t.Cursor.Style = cursorStyle
+	t.CharLimit = 256
+	t.Placeholder = "name of the tag to add or remove"
+	t.Focus()
+	t.PromptStyle = focusedStyle
+	t.TextStyle = focusedStyle
+
+	return t
+}
+
+// toggleChannelSelected selects or unselects the channel under the cursor
+// for bulk changes.
+func (m *Model) toggleChannelSelected() {
+	if m.list.SelectedItem() == nil {
+		return
+	}
+
+	id := m.list.SelectedItem().(channel.Channel).Id()
+	if m.bulkSelected[id] {
+		delete(m.bulkSelected, id)
+	} else {
+		m.bulkSelected[id] = true
+	}
+}
+
+// toggleAllChannelsSelected selects all the channels being shown, or if
+// they're all selected already, unselects them.
+func (m *Model) toggleAllChannelsSelected() {
+	var visible []string
+	for _, item := range m.list.VisibleItems() {
+		visible = append(visible, item.(channel.Channel).Id())
+	}
+
+	allSelected := !slices.ContainsFunc(visible, func(id string) bool { return !m.bulkSelected[id] })
+	for _, id := range visible {
+		if allSelected {
+			delete(m.bulkSelected, id)
+		} else {
+			m.bulkSelected[id] = true
+		}
+	}
+}
+
+func (m *Model) moveBulkFocus(s string) tea.Cmd {
+	if s == "up" || s == "shift+tab" {
+		m.bulkFocus--
+	} else {
+		m.bulkFocus++
+	}
+
+	if m.bulkFocus > len(bulkButtons) {
+		m.bulkFocus = 0
+	} else if m.bulkFocus < 0 {
+		m.bulkFocus = len(bulkButtons)
+	}
+
+	if m.bulkFocus == 0 {
+		m.bulkTagInput.PromptStyle = focusedStyle
+		m.bulkTagInput.TextStyle = focusedStyle
+		return m.bulkTagInput.Focus()
+	}
+	m.bulkTagInput.Blur()
+	m.bulkTagInput.PromptStyle = blurredStyle
+	m.bulkTagInput.TextStyle = blurredStyle
+	return nil
+}
+
+// applyBulk does the focused button's action to the selected channels, then
+// goes back to the channel view with a summary of what changed.
+func (m *Model) applyBulk() tea.Cmd {
+	action := bulkButtons[m.bulkFocus-1].action
+
+	var tagId int64
+	var tagName string
+	if action == channel.BulkAddTag || action == channel.BulkRemoveTag {
+		name := strings.TrimSpace(m.bulkTagInput.Value())
+		if name == "" {
+			m.bulkError = "enter the name of a tag first"
+			return nil
+		}
+		tagInfo, err := m.findTag(name)
+		if err != nil {
+			m.bulkError = err.Error()
+			return nil
+		}
+		tagId, tagName = tagInfo.Id(), tagInfo.Name()
+	}
+
+	result, err := channel.Bulk(slices.Sorted(maps.Keys(m.bulkSelected)), action, tagId, tagName)
+	if err != nil {
+		m.bulkError = err.Error()
+		return nil
+	}
+
+	m.bulkError = ""
+	clear(m.bulkSelected)
+	m.channels.LoadEntriesFromDb()
+	m.tags.LoadEntriesFromDb()
+	m.current = "channel"
+	m.setChannelList(m.list.Width(), m.list.Height())
+	return m.list.NewStatusMessage(result.String())
+}
+
+func (m Model) bulkView() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Change the %d selected channels\n\n", len(m.bulkSelected))
+	fmt.Fprintf(&b, "%24s: %s\n", "tag", m.bulkTagInput.View())
+	if m.bulkError != "" {
+		fmt.Fprintf(&b, "%24s  %s\n", "", errorStyle.Render(m.bulkError))
+	}
+	b.WriteRune('\n')
+
+	var buttons []string
+	for i, button := range bulkButtons {
+		if m.bulkFocus == i+1 {
+			buttons = append(buttons, focusedButtonStyle.Render(button.label))
+		} else {
+			buttons = append(buttons, blurredButtonStyle.Render(button.label))
+		}
+	}
+	b.WriteString(strings.Join(buttons, "  "))
+	b.WriteString("\n\nArchiving marks the channels as unsubscribed, and syncing from youtube leaves them that way.\n")
+
+	_, h, _ := term.GetSize(os.Stdout.Fd())
+	// the 5 is the help height (plus some)
+	height := h - strings.Count(b.String(), "\n") - 5
+	if height > 0 {
+		b.WriteString(strings.Repeat("\n", height))
+	}
+
+	help := help.New()
+	help.ShowAll = true
+	b.WriteString(help.View(newBulkKeyMap()))
+
+	return b.String()
+}
@@ -16,16 +16,20 @@ import (
 	"fmt"
 	"io"
 	"maps"
+	"os"
 	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
-	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/textarea"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
 	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/query"
 )
 
 var channels channel.Channels
@@ -59,12 +63,22 @@ var channelModifyKeyList = map[string]key.Binding{
 		key.WithKeys(" "),
 		key.WithHelp("<space>", "select or unselect tag"),
 	),
+	"newlineKey": key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("<enter>", "start a new line"),
+	),
+	"editorKey": key.NewBinding(
+		key.WithKeys("ctrl+e"),
+		key.WithHelp("<ctrl-e>", "edit notes in $EDITOR"),
+	),
 }
 
 type channelModifyNotesKeyMap struct {
-	NextKey key.Binding
-	PrevKey key.Binding
-	EscKey  key.Binding
+	NextKey    key.Binding
+	PrevKey    key.Binding
+	EscKey     key.Binding
+	NewlineKey key.Binding
+	EditorKey  key.Binding
 }
 
 func (k channelModifyNotesKeyMap) ShortHelp() []key.Binding {
@@ -73,15 +87,17 @@ func (k channelModifyNotesKeyMap) ShortHelp() []key.Binding {
 func (k channelModifyNotesKeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.NextKey, k.PrevKey, k.EscKey},
-		{},
+		{k.NewlineKey, k.EditorKey},
 	}
 }
 
 func newChannelModifyNotesKeyMap() *channelModifyNotesKeyMap {
 	return &channelModifyNotesKeyMap{
-		NextKey: channelModifyKeyList["nextKey"],
-		PrevKey: channelModifyKeyList["prevKey"],
-		EscKey:  channelModifyKeyList["escKey"],
+		NextKey:    channelModifyKeyList["nextKey"],
+		PrevKey:    channelModifyKeyList["prevKey"],
+		EscKey:     channelModifyKeyList["escKey"],
+		NewlineKey: channelModifyKeyList["newlineKey"],
+		EditorKey:  channelModifyKeyList["editorKey"],
 	}
 }
 
@@ -142,7 +158,12 @@ func newChannelModifySubmitKeyMap() *channelModifySubmitKeyMap {
 	}
 }
 
-type channelListItemDelegate struct{}
+type channelListItemDelegate struct {
+	// selected are the ids of the channels selected for bulk changes
+	selected map[string]bool
+	// showUpload adds when the channel last uploaded to its name
+	showUpload bool
+}
 
 func (d channelListItemDelegate) Height() int                               { return 4 }
 func (d channelListItemDelegate) Spacing() int                              { return 0 }
@@ -166,7 +187,12 @@ func (d channelListItemDelegate) Render(w io.Writer, m list.Model, index int, li
 			Background(lipgloss.Color("#" + tmpTag.BgColour()))
 
 		tagName := tmpTag.Name()
-		channelTags[tagName] = style.Render(tmpTag.Name())
+		// tags added by an auto-tagging rule get a * after them
+		if slices.Contains(item.RuleTags(), tagId) {
+			channelTags[tagName] = style.Render(tmpTag.Name() + "*")
+		} else {
+			channelTags[tagName] = style.Render(tmpTag.Name())
+		}
 	}
 
 	sortedTags := slices.Sorted(maps.Keys(channelTags))
@@ -181,7 +207,27 @@ func (d channelListItemDelegate) Render(w io.Writer, m list.Model, index int, li
 		out = "<none>"
 	}
 
-	str := fmt.Sprintf("%s\n%s\n%s\n", item.Name(), descLines[0], "tags: "+out)
+	var name = item.Name()
+	if d.selected[item.Id()] {
+		name = "[x] " + name
+	}
+	if item.Archived() {
+		name += fmt.Sprintf(" (archived %s)", item.UnsubscribedAt().Local().Format(time.DateOnly))
+	} else if item.Unsubscribed() {
+		name += fmt.Sprintf(" (unsubscribed %s)", item.UnsubscribedAt().Local().Format(time.DateOnly))
+	}
+	if d.showUpload {
+		switch {
+		case item.FeedCheckedAt().IsZero():
+			name += " (feed not checked)"
+		case item.LatestVideo().Id == "":
+			name += " (no uploads)"
+		default:
+			name += fmt.Sprintf(" (last upload %s)", item.LatestVideo().PublishedAt.Local().Format(time.DateOnly))
+		}
+	}
+
+	str := fmt.Sprintf("%s\n%s\n%s\n", name, descLines[0], "tags: "+out)
 
 	fn := blurredListStyle.Render
 	if index == m.Index() {
@@ -191,9 +237,80 @@ func (d channelListItemDelegate) Render(w io.Writer, m list.Model, index int, li
 	fmt.Fprint(w, fn(str))
 }
 
-func (m Model) generateChannelItems(untaggedFilter bool) []list.Item {
+// setChannelListTitle sets the channel view's title to show the filters and
+// how many channels are selected.
+func (m *Model) setChannelListTitle() {
+	m.list.Title = "YSM - Channel View"
+	if unsubscribedFilter {
+		m.list.Title += " (unsubscribed)"
+	}
+	if tagFilter != 0 {
+		m.list.Title += " (tag: " + m.tags.Path(tagFilter) + ")"
+	}
+	if channelQuery != "" {
+		m.list.Title += " (query: " + channelQuery + ")"
+	}
+	if sortByUpload {
+		m.list.Title += " (by last upload)"
+	}
+	if len(m.bulkSelected) > 0 {
+		m.list.Title += fmt.Sprintf(" (%d selected)", len(m.bulkSelected))
+	}
+}
+
+// setChannelList replaces the list with the channel view, using the current
+// filters.
+func (m *Model) setChannelList(width int, height int) {
+	q := m.parseChannelQuery()
+	m.list = list.New(m.generateChannelItems(untaggedFilter, unsubscribedFilter, tagFilter, q), channelListItemDelegate{selected: m.bulkSelected, showUpload: sortByUpload}, width, height)
+	m.setChannelListTitle()
+	m.list.Styles.Title = titleStyle
+	listKeys := newListKeyMap()
+	m.list.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{
+			listKeys.tKey,
+			listKeys.pKey,
+			listKeys.enterKey,
+			listKeys.gKey,
+			listKeys.uKey,
+			listKeys.aKey,
+			listKeys.fKey,
+		}
+	}
+	m.list.AdditionalFullHelpKeys = func() []key.Binding {
+		return []key.Binding{
+			listKeys.tKey,
+			listKeys.pKey,
+			listKeys.enterKey,
+			listKeys.gKey,
+			listKeys.uKey,
+			listKeys.aKey,
+			listKeys.fKey,
+			listKeys.vKey,
+			listKeys.searchKey,
+			listKeys.oKey,
+			listKeys.spaceKey,
+			listKeys.selectAllKey,
+			listKeys.bKey,
+			listKeys.undoKey,
+			listKeys.redoKey,
+		}
+	}
+}
+
+// generateChannelItems returns the channels to show. If tagFilter isn't 0,
+// only channels with that tag or any of its sub-tags are included, and only
+// channels matching q are included. They're sorted by name, or if
+// sortByUpload is set, by their last upload, the longest quiet first and the
+// ones whose feed hasn't been checked last.
+func (m Model) generateChannelItems(untaggedFilter bool, unsubscribedFilter bool, tagFilter int64, q query.Query) []list.Item {
 	var items []list.Item
 
+	var matchTags map[int64]bool
+	if tagFilter != 0 {
+		matchTags = m.tags.WithDescendants(tagFilter)
+	}
+
 	keys := make([]string, 0, len(m.channels.ByName()))
 	for k := range m.channels.ByName() {
 		keys = append(keys, k)
@@ -202,16 +319,50 @@ func (m Model) generateChannelItems(untaggedFilter bool) []list.Item {
 
 	for _, key := range keys {
 		var channel = m.channels.ByName()[key]
+		if unsubscribedFilter != channel.Unsubscribed() {
+			continue
+		}
+		if matchTags != nil && !slices.ContainsFunc(channel.Tags(), func(id int64) bool { return matchTags[id] }) {
+			continue
+		}
+		if !q.Match(channel) {
+			continue
+		}
 		if !untaggedFilter || len(channel.Tags()) == 0 {
 			items = append(items, channel)
 		}
 	}
 
+	if sortByUpload {
+		// a stable sort, so channels that uploaded at the same time stay in
+		// name order
+		slices.SortStableFunc(items, func(a, b list.Item) int {
+			chanA, chanB := a.(channel.Channel), b.(channel.Channel)
+			if checkedA, checkedB := !chanA.FeedCheckedAt().IsZero(), !chanB.FeedCheckedAt().IsZero(); checkedA != checkedB {
+				if checkedA {
+					return -1
+				}
+				return 1
+			}
+			return chanA.LatestVideo().PublishedAt.Compare(chanB.LatestVideo().PublishedAt)
+		})
+	}
+
 	// os.WriteFile("debug-items.log", []byte(dump.Format(items)), 0644)
 
 	return items
 }
 
+// startChannelModify opens the modify page for a channel.
+func (m *Model) startChannelModify(chanInfo channel.Channel) {
+	m.channelModifyHeaders = m.createChannelModifyHeader(chanInfo)
+	m.channelModifyInputs = m.createChannelModifyForm(chanInfo)
+	m.selectedChannel = chanInfo
+	m.channelModifyFocus = 0
+	m.channelModifyError = ""
+	m.current = "channelModify"
+}
+
 func (m Model) createChannelModifyHeader(channel channel.Channel) []string {
 	channelModifyHeaders := make([]string, 2)
 
@@ -221,23 +372,48 @@ func (m Model) createChannelModifyHeader(channel channel.Channel) []string {
 	return channelModifyHeaders
 }
 
-func (m Model) createChannelModifyForm(channel channel.Channel) []textinput.Model {
-	channelModifyInputs := make([]textinput.Model, 1)
+func (m Model) createChannelModifyForm(channel channel.Channel) []textarea.Model {
+	channelModifyInputs := make([]textarea.Model, 1)
 
-	t := textinput.New()
+	t := textarea.New()
 	t.Cursor.Style = cursorStyle
-	t.CharLimit = 4000
-	t.Placeholder = "notes about channel"
+	t.CharLimit = 0
+	t.ShowLineNumbers = false
+	t.Placeholder = "notes about channel, in markdown"
+	t.FocusedStyle.Prompt = focusedButtonStyle
+	t.BlurredStyle.Prompt = blurredButtonStyle
+	w, _, _ := term.GetSize(os.Stdout.Fd())
+	t.SetWidth(max(w-6, 20))
+	t.SetHeight(8)
 	t.SetValue(channel.Notes())
-	t.Focus()
-	t.PromptStyle = focusedButtonStyle
-	t.TextStyle = focusedButtonStyle
 
 	channelModifyInputs[0] = t
+	// the textarea keeps a pointer to its current style, so it has to be
+	// focused where it's going to stay
+	channelModifyInputs[0].Focus()
 
 	return channelModifyInputs
 }
 
+// notesKeepKey reports whether the notes field, if focused, should get msg
+// rather than it moving the focus, which is when the cursor can move up or
+// down a line.
+func (m Model) notesKeepKey(msg tea.KeyMsg) bool {
+	if m.channelModifyFocus != 0 {
+		return false
+	}
+
+	notes := m.channelModifyInputs[0]
+	switch msg.String() {
+	case "up":
+		return notes.Line() > 0
+	case "down":
+		return notes.Line() < notes.LineCount()-1
+	}
+
+	return false
+}
+
 func (m Model) updateChannelModifyInput(msg tea.Msg) tea.Cmd {
 	cmds := make([]tea.Cmd, len(m.channelModifyInputs))
 
@@ -13,9 +13,15 @@ You should have received a copy of the GNU General Public License along with thi
 package tui
 
 import (
+	"fmt"
+	"slices"
+
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"repo.joyrex.net/ejstacey/ysm/generator"
+	"repo.joyrex.net/ejstacey/ysm/tag"
+	"repo.joyrex.net/ejstacey/ysm/utils"
 )
 
 var generatePageKeyList = map[string]key.Binding{
@@ -45,17 +51,22 @@ var generatePageKeyList = map[string]key.Binding{
 	),
 	"spaceKey": key.NewBinding(
 		key.WithKeys(" "),
-		key.WithHelp("<space>", "select or unselect tag"),
+		key.WithHelp("<space>", "change tag visibility"),
+	),
+	"profileKey": key.NewBinding(
+		key.WithKeys("ctrl+p"),
+		key.WithHelp("<ctrl-p>", "switch to the next profile"),
 	),
 }
 
 type generatePageInputKeyMap struct {
-	NextKey  key.Binding
-	PrevKey  key.Binding
-	SpaceKey key.Binding
-	LeftKey  key.Binding
-	RightKey key.Binding
-	EscKey   key.Binding
+	NextKey    key.Binding
+	PrevKey    key.Binding
+	SpaceKey   key.Binding
+	LeftKey    key.Binding
+	RightKey   key.Binding
+	EscKey     key.Binding
+	ProfileKey key.Binding
 }
 
 func (k generatePageInputKeyMap) ShortHelp() []key.Binding {
@@ -65,22 +76,25 @@ func (k generatePageInputKeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.NextKey, k.PrevKey, k.EscKey},
 		{k.LeftKey, k.RightKey, k.SpaceKey},
+		{k.ProfileKey},
 	}
 }
 
 func newGeneratePageInputKeyMap() generatePageInputKeyMap {
 	return generatePageInputKeyMap{
-		NextKey: generatePageKeyList["nextKey"],
-		PrevKey: generatePageKeyList["prevKey"],
-		EscKey:  generatePageKeyList["escKey"],
+		NextKey:    generatePageKeyList["nextKey"],
+		PrevKey:    generatePageKeyList["prevKey"],
+		EscKey:     generatePageKeyList["escKey"],
+		ProfileKey: generatePageKeyList["profileKey"],
 	}
 }
 
 type generatePageButtonKeyMap struct {
-	NextKey  key.Binding
-	PrevKey  key.Binding
-	EnterKey key.Binding
-	EscKey   key.Binding
+	NextKey    key.Binding
+	PrevKey    key.Binding
+	EnterKey   key.Binding
+	EscKey     key.Binding
+	ProfileKey key.Binding
 }
 
 func (k generatePageButtonKeyMap) ShortHelp() []key.Binding {
@@ -91,25 +105,28 @@ func (k generatePageButtonKeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.NextKey, k.PrevKey, k.EscKey},
 		{k.EnterKey},
+		{k.ProfileKey},
 	}
 }
 
 func newGeneratePageButtonKeyMap() generatePageButtonKeyMap {
 	return generatePageButtonKeyMap{
-		NextKey:  generatePageKeyList["nextKey"],
-		PrevKey:  generatePageKeyList["prevKey"],
-		EnterKey: generatePageKeyList["enterKey"],
-		EscKey:   generatePageKeyList["escKey"],
+		NextKey:    generatePageKeyList["nextKey"],
+		PrevKey:    generatePageKeyList["prevKey"],
+		EnterKey:   generatePageKeyList["enterKey"],
+		EscKey:     generatePageKeyList["escKey"],
+		ProfileKey: generatePageKeyList["profileKey"],
 	}
 }
 
 type generatePageSelectKeyMap struct {
-	NextKey  key.Binding
-	PrevKey  key.Binding
-	SpaceKey key.Binding
-	LeftKey  key.Binding
-	RightKey key.Binding
-	EscKey   key.Binding
+	NextKey    key.Binding
+	PrevKey    key.Binding
+	SpaceKey   key.Binding
+	LeftKey    key.Binding
+	RightKey   key.Binding
+	EscKey     key.Binding
+	ProfileKey key.Binding
 }
 
 func (k generatePageSelectKeyMap) ShortHelp() []key.Binding {
@@ -119,22 +136,86 @@ func (k generatePageSelectKeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.NextKey, k.PrevKey, k.EscKey},
 		{k.LeftKey, k.RightKey, k.SpaceKey},
+		{k.ProfileKey},
 	}
 }
 
 func newGeneratePageSelectKeyMap() *generatePageSelectKeyMap {
 	return &generatePageSelectKeyMap{
-		NextKey:  channelModifyKeyList["nextKey"],
-		PrevKey:  channelModifyKeyList["prevKey"],
-		SpaceKey: channelModifyKeyList["spaceKey"],
-		LeftKey:  channelModifyKeyList["leftKey"],
-		RightKey: channelModifyKeyList["rightKey"],
-		EscKey:   channelModifyKeyList["escKey"],
+		NextKey:    channelModifyKeyList["nextKey"],
+		PrevKey:    channelModifyKeyList["prevKey"],
+		SpaceKey:   channelModifyKeyList["spaceKey"],
+		LeftKey:    channelModifyKeyList["leftKey"],
+		RightKey:   channelModifyKeyList["rightKey"],
+		EscKey:     channelModifyKeyList["escKey"],
+		ProfileKey: generatePageKeyList["profileKey"],
+	}
+}
+
+// generateProfile returns the profile the generate page was filled in from.
+func (m Model) generateProfile() utils.GeneratorProfile {
+	profiles := m.settings.Generator.ProfileList()
+	if m.generatePageProfile >= len(profiles) {
+		return profiles[0]
 	}
+
+	return profiles[m.generatePageProfile]
 }
 
-func (m Model) createGeneratePageForm() []textinput.Model {
-	generatePageInputs := make([]textinput.Model, 3)
+// selectGenerateProfile fills the generate page in from a profile.
+func (m *Model) selectGenerateProfile(i int) {
+	m.generatePageProfile = i
+	m.generatePageInputs = m.createGeneratePageForm(m.generateProfile())
+	m.generatePageFocus = 0
+	m.generatePageError = ""
+}
+
+// profileHiddenTags returns the names of the tags the generate page's profile
+// leaves out with its ShowTags and HideTags, on top of the private ones.
+func (m Model) profileHiddenTags() ([]string, error) {
+	p := m.generateProfile()
+	shownTags, err := generator.ShownTags(m.tags, p.ShowTags)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", p.Name, err)
+	}
+	hiddenTags, err := generator.HiddenTags(m.tags, p.HideTags)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", p.Name, err)
+	}
+
+	var names []string
+	for id, tagInfo := range m.tags.ById() {
+		_, shown := shownTags[id]
+		_, hidden := hiddenTags[id]
+		if hidden || (shownTags != nil && !shown) {
+			names = append(names, tagInfo.Name())
+		}
+	}
+	slices.Sort(names)
+
+	return names, nil
+}
+
+// cycleTagVisibility moves a tag on to its next visibility, from public to
+// unlisted to private and back round. It's saved straight away, so it's the
+// same next time and for the command line.
+func (m *Model) cycleTagVisibility(tagInfo tag.Tag) error {
+	i := slices.Index(tag.Visibilities, tagInfo.Visibility())
+	err := tagInfo.SetVisibility(tag.Visibilities[(i+1)%len(tag.Visibilities)])
+	if err != nil {
+		return err
+	}
+
+	m.tags.LoadEntriesFromDb()
+	if m.previous == "tag" {
+		m.list.SetItems(m.generateTagItems())
+	}
+
+	return nil
+}
+
+func (m Model) createGeneratePageForm(p utils.GeneratorProfile) []textinput.Model {
+	generatePageInputs := make([]textinput.Model, 4)
 
 	var t textinput.Model
 	for i := range generatePageInputs {
@@ -147,19 +228,25 @@ func (m Model) createGeneratePageForm() []textinput.Model {
 		case 0:
 			t.Placeholder = "location of template file relative to running directory (or absolute path)"
 			t.CharLimit = 256
-			t.SetValue(m.settings.Generator.TemplateFile)
+			t.SetValue(p.TemplateFile)
 			t.Focus()
 			t.PromptStyle = focusedStyle
 			t.TextStyle = focusedStyle
 		case 1:
 			t.Placeholder = "location of output file relative to running directory (or absolute path)"
 			t.CharLimit = 256
-			t.SetValue(m.settings.Generator.OutputFile)
+			t.SetValue(p.OutputFile)
 		case 2:
 			t.Placeholder = "title for the page"
 			t.CharLimit = 512
 			t.Width = 512
-			t.SetValue(m.settings.Generator.Title)
+			t.SetValue(p.Title)
+		// comes after the tag selection
+		case 3:
+			t.Placeholder = "only include channels matching this query, eg tag:music AND NOT notes:private"
+			t.CharLimit = 1000
+			t.Width = 512
+			t.SetValue(p.Query)
 		}
 
 		generatePageInputs[i] = t
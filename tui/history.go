@@ -0,0 +1,57 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package tui
+
+import (
+	"errors"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"repo.joyrex.net/ejstacey/ysm/history"
+)
+
+// undoRedo undoes or redoes the newest change in the history, then reloads
+// the list that's showing so it reflects the db again.
+func (m *Model) undoRedo(undo bool) tea.Cmd {
+	var entry history.Entry
+	var err error
+	if undo {
+		entry, err = history.Undo()
+	} else {
+		entry, err = history.Redo()
+	}
+	if errors.Is(err, history.ErrNothingToUndo) || errors.Is(err, history.ErrNothingToRedo) {
+		return m.list.NewStatusMessage(err.Error())
+	}
+	if err != nil {
+		return m.list.NewStatusMessage(errorStyle.Render(err.Error()))
+	}
+
+	m.channels.LoadEntriesFromDb()
+	m.tags.LoadEntriesFromDb()
+
+	index := m.list.Index()
+	switch m.current {
+	case "channel":
+		m.setChannelList(m.list.Width(), m.list.Height())
+	case "tag":
+		m.list.SetItems(m.generateTagItems())
+	}
+	if index < len(m.list.Items()) {
+		m.list.Select(index)
+	}
+
+	if undo {
+		return m.list.NewStatusMessage("Undid: " + entry.Description)
+	}
+	return m.list.NewStatusMessage("Redid: " + entry.Description)
+}
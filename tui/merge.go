@@ -0,0 +1,319 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package tui
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+	"repo.joyrex.net/ejstacey/ysm/tag"
+)
+
+var tagMergeKeyList = map[string]key.Binding{
+	"nextKey": key.NewBinding(
+		key.WithKeys("down", "tab"),
+		key.WithHelp("<down>/<tab>", "next option"),
+	),
+	"prevKey": key.NewBinding(
+		key.WithKeys("up", "shift+tab"),
+		key.WithHelp("<up>/<shift-tab>", "previous option"),
+	),
+	"spaceKey": key.NewBinding(
+		key.WithKeys(" "),
+		key.WithHelp("<space>", "pick or unpick channel"),
+	),
+	"enterKey": key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("<enter>", "select button"),
+	),
+	"escKey": key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("<esc>", "back out to tag view"),
+	),
+}
+
+type tagMergeKeyMap struct {
+	NextKey  key.Binding
+	PrevKey  key.Binding
+	EnterKey key.Binding
+	EscKey   key.Binding
+}
+
+func (k tagMergeKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.NextKey, k.PrevKey, k.EnterKey, k.EscKey}
+}
+func (k tagMergeKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.NextKey, k.PrevKey, k.EscKey},
+		{k.EnterKey},
+	}
+}
+
+func newTagMergeKeyMap() tagMergeKeyMap {
+	return tagMergeKeyMap{
+		NextKey:  tagMergeKeyList["nextKey"],
+		PrevKey:  tagMergeKeyList["prevKey"],
+		EnterKey: tagMergeKeyList["enterKey"],
+		EscKey:   tagMergeKeyList["escKey"],
+	}
+}
+
+type tagSplitKeyMap struct {
+	NextKey  key.Binding
+	PrevKey  key.Binding
+	SpaceKey key.Binding
+	EnterKey key.Binding
+	EscKey   key.Binding
+}
+
+func (k tagSplitKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.NextKey, k.PrevKey, k.SpaceKey, k.EnterKey, k.EscKey}
+}
+func (k tagSplitKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.NextKey, k.PrevKey, k.EscKey},
+		{k.SpaceKey, k.EnterKey},
+	}
+}
+
+func newTagSplitKeyMap() tagSplitKeyMap {
+	return tagSplitKeyMap{
+		NextKey:  tagMergeKeyList["nextKey"],
+		PrevKey:  tagMergeKeyList["prevKey"],
+		SpaceKey: tagMergeKeyList["spaceKey"],
+		EnterKey: tagMergeKeyList["enterKey"],
+		EscKey:   tagMergeKeyList["escKey"],
+	}
+}
+
+func (m Model) createTagMergeInput(placeholder string) textinput.Model {
+	t := textinput.New()
+	t.Cursor.Style = cursorStyle
+	t.CharLimit = 256
+	t.Placeholder = placeholder
+	t.Focus()
+	t.PromptStyle = focusedStyle
+	t.TextStyle = focusedStyle
+
+	return t
+}
+
+// moveTagMergeFocus moves between the input and the buttons of the merge
+// page, or the input, channels and buttons of the split page. last is the
+// focus of the last button.
+func (m *Model) moveTagMergeFocus(s string, last int) tea.Cmd {
+	if s == "up" || s == "shift+tab" {
+		m.tagMergeFocus--
+	} else {
+		m.tagMergeFocus++
+	}
+
+	if m.tagMergeFocus > last {
+		m.tagMergeFocus = 0
+	} else if m.tagMergeFocus < 0 {
+		m.tagMergeFocus = last
+	}
+
+	if m.tagMergeFocus == 0 {
+		m.tagMergeInput.PromptStyle = focusedStyle
+		m.tagMergeInput.TextStyle = focusedStyle
+		return m.tagMergeInput.Focus()
+	}
+	m.tagMergeInput.Blur()
+	m.tagMergeInput.PromptStyle = blurredStyle
+	m.tagMergeInput.TextStyle = blurredStyle
+	return nil
+}
+
+// startTagMerge opens the merge page for the selected tag.
+func (m *Model) startTagMerge() {
+	m.selectedTag = m.list.SelectedItem().(tag.Tag)
+	m.tagMergeInput = m.createTagMergeInput("name of the tag to merge it into")
+	m.tagMergeFocus = 0
+	m.tagMergeError = ""
+	m.current = "tagMerge"
+}
+
+// startTagSplit opens the split page for the selected tag, with its
+// channels sorted by name and none of them picked.
+func (m *Model) startTagSplit() {
+	m.selectedTag = m.list.SelectedItem().(tag.Tag)
+	m.tagMergeInput = m.createTagMergeInput("name of the new tag")
+	m.tagMergeFocus = 0
+	m.tagMergeError = ""
+	m.tagSplitChannels = slices.Clone(m.selectedTag.Channels())
+	slices.SortFunc(m.tagSplitChannels, func(a, b string) int {
+		return strings.Compare(strings.ToLower(m.channels.ById()[a].Name()), strings.ToLower(m.channels.ById()[b].Name()))
+	})
+	m.tagSplitPicked = make(map[string]bool)
+	m.current = "tagSplit"
+}
+
+// applyTagMerge merges the selected tag into the one named in the input,
+// then goes back to the tag view with a summary.
+func (m *Model) applyTagMerge() tea.Cmd {
+	name := strings.TrimSpace(m.tagMergeInput.Value())
+	if name == "" {
+		m.tagMergeError = "enter the name of the tag to merge it into first"
+		return nil
+	}
+	into, err := m.findTag(name)
+	if err != nil {
+		m.tagMergeError = err.Error()
+		return nil
+	}
+
+	gained, err := m.selectedTag.Merge(into)
+	if err != nil {
+		m.tagMergeError = err.Error()
+		return nil
+	}
+
+	m.reloadTagList()
+	return m.list.NewStatusMessage(fmt.Sprintf("Merged %s into %s, %d channels newly tagged", m.selectedTag.Name(), into.Name(), gained))
+}
+
+// applyTagSplit moves the picked channels to a new tag, then goes back to
+// the tag view with a summary.
+func (m *Model) applyTagSplit() tea.Cmd {
+	name := strings.TrimSpace(m.tagMergeInput.Value())
+	if name == "" {
+		m.tagMergeError = "enter a name for the new tag first"
+		return nil
+	}
+	if _, err := m.findTag(name); err == nil {
+		m.tagMergeError = fmt.Sprintf("there's already a tag named %q", name)
+		return nil
+	}
+
+	var picked []string
+	for _, channelId := range m.tagSplitChannels {
+		if m.tagSplitPicked[channelId] {
+			picked = append(picked, channelId)
+		}
+	}
+
+	newTag, err := m.selectedTag.Split(name, picked)
+	if err != nil {
+		m.tagMergeError = err.Error()
+		return nil
+	}
+
+	m.reloadTagList()
+	return m.list.NewStatusMessage(fmt.Sprintf("Moved %d channels from %s to %s", len(picked), m.selectedTag.Name(), newTag.Name()))
+}
+
+func (m *Model) reloadTagList() {
+	m.tagMergeError = ""
+	m.tags.LoadEntriesFromDb()
+	m.channels.LoadEntriesFromDb()
+	m.list.SetItems(m.generateTagItems())
+	m.current = "tag"
+}
+
+func (m Model) tagMergeView() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Merge %s into another tag\n\n", m.selectedTag.Name())
+	fmt.Fprintf(&b, "%24s: %s\n", "merge into", m.tagMergeInput.View())
+	if m.tagMergeError != "" {
+		fmt.Fprintf(&b, "%24s  %s\n", "", errorStyle.Render(m.tagMergeError))
+	}
+	b.WriteRune('\n')
+
+	b.WriteString(m.tagMergeButtons(1, "[ Merge ]"))
+	fmt.Fprintf(&b, "\n\nIts %d channels, rules and sub-tags move to the other tag, and %s is deleted. Its name keeps finding the other tag.\n", len(m.selectedTag.Channels()), m.selectedTag.Name())
+
+	return m.withTagMergeHelp(b.String(), newTagMergeKeyMap())
+}
+
+func (m Model) tagSplitView() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Move some of the channels tagged %s to a new tag\n\n", m.selectedTag.Name())
+	fmt.Fprintf(&b, "%24s: %s\n", "new tag", m.tagMergeInput.View())
+	if m.tagMergeError != "" {
+		fmt.Fprintf(&b, "%24s  %s\n", "", errorStyle.Render(m.tagMergeError))
+	}
+	b.WriteRune('\n')
+
+	// only show the channels around the focused one if they don't all fit
+	_, h, _ := term.GetSize(os.Stdout.Fd())
+	rows := max(h-14, 3)
+	start := 0
+	if focused := m.tagMergeFocus - 1; focused >= rows {
+		start = min(focused-rows+1, len(m.tagSplitChannels)-rows)
+	}
+	end := min(start+rows, len(m.tagSplitChannels))
+	if start > 0 {
+		b.WriteString("  ...\n")
+	}
+	for i := start; i < end; i++ {
+		channelId := m.tagSplitChannels[i]
+		box := "[ ]"
+		if m.tagSplitPicked[channelId] {
+			box = "[x]"
+		}
+		line := box + " " + m.channels.ById()[channelId].Name()
+		if m.tagMergeFocus == i+1 {
+			b.WriteString("  " + focusedStyle.Render(line) + "\n")
+		} else {
+			b.WriteString("  " + blurredStyle.Render(line) + "\n")
+		}
+	}
+	if end < len(m.tagSplitChannels) {
+		b.WriteString("  ...\n")
+	}
+	b.WriteRune('\n')
+
+	b.WriteString(m.tagMergeButtons(len(m.tagSplitChannels)+1, "[ Split ]"))
+	b.WriteRune('\n')
+
+	return m.withTagMergeHelp(b.String(), newTagSplitKeyMap())
+}
+
+// tagMergeButtons renders cancel and the action button, whose focuses are
+// first and first+1.
+func (m Model) tagMergeButtons(first int, label string) string {
+	var buttons []string
+	for i, button := range []string{"[ Cancel ]", label} {
+		if m.tagMergeFocus == first+i {
+			buttons = append(buttons, focusedButtonStyle.Render(button))
+		} else {
+			buttons = append(buttons, blurredButtonStyle.Render(button))
+		}
+	}
+
+	return strings.Join(buttons, "  ")
+}
+
+func (m Model) withTagMergeHelp(out string, keys help.KeyMap) string {
+	_, h, _ := term.GetSize(os.Stdout.Fd())
+	// the 5 is the help height (plus some)
+	height := h - strings.Count(out, "\n") - 5
+	if height > 0 {
+		out += strings.Repeat("\n", height)
+	}
+
+	help := help.New()
+	help.ShowAll = true
+
+	return out + help.View(keys)
+}
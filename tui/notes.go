@@ -0,0 +1,218 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/term"
+	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/markdown"
+)
+
+var channelDetailKeyList = map[string]key.Binding{
+	"upKey": key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("<up>", "scroll up"),
+	),
+	"downKey": key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("<down>", "scroll down"),
+	),
+	"enterKey": key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("<enter>", "modify the channel"),
+	),
+	"escKey": key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("<esc>", "back out to channel view"),
+	),
+}
+
+type channelDetailKeyMap struct {
+	UpKey    key.Binding
+	DownKey  key.Binding
+	EnterKey key.Binding
+	EscKey   key.Binding
+}
+
+func (k channelDetailKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.UpKey, k.DownKey, k.EnterKey, k.EscKey}
+}
+func (k channelDetailKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.UpKey, k.DownKey, k.EscKey},
+		{k.EnterKey},
+	}
+}
+
+func newChannelDetailKeyMap() channelDetailKeyMap {
+	return channelDetailKeyMap{
+		UpKey:    channelDetailKeyList["upKey"],
+		DownKey:  channelDetailKeyList["downKey"],
+		EnterKey: channelDetailKeyList["enterKey"],
+		EscKey:   channelDetailKeyList["escKey"],
+	}
+}
+
+// notesEditedMsg is sent when the editor started by editNotes exits.
+type notesEditedMsg struct {
+	notes string
+	err   error
+}
+
+// notesEditor returns the command line of the user's editor.
+func notesEditor() []string {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		} else {
+			editor = "vi"
+		}
+	}
+
+	// the setting can have arguments, like "code --wait"
+	return strings.Fields(editor)
+}
+
+// editNotes hands notes to the user's editor in a temporary file, suspending
+// the TUI until it exits, then sends a notesEditedMsg with the file's contents.
+func editNotes(notes string) tea.Cmd {
+	fail := func(err error) tea.Cmd {
+		return func() tea.Msg { return notesEditedMsg{err: err} }
+	}
+
+	f, err := os.CreateTemp("", "ysm-notes-*.md")
+	if err != nil {
+		return fail(err)
+	}
+	_, err = f.WriteString(notes)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fail(err)
+	}
+
+	editor := notesEditor()
+	cmd := exec.Command(editor[0], append(editor[1:], f.Name())...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		defer os.Remove(f.Name())
+		if err != nil {
+			return notesEditedMsg{err: err}
+		}
+
+		edited, err := os.ReadFile(f.Name())
+		if err != nil {
+			return notesEditedMsg{err: err}
+		}
+
+		// editors like to end files with a newline, which isn't part of the notes
+		return notesEditedMsg{notes: strings.TrimRight(string(edited), "\r\n")}
+	})
+}
+
+// startChannelDetail opens the detail page for a channel.
+func (m *Model) startChannelDetail(chanInfo channel.Channel) {
+	w, h, _ := term.GetSize(os.Stdout.Fd())
+	// the 8 is the page padding and the help
+	m.channelDetail = viewport.New(max(w-4, 20), max(h-8, 5))
+	m.channelDetail.SetContent(m.channelDetailContent(chanInfo, m.channelDetail.Width))
+	m.selectedChannel = chanInfo
+	m.current = "channelDetail"
+}
+
+func (m Model) channelDetailContent(chanInfo channel.Channel, width int) string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render(chanInfo.Name()))
+	if chanInfo.Unsubscribed() {
+		b.WriteString(" (unsubscribed)")
+	}
+	fmt.Fprintf(&b, "\n\n%s\n\n", chanInfo.Url())
+
+	if details := chanInfo.Details(); details.Enriched() && !details.CreatedAt.IsZero() {
+		if details.CustomUrl != "" {
+			fmt.Fprintf(&b, "Handle: %s\n", details.CustomUrl)
+		}
+		if details.SubscribersHidden {
+			b.WriteString("Subscribers: hidden\n")
+		} else {
+			fmt.Fprintf(&b, "Subscribers: %d\n", details.SubscriberCount)
+		}
+		fmt.Fprintf(&b, "Videos: %d\n", details.VideoCount)
+		if details.Country != "" {
+			fmt.Fprintf(&b, "Country: %s\n", details.Country)
+		}
+		fmt.Fprintf(&b, "Created: %s\n\n", details.CreatedAt.Local().Format(time.DateOnly))
+	}
+
+	if !chanInfo.FeedCheckedAt().IsZero() {
+		if latest := chanInfo.LatestVideo(); latest.Id == "" {
+			b.WriteString("Last upload: none\n\n")
+		} else {
+			fmt.Fprintf(&b, "Last upload: %s (%s)\n%s\n\n", ansi.Wordwrap(latest.Title, width, ""), latest.PublishedAt.Local().Format(time.DateOnly), latest.Url())
+		}
+	}
+
+	if chanInfo.Description() != "" {
+		fmt.Fprintf(&b, "%s\n\n", ansi.Wordwrap(chanInfo.Description(), width, ""))
+	}
+
+	var tagPaths []string
+	for _, tagId := range chanInfo.Tags() {
+		tagPaths = append(tagPaths, m.tags.Path(tagId))
+	}
+	slices.Sort(tagPaths)
+	if len(tagPaths) > 0 {
+		fmt.Fprintf(&b, "Tags: %s\n\n", ansi.Wordwrap(strings.Join(tagPaths, ", "), width, ""))
+	}
+
+	if chanInfo.Notes() == "" {
+		b.WriteString("No notes")
+	} else {
+		b.WriteString(markdown.ToTerminal(chanInfo.Notes(), width))
+	}
+
+	return b.String()
+}
+
+func (m Model) channelDetailView() string {
+	var b strings.Builder
+
+	b.WriteString(m.channelDetail.View())
+	b.WriteString("\n\n")
+
+	help := help.New()
+	help.ShowAll = true
+	b.WriteString(help.View(newChannelDetailKeyMap()))
+
+	return b.String()
+}
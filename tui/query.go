@@ -0,0 +1,131 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/x/term"
+	"repo.joyrex.net/ejstacey/ysm/query"
+)
+
+var channelQueryKeyList = map[string]key.Binding{
+	"enterKey": key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("<enter>", "filter with the query (empty to clear it)"),
+	),
+	"escKey": key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("<esc>", "back out to channel view"),
+	),
+}
+
+type channelQueryKeyMap struct {
+	EnterKey key.Binding
+	EscKey   key.Binding
+}
+
+func (k channelQueryKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.EnterKey, k.EscKey}
+}
+func (k channelQueryKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.EnterKey, k.EscKey},
+	}
+}
+
+func newChannelQueryKeyMap() channelQueryKeyMap {
+	return channelQueryKeyMap{
+		EnterKey: channelQueryKeyList["enterKey"],
+		EscKey:   channelQueryKeyList["escKey"],
+	}
+}
+
+func (m Model) createChannelQueryInput() textinput.Model {
+	t := textinput.New()
+	t.Cursor.Style = cursorStyle
+	t.CharLimit = 1000
+	t.Placeholder = `eg tag:music AND NOT notes:"seen it"`
+	t.SetValue(channelQuery)
+	t.Focus()
+	t.PromptStyle = focusedStyle
+	t.TextStyle = focusedStyle
+
+	return t
+}
+
+// applyChannelQuery checks the typed query and, if it's ok, filters the
+// channel view with it.
+func (m *Model) applyChannelQuery() {
+	_, err := query.Parse(m.channelQueryInput.Value(), m.tags)
+	if err != nil {
+		m.channelQueryError = err.Error()
+		return
+	}
+
+	m.channelQueryError = ""
+	channelQuery = strings.TrimSpace(m.channelQueryInput.Value())
+	m.current = "channel"
+	m.setChannelList(m.list.Width(), m.list.Height())
+	m.list.ResetSelected()
+}
+
+// parseChannelQuery returns the query the channel view is filtered with. If
+// it no longer parses, because a tag in it was renamed or deleted, it's
+// dropped.
+func (m Model) parseChannelQuery() query.Query {
+	q, err := query.Parse(channelQuery, m.tags)
+	if err != nil {
+		channelQuery = ""
+		return query.Query{}
+	}
+	return q
+}
+
+func (m Model) channelQueryView() string {
+	var b strings.Builder
+
+	b.WriteString("Filter the channels with a query\n\n")
+	fmt.Fprintf(&b, "%s\n", m.channelQueryInput.View())
+	if m.channelQueryError != "" {
+		fmt.Fprintf(&b, "%s\n", errorStyle.Render(m.channelQueryError))
+	}
+
+	b.WriteString(`
+  tag:music           channels tagged music, or any of its sub-tags
+  name:x desc:x       name or description contains x
+  notes:"watch later" notes contain "watch later"
+  x                   name, description or notes contain x
+  is:untagged         also is:tagged, is:noted, is:subscribed, is:unsubscribed and is:archived
+  AND, OR, NOT, ( )   combine terms, eg (tag:music OR tag:news) AND NOT tag:hidden.
+                      Terms next to each other are ANDed.
+`)
+
+	_, h, _ := term.GetSize(os.Stdout.Fd())
+	// the 5 is the help height (plus some)
+	height := h - strings.Count(b.String(), "\n") - 5
+	if height > 0 {
+		b.WriteString(strings.Repeat("\n", height))
+	}
+
+	help := help.New()
+	help.ShowAll = true
+	b.WriteString(help.View(newChannelQueryKeyMap()))
+
+	return b.String()
+}
@@ -0,0 +1,236 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package tui
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"repo.joyrex.net/ejstacey/ysm/channel"
+)
+
+// the matches in search results are marked with these private use characters,
+// which won't turn up in channel text, and swapped for a style when shown
+const (
+	searchMatchStart = "\ue000"
+	searchMatchEnd   = "\ue001"
+)
+
+var searchMatchStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FFFF00"))
+
+var channelSearchKeyList = map[string]key.Binding{
+	"nextKey": key.NewBinding(
+		key.WithKeys("down", "tab"),
+		key.WithHelp("<down>/<tab>", "next result"),
+	),
+	"prevKey": key.NewBinding(
+		key.WithKeys("up", "shift+tab"),
+		key.WithHelp("<up>/<shift-tab>", "previous result"),
+	),
+	"enterKey": key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("<enter>", "modify the channel"),
+	),
+	"escKey": key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("<esc>", "back out to channel view"),
+	),
+}
+
+type channelSearchKeyMap struct {
+	NextKey  key.Binding
+	PrevKey  key.Binding
+	EnterKey key.Binding
+	EscKey   key.Binding
+}
+
+func (k channelSearchKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.NextKey, k.PrevKey, k.EnterKey, k.EscKey}
+}
+func (k channelSearchKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.NextKey, k.PrevKey, k.EscKey},
+		{k.EnterKey},
+	}
+}
+
+func newChannelSearchKeyMap() channelSearchKeyMap {
+	return channelSearchKeyMap{
+		NextKey:  channelSearchKeyList["nextKey"],
+		PrevKey:  channelSearchKeyList["prevKey"],
+		EnterKey: channelSearchKeyList["enterKey"],
+		EscKey:   channelSearchKeyList["escKey"],
+	}
+}
+
+func (m Model) createChannelSearchInput() textinput.Model {
+	t := textinput.New()
+	t.Cursor.Style = cursorStyle
+	t.CharLimit = 256
+	t.Placeholder = "words in channel names, descriptions or notes"
+	t.Focus()
+	t.PromptStyle = focusedStyle
+	t.TextStyle = focusedStyle
+
+	return t
+}
+
+// runChannelSearch searches for what's been typed, if it's changed since the
+// last search.
+func (m *Model) runChannelSearch() {
+	text := m.channelSearchInput.Value()
+	if text == m.channelSearchText {
+		return
+	}
+	m.channelSearchText = text
+
+	results, err := channel.Search(text, false, searchMatchStart, searchMatchEnd, 50)
+	if err != nil {
+		m.channelSearchError = err.Error()
+		return
+	}
+
+	m.channelSearchError = ""
+	m.channelSearchResults = results
+	m.channelSearchFocus = 0
+}
+
+func (m *Model) moveChannelSearchFocus(s string) {
+	if len(m.channelSearchResults) == 0 {
+		return
+	}
+
+	if s == "up" || s == "shift+tab" {
+		m.channelSearchFocus--
+	} else {
+		m.channelSearchFocus++
+	}
+	if m.channelSearchFocus >= len(m.channelSearchResults) {
+		m.channelSearchFocus = 0
+	} else if m.channelSearchFocus < 0 {
+		m.channelSearchFocus = len(m.channelSearchResults) - 1
+	}
+}
+
+// openChannelSearchResult selects the focused result in the channel view,
+// clearing the view's filters if they hide it, and opens it for modifying.
+func (m *Model) openChannelSearchResult() {
+	if len(m.channelSearchResults) == 0 {
+		return
+	}
+	chanInfo, ok := m.channels.ById()[m.channelSearchResults[m.channelSearchFocus].ChannelId]
+	if !ok {
+		return
+	}
+
+	m.current = "channel"
+	isChannel := func(item list.Item) bool { return item.(channel.Channel).Id() == chanInfo.Id() }
+	if !slices.ContainsFunc(m.list.Items(), isChannel) {
+		untaggedFilter = false
+		unsubscribedFilter = chanInfo.Unsubscribed()
+		tagFilter = 0
+		channelQuery = ""
+		m.setChannelList(m.list.Width(), m.list.Height())
+	}
+	m.list.ResetFilter()
+	m.list.Select(slices.IndexFunc(m.list.Items(), isChannel))
+
+	m.startChannelModify(chanInfo)
+}
+
+// renderSearchMatches swaps the match markers in s for the match style.
+func renderSearchMatches(s string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, searchMatchStart)
+		if start == -1 {
+			break
+		}
+		end := strings.Index(s[start:], searchMatchEnd)
+		if end == -1 {
+			break
+		}
+		end += start
+
+		b.WriteString(s[:start])
+		b.WriteString(searchMatchStyle.Render(s[start+len(searchMatchStart) : end]))
+		s = s[end+len(searchMatchEnd):]
+	}
+	b.WriteString(s)
+
+	return b.String()
+}
+
+func (m Model) channelSearchView() string {
+	var b strings.Builder
+
+	b.WriteString("Search channel names, descriptions and notes\n\n")
+	fmt.Fprintf(&b, "%s\n", m.channelSearchInput.View())
+	if m.channelSearchError != "" {
+		fmt.Fprintf(&b, "%s\n", errorStyle.Render(m.channelSearchError))
+	}
+	b.WriteRune('\n')
+
+	_, h, _ := term.GetSize(os.Stdout.Fd())
+	// each result takes 2 lines, and the rest of the page about 12
+	rows := max((h-12)/2, 1)
+	start := 0
+	if m.channelSearchFocus >= rows {
+		start = m.channelSearchFocus - rows + 1
+	}
+	end := min(start+rows, len(m.channelSearchResults))
+
+	if m.channelSearchText != "" && len(m.channelSearchResults) == 0 && m.channelSearchError == "" {
+		b.WriteString("No channels found\n")
+	}
+	for i := start; i < end; i++ {
+		result := m.channelSearchResults[i]
+		name := renderSearchMatches(result.Name)
+		if m.channels.ById()[result.ChannelId].Unsubscribed() {
+			name += " (unsubscribed)"
+		}
+		pointer := "  "
+		if i == m.channelSearchFocus {
+			pointer = focusedStyle.Render(">") + " "
+		}
+		fmt.Fprintf(&b, "%s%s\n", pointer, name)
+
+		// the snippet is left out when it's just the name again
+		snippet := strings.Join(strings.Fields(result.Snippet), " ")
+		if snippet != result.Name {
+			fmt.Fprintf(&b, "    %s\n", renderSearchMatches(snippet))
+		} else {
+			b.WriteRune('\n')
+		}
+	}
+
+	// the 5 is the help height (plus some)
+	height := h - strings.Count(b.String(), "\n") - 5
+	if height > 0 {
+		b.WriteString(strings.Repeat("\n", height))
+	}
+
+	help := help.New()
+	help.ShowAll = true
+	b.WriteString(help.View(newChannelSearchKeyMap()))
+
+	return b.String()
+}
@@ -0,0 +1,147 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/x/term"
+	"repo.joyrex.net/ejstacey/ysm/channel"
+	"repo.joyrex.net/ejstacey/ysm/rule"
+	"repo.joyrex.net/ejstacey/ysm/utils"
+)
+
+var syncReviewKeyList = map[string]key.Binding{
+	"nextKey": key.NewBinding(
+		key.WithKeys("down", "tab"),
+		key.WithHelp("<down>/<tab>", "next change"),
+	),
+	"prevKey": key.NewBinding(
+		key.WithKeys("up", "shift+tab"),
+		key.WithHelp("<up>/<shift-tab>", "previous change"),
+	),
+	"spaceKey": key.NewBinding(
+		key.WithKeys(" "),
+		key.WithHelp("<space>", "accept or skip change"),
+	),
+	"enterKey": key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("<enter>", "apply the accepted changes"),
+	),
+	"aKey": key.NewBinding(
+		key.WithKeys("a"),
+		key.WithHelp("a", "accept and apply all changes"),
+	),
+	"escKey": key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("<esc>", "abort, apply nothing"),
+	),
+}
+
+type syncReviewKeyMap struct {
+	NextKey  key.Binding
+	PrevKey  key.Binding
+	SpaceKey key.Binding
+	EnterKey key.Binding
+	AKey     key.Binding
+	EscKey   key.Binding
+}
+
+func (k syncReviewKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.NextKey, k.PrevKey, k.SpaceKey, k.EnterKey, k.AKey, k.EscKey}
+}
+func (k syncReviewKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.NextKey, k.PrevKey, k.SpaceKey},
+		{k.EnterKey, k.AKey, k.EscKey},
+	}
+}
+
+func newSyncReviewKeyMap() syncReviewKeyMap {
+	return syncReviewKeyMap{
+		NextKey:  syncReviewKeyList["nextKey"],
+		PrevKey:  syncReviewKeyList["prevKey"],
+		SpaceKey: syncReviewKeyList["spaceKey"],
+		EnterKey: syncReviewKeyList["enterKey"],
+		AKey:     syncReviewKeyList["aKey"],
+		EscKey:   syncReviewKeyList["escKey"],
+	}
+}
+
+// applySyncChanges writes the accepted sync changes to the db and moves on to
+// the channel view.
+func (m *Model) applySyncChanges() {
+	err := m.channels.ApplyChanges(m.syncChanges)
+	utils.HandleError(err, "applying sync changes")
+	_, err = rule.ApplyAll()
+	utils.HandleError(err, "applying auto-tagging rules")
+	m.syncChanges = channel.Changeset{}
+	m.channels.LoadEntriesFromDb()
+	m.tags.LoadEntriesFromDb()
+	m.current = "channel"
+	m.setChannelList(m.list.Width(), m.list.Height())
+}
+
+func (m Model) syncReviewView() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Review changes from YouTube (%d accepted of %d)\n\n", len(m.syncChanges.Accepted()), len(m.syncChanges.Changes))
+	if !m.syncChanges.Complete {
+		fmt.Fprintf(&b, "The retrieved subscription list looks incomplete (%s), so no channels will be marked as unsubscribed.\n\n", m.syncChanges.Incomplete)
+	}
+
+	// the 10 is the header and help height (plus some)
+	_, h, _ := term.GetSize(os.Stdout.Fd())
+	rows := h - strings.Count(b.String(), "\n") - 10
+	if rows < 1 {
+		rows = 1
+	}
+
+	start := 0
+	if m.syncReviewFocus >= rows {
+		start = m.syncReviewFocus - rows + 1
+	}
+	end := min(start+rows, len(m.syncChanges.Changes))
+
+	for i := start; i < end; i++ {
+		ch := m.syncChanges.Changes[i]
+
+		var check = "[ ]"
+		if ch.Accepted {
+			check = "[x]"
+		}
+		var line = fmt.Sprintf("%s %s", check, ch)
+
+		if i == m.syncReviewFocus {
+			b.WriteString(focusedStyle.Render(line))
+		} else {
+			b.WriteString(blurredStyle.Render(line))
+		}
+		b.WriteRune('\n')
+	}
+
+	height := h - strings.Count(b.String(), "\n") - 5
+	if height > 0 {
+		b.WriteString(strings.Repeat("\n", height))
+	}
+
+	help := help.New()
+	help.ShowAll = true
+	b.WriteString(help.View(newSyncReviewKeyMap()))
+
+	return b.String()
+}
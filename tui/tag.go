@@ -20,7 +20,6 @@ import (
 	"maps"
 	"regexp"
 	"slices"
-	"sort"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/key"
@@ -227,7 +226,20 @@ func (d tagListItemDelegate) Render(w io.Writer, m list.Model, index int, listIt
 		Foreground(lipgloss.Color("#" + item.FgColour())).
 		Background(lipgloss.Color("#" + item.BgColour()))
 
-	str := fmt.Sprintf("%s\n%s\n%s\n", style.Render(item.Name()), item.Description(), "channels: "+out)
+	// child tags are indented under their parent
+	var indent, branch string
+	if depth := tags.Depth(item.Id()); depth > 0 {
+		indent = strings.Repeat("   ", depth)
+		branch = strings.Repeat("   ", depth-1) + "└─ "
+	}
+
+	// old names from renames and merges still find the tag
+	var aliases string
+	if names := tags.AliasesOf(item.Id()); len(names) > 0 {
+		aliases = " (was " + strings.Join(names, ", ") + ")"
+	}
+
+	str := fmt.Sprintf("%s%s%s\n%s%s\n%s%s\n", branch, style.Render(item.Name()), aliases, indent, item.Description(), indent, "channels: "+out)
 
 	fn := blurredListStyle.Render
 	if index == m.Index() {
@@ -253,22 +265,63 @@ func HexValidator(s string) error {
 func (m Model) generateTagItems() []list.Item {
 	var items []list.Item
 
-	keys := make([]string, 0, len(m.tags.ByName()))
-	for k := range m.tags.ByName() {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
-	for _, key := range keys {
-		var tag = m.tags.ByName()[key]
+	for _, tag := range m.tags.Tree() {
 		items = append(items, tag)
 	}
 
 	return items
 }
 
+// findTag looks a tag up by its name, ignoring case if there's no exact
+// match, or by a name it used to have.
+func (m Model) findTag(name string) (tag.Tag, error) {
+	if tagInfo, ok := m.tags.Find(name); ok {
+		return tagInfo, nil
+	}
+
+	return tag.Tag{}, fmt.Errorf("there's no tag named %q", name)
+}
+
+// validateTagParent looks up the tag named as the parent in the tag entry
+// form, returning 0 if it's empty. The parent can't be the tag being edited or
+// one of its descendants.
+func (m Model) validateTagParent(name string) (int64, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, nil
+	}
+
+	parent, err := m.findTag(name)
+	if err != nil {
+		return 0, err
+	}
+
+	if m.tagEntryOperation == tagEntryModifyOperationId {
+		editing := m.list.SelectedItem().(tag.Tag)
+		if parent.Id() == editing.Id() || slices.Contains(m.tags.Descendants(editing.Id()), parent.Id()) {
+			return 0, fmt.Errorf("%s can't go under itself or one of its own sub-tags", editing.Name())
+		}
+	}
+
+	return parent.Id(), nil
+}
+
+// validateTagVisibility checks the visibility in the tag entry form, which is
+// public if it's left empty.
+func validateTagVisibility(x string) (string, error) {
+	x = strings.ToLower(strings.TrimSpace(x))
+	if x == "" {
+		return tag.VisibilityPublic, nil
+	}
+	if !slices.Contains(tag.Visibilities, x) {
+		return "", fmt.Errorf("visibility should be one of %s", strings.Join(tag.Visibilities, ", "))
+	}
+
+	return x, nil
+}
+
 func (m Model) createTagEntryForm(tag tag.Tag) []textinput.Model {
-	tagEntryInputs := make([]textinput.Model, 4)
+	tagEntryInputs := make([]textinput.Model, 6)
 
 	var t textinput.Model
 	for i := range tagEntryInputs {
@@ -303,6 +356,14 @@ func (m Model) createTagEntryForm(tag tag.Tag) []textinput.Model {
 			t.Prompt = " #"
 			t.Validate = HexValidator
 			t.SetValue(tag.BgColour())
+		case 4:
+			t.Placeholder = "name of the tag to put this one under"
+			t.CharLimit = 64
+			t.SetValue(m.tags.ById()[tag.ParentId()].Name())
+		case 5:
+			t.Placeholder = "public, unlisted or private"
+			t.CharLimit = 8
+			t.SetValue(tag.Visibility())
 		}
 
 		tagEntryInputs[i] = t
@@ -315,6 +376,9 @@ func (m Model) deleteTagEntryForm(tag tag.Tag) []string {
 
 	tagDeleteInputs[0] = fmt.Sprintf("Name: %s\n", tag.Name())
 	tagDeleteInputs[1] = fmt.Sprintf("Description: %s\n\n", tag.Description())
+	if children := m.tags.Children(tag.Id()); len(children) != 0 {
+		tagDeleteInputs = append(tagDeleteInputs, fmt.Sprintf("Its %d sub-tags will move up to the top level.\n\n", len(children)))
+	}
 
 	return tagDeleteInputs
 }
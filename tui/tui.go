@@ -18,20 +18,22 @@ import (
 	"maps"
 	"math"
 	"os"
-	"regexp"
 	"slices"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/x/term"
 	"github.com/devkvlt/hexer"
 	"repo.joyrex.net/ejstacey/ysm/channel"
 	"repo.joyrex.net/ejstacey/ysm/generator"
+	"repo.joyrex.net/ejstacey/ysm/history"
 	"repo.joyrex.net/ejstacey/ysm/tag"
 	"repo.joyrex.net/ejstacey/ysm/utils"
 )
@@ -49,6 +51,8 @@ var (
 			Background(lipgloss.Color("#25A065")).
 			Padding(0, 1)
 
+	errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555"))
+
 	unsavedColour = lipgloss.Color("#0077FF")
 	activeColour  = lipgloss.Color("#0000FF")
 
@@ -399,52 +403,137 @@ var (
 			key.WithKeys("u"),
 			key.WithHelp("u", "toggle to show only untagged channgels"),
 		),
+		"aKey": key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", "toggle to show unsubscribed (archived) channels"),
+		),
+		"sKey": key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "show channels with this tag or its sub-tags"),
+		),
+		"fKey": key.NewBinding(
+			key.WithKeys("f"),
+			key.WithHelp("f", "filter channels with a query"),
+		),
+		"spaceKey": key.NewBinding(
+			key.WithKeys(" "),
+			key.WithHelp("<space>", "select or unselect channel"),
+		),
+		"selectAllKey": key.NewBinding(
+			key.WithKeys("A"),
+			key.WithHelp("A", "select or unselect all shown channels"),
+		),
+		"bKey": key.NewBinding(
+			key.WithKeys("b"),
+			key.WithHelp("b", "bulk change the selected channels"),
+		),
+		"vKey": key.NewBinding(
+			key.WithKeys("v"),
+			key.WithHelp("v", "view channel details and notes"),
+		),
+		"searchKey": key.NewBinding(
+			key.WithKeys("ctrl+f"),
+			key.WithHelp("<ctrl-f>", "search channel names, descriptions and notes"),
+		),
+		"mergeKey": key.NewBinding(
+			key.WithKeys("M"),
+			key.WithHelp("M", "merge this tag into another one"),
+		),
+		"splitKey": key.NewBinding(
+			key.WithKeys("S"),
+			key.WithHelp("S", "move some of this tag's channels to a new tag"),
+		),
+		"oKey": key.NewBinding(
+			key.WithKeys("o"),
+			key.WithHelp("o", "toggle sorting by last upload"),
+		),
+		"undoKey": key.NewBinding(
+			key.WithKeys("ctrl+z"),
+			key.WithHelp("<ctrl-z>", "undo the last change"),
+		),
+		"redoKey": key.NewBinding(
+			key.WithKeys("ctrl+y"),
+			key.WithHelp("<ctrl-y>", "redo the last undone change"),
+		),
 	}
 
-	untaggedFilter bool = false
+	untaggedFilter     bool  = false
+	unsubscribedFilter bool  = false
+	tagFilter          int64 = 0
+	// channelQuery is the text of the query the channel view is filtered with
+	channelQuery string = ""
+	// sortByUpload sorts the channel view by when channels last uploaded
+	// rather than by name
+	sortByUpload bool = false
 )
 
 type listKeyMap struct {
-	cKey        key.Binding
-	dKey        key.Binding
-	tKey        key.Binding
-	pKey        key.Binding
-	mKey        key.Binding
-	hKey        key.Binding
-	qKey        key.Binding
-	nKey        key.Binding
-	gKey        key.Binding
-	uKey        key.Binding
-	tabKey      key.Binding
-	shiftTabKey key.Binding
-	enterKey    key.Binding
-	upKey       key.Binding
-	downKey     key.Binding
-	leftKey     key.Binding
-	rightKey    key.Binding
-	escKey      key.Binding
+	cKey         key.Binding
+	dKey         key.Binding
+	tKey         key.Binding
+	pKey         key.Binding
+	mKey         key.Binding
+	hKey         key.Binding
+	qKey         key.Binding
+	nKey         key.Binding
+	gKey         key.Binding
+	uKey         key.Binding
+	aKey         key.Binding
+	sKey         key.Binding
+	fKey         key.Binding
+	spaceKey     key.Binding
+	selectAllKey key.Binding
+	bKey         key.Binding
+	vKey         key.Binding
+	searchKey    key.Binding
+	mergeKey     key.Binding
+	splitKey     key.Binding
+	oKey         key.Binding
+	undoKey      key.Binding
+	redoKey      key.Binding
+	tabKey       key.Binding
+	shiftTabKey  key.Binding
+	enterKey     key.Binding
+	upKey        key.Binding
+	downKey      key.Binding
+	leftKey      key.Binding
+	rightKey     key.Binding
+	escKey       key.Binding
 }
 
 func newListKeyMap() *listKeyMap {
 	return &listKeyMap{
-		cKey:        listKeyList["cKey"],
-		tKey:        listKeyList["tKey"],
-		pKey:        listKeyList["pKey"],
-		hKey:        listKeyList["hKey"],
-		qKey:        listKeyList["qKey"],
-		nKey:        listKeyList["nKey"],
-		dKey:        listKeyList["dKey"],
-		mKey:        listKeyList["mKey"],
-		gKey:        listKeyList["gKey"],
-		uKey:        listKeyList["uKey"],
-		tabKey:      listKeyList["tabKey"],
-		shiftTabKey: listKeyList["shiftTabKey"],
-		enterKey:    listKeyList["enterKey"],
-		upKey:       listKeyList["upKey"],
-		downKey:     listKeyList["downKey"],
-		leftKey:     listKeyList["leftKey"],
-		rightKey:    listKeyList["rightKey"],
-		escKey:      listKeyList["escKey"],
+		cKey:         listKeyList["cKey"],
+		tKey:         listKeyList["tKey"],
+		pKey:         listKeyList["pKey"],
+		hKey:         listKeyList["hKey"],
+		qKey:         listKeyList["qKey"],
+		nKey:         listKeyList["nKey"],
+		dKey:         listKeyList["dKey"],
+		mKey:         listKeyList["mKey"],
+		gKey:         listKeyList["gKey"],
+		uKey:         listKeyList["uKey"],
+		aKey:         listKeyList["aKey"],
+		sKey:         listKeyList["sKey"],
+		fKey:         listKeyList["fKey"],
+		spaceKey:     listKeyList["spaceKey"],
+		selectAllKey: listKeyList["selectAllKey"],
+		bKey:         listKeyList["bKey"],
+		vKey:         listKeyList["vKey"],
+		searchKey:    listKeyList["searchKey"],
+		mergeKey:     listKeyList["mergeKey"],
+		splitKey:     listKeyList["splitKey"],
+		oKey:         listKeyList["oKey"],
+		undoKey:      listKeyList["undoKey"],
+		redoKey:      listKeyList["redoKey"],
+		tabKey:       listKeyList["tabKey"],
+		shiftTabKey:  listKeyList["shiftTabKey"],
+		enterKey:     listKeyList["enterKey"],
+		upKey:        listKeyList["upKey"],
+		downKey:      listKeyList["downKey"],
+		leftKey:      listKeyList["leftKey"],
+		rightKey:     listKeyList["rightKey"],
+		escKey:       listKeyList["escKey"],
 	}
 }
 
@@ -506,35 +595,57 @@ func newColourPickerKeyMap() *colourPickerKeyMap {
 }
 
 type Model struct {
-	current                    string
-	previous                   string
-	list                       list.Model
-	channels                   channel.Channels
-	settings                   utils.Settings
-	tags                       tag.Tags
-	listKeys                   *listKeyMap
-	selectedChannel            channel.Channel
-	selectedTag                tag.Tag
-	selectedChannelId          int
-	selectedTagId              int
-	selectedTagIds             []int
-	tagEntryFocus              int
-	tagEntryOperation          int
-	tagDeleteFocus             int
-	tagDeleteInputs            []string
-	tagEntryInputs             []textinput.Model
-	channelModifyFocus         int
-	generatePageFocus          int
-	generatePageSelectedTagId  int
-	generatePageInputs         []textinput.Model
-	generatePageSelectedTagIds []int
-	channelModifyHeaders       []string
-	channelModifyInputs        []textinput.Model
-	colourPickerX              int
-	colourPickerY              int
-	colourPickerTitle          string
-	selectedBackColour         string
-	lastOutputFile             string
+	current                   string
+	previous                  string
+	list                      list.Model
+	channels                  channel.Channels
+	settings                  utils.Settings
+	tags                      tag.Tags
+	listKeys                  *listKeyMap
+	selectedChannel           channel.Channel
+	selectedTag               tag.Tag
+	selectedChannelId         int
+	selectedTagId             int
+	selectedTagIds            []int
+	tagEntryFocus             int
+	tagEntryOperation         int
+	tagDeleteFocus            int
+	tagDeleteInputs           []string
+	tagEntryInputs            []textinput.Model
+	tagEntryError             string
+	channelModifyFocus        int
+	generatePageFocus         int
+	generatePageSelectedTagId int
+	generatePageInputs        []textinput.Model
+	generatePageError         string
+	generatePageProfile       int
+	channelModifyHeaders      []string
+	channelModifyInputs       []textarea.Model
+	channelModifyError        string
+	channelDetail             viewport.Model
+	colourPickerX             int
+	colourPickerY             int
+	colourPickerTitle         string
+	selectedBackColour        string
+	lastOutputFile            string
+	syncChanges               channel.Changeset
+	syncReviewFocus           int
+	channelQueryInput         textinput.Model
+	channelQueryError         string
+	bulkSelected              map[string]bool
+	bulkFocus                 int
+	bulkTagInput              textinput.Model
+	bulkError                 string
+	tagMergeInput             textinput.Model
+	tagMergeFocus             int
+	tagMergeError             string
+	tagSplitChannels          []string
+	tagSplitPicked            map[string]bool
+	channelSearchInput        textinput.Model
+	channelSearchText         string
+	channelSearchResults      []channel.SearchResult
+	channelSearchFocus        int
+	channelSearchError        string
 }
 
 func (m Model) Init() tea.Cmd {
@@ -561,22 +672,72 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.list.SetSize(msg.Width-h, msg.Height-v)
 
 		case tea.KeyMsg:
+			// let the fuzzy filter have the keys while it's being typed
+			if m.list.FilterState() == list.Filtering {
+				break
+			}
+
 			switch {
+			case key.Matches(msg, m.listKeys.spaceKey):
+				if m.current == "channel" {
+					m.toggleChannelSelected()
+					m.list.CursorDown()
+					m.setChannelListTitle()
+				}
+
+				return m, nil
+
+			case key.Matches(msg, m.listKeys.selectAllKey):
+				if m.current == "channel" {
+					m.toggleAllChannelsSelected()
+					m.setChannelListTitle()
+				}
+
+				return m, nil
+
+			case key.Matches(msg, m.listKeys.bKey):
+				if m.current != "channel" {
+					return m, nil
+				}
+				if len(m.bulkSelected) == 0 {
+					return m, m.list.NewStatusMessage("Select some channels with <space> first")
+				}
+
+				m.bulkTagInput = m.createBulkTagInput()
+				m.bulkFocus = 0
+				m.bulkError = ""
+				m.current = "channelBulk"
+				return m, nil
+
+			case key.Matches(msg, m.listKeys.mergeKey):
+				if m.current != "tag" || m.list.SelectedItem() == nil {
+					return m, nil
+				}
+
+				m.startTagMerge()
+				return m, nil
+
+			case key.Matches(msg, m.listKeys.splitKey):
+				if m.current != "tag" || m.list.SelectedItem() == nil {
+					return m, nil
+				}
+				if len(m.list.SelectedItem().(tag.Tag).Channels()) == 0 {
+					return m, m.list.NewStatusMessage("That tag has no channels to split off")
+				}
+
+				m.startTagSplit()
+				return m, nil
+
+			case key.Matches(msg, m.listKeys.undoKey):
+				return m, m.undoRedo(true)
+
+			case key.Matches(msg, m.listKeys.redoKey):
+				return m, m.undoRedo(false)
+
 			case key.Matches(msg, m.listKeys.gKey):
 				if m.current == "channel" || m.current == "tag" {
 					m.previous = m.current
-					m.generatePageInputs = m.createGeneratePageForm()
-					// juice the tags
-					if len(m.generatePageSelectedTagIds) == 0 {
-						sortedTags := slices.Sorted(maps.Keys(m.tags.ByName()))
-						for i, tagName := range sortedTags {
-							tag := m.tags.ByName()[tagName]
-							regexpHidden := regexp.MustCompile(`(?i)^(hide|hidden)?$`)
-							if !regexpHidden.Match([]byte(tag.Name())) {
-								m.generatePageSelectedTagIds = append(m.generatePageSelectedTagIds, i)
-							}
-						}
-					}
+					m.selectGenerateProfile(m.generatePageProfile)
 					m.current = "generatePage"
 				}
 
@@ -585,63 +746,75 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case key.Matches(msg, m.listKeys.uKey):
 				if m.current == "channel" {
 					untaggedFilter = !untaggedFilter
+					m.setChannelList(m.list.Width(), m.list.Height())
+					m.list.ResetSelected()
+				}
 
-					width := m.list.Width()
-					height := m.list.Height()
-					m.list = list.New(m.generateChannelItems(untaggedFilter), channelListItemDelegate{}, width, height)
-					m.list.Title = "YSM - Channel View"
-					m.list.Styles.Title = titleStyle
+				return m, nil
+
+			case key.Matches(msg, m.listKeys.aKey):
+				if m.current == "channel" {
+					unsubscribedFilter = !unsubscribedFilter
+					m.setChannelList(m.list.Width(), m.list.Height())
 					m.list.ResetSelected()
-					listKeys := newListKeyMap()
-					m.list.AdditionalShortHelpKeys = func() []key.Binding {
-						return []key.Binding{
-							listKeys.tKey,
-							listKeys.pKey,
-							listKeys.enterKey,
-							listKeys.gKey,
-							listKeys.uKey,
-						}
-					}
-					m.list.AdditionalFullHelpKeys = func() []key.Binding {
-						return []key.Binding{
-							listKeys.tKey,
-							listKeys.pKey,
-							listKeys.enterKey,
-							listKeys.gKey,
-							listKeys.uKey,
-						}
-					}
 				}
 
 				return m, nil
 
-			case key.Matches(msg, m.listKeys.cKey):
-				m.current = "channel"
-				width := m.list.Width()
-				height := m.list.Height()
-				m.list = list.New(m.generateChannelItems(untaggedFilter), channelListItemDelegate{}, width, height)
-				m.list.Title = "YSM - Channel View"
-				m.list.Styles.Title = titleStyle
-				listKeys := newListKeyMap()
-				m.list.AdditionalShortHelpKeys = func() []key.Binding {
-					return []key.Binding{
-						listKeys.tKey,
-						listKeys.pKey,
-						listKeys.enterKey,
-						listKeys.gKey,
-						listKeys.uKey,
-					}
+			case key.Matches(msg, m.listKeys.oKey):
+				if m.current == "channel" {
+					sortByUpload = !sortByUpload
+					m.setChannelList(m.list.Width(), m.list.Height())
+					m.list.ResetSelected()
 				}
-				m.list.AdditionalFullHelpKeys = func() []key.Binding {
-					return []key.Binding{
-						listKeys.tKey,
-						listKeys.pKey,
-						listKeys.enterKey,
-						listKeys.gKey,
-						listKeys.uKey,
-					}
+
+				return m, nil
+
+			case key.Matches(msg, m.listKeys.fKey):
+				if m.current != "channel" {
+					return m, nil
+				}
+
+				m.channelQueryInput = m.createChannelQueryInput()
+				m.channelQueryError = ""
+				m.current = "channelQuery"
+				return m, nil
+
+			case key.Matches(msg, m.listKeys.vKey):
+				if m.current != "channel" || m.list.SelectedItem() == nil {
+					return m, nil
+				}
+
+				m.startChannelDetail(m.list.SelectedItem().(channel.Channel))
+				return m, nil
+
+			case key.Matches(msg, m.listKeys.searchKey):
+				if m.current != "channel" {
+					return m, nil
+				}
+
+				m.channelSearchInput = m.createChannelSearchInput()
+				m.channelSearchText = ""
+				m.channelSearchResults = nil
+				m.channelSearchError = ""
+				m.current = "channelSearch"
+				return m, nil
+
+			case key.Matches(msg, m.listKeys.sKey):
+				if m.current != "tag" || m.list.SelectedItem() == nil {
+					return m, nil
 				}
 
+				tagFilter = m.list.SelectedItem().(tag.Tag).Id()
+				m.current = "channel"
+				m.setChannelList(m.list.Width(), m.list.Height())
+				return m, nil
+
+			case key.Matches(msg, m.listKeys.cKey):
+				tagFilter = 0
+				m.current = "channel"
+				m.setChannelList(m.list.Width(), m.list.Height())
+
 				// set selected channel
 				if m.selectedChannelId != -1 {
 					m.list.Select(m.selectedChannelId)
@@ -665,6 +838,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						listKeys.nKey,
 						listKeys.mKey,
 						listKeys.dKey,
+						listKeys.sKey,
 						listKeys.enterKey,
 						listKeys.gKey,
 					}
@@ -676,8 +850,13 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						listKeys.nKey,
 						listKeys.mKey,
 						listKeys.dKey,
+						listKeys.sKey,
+						listKeys.mergeKey,
+						listKeys.splitKey,
 						listKeys.enterKey,
 						listKeys.gKey,
+						listKeys.undoKey,
+						listKeys.redoKey,
 					}
 				}
 
@@ -717,11 +896,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if m.list.SelectedItem() != nil {
 					switch m.current {
 					case "channel":
-						channel := m.list.SelectedItem().(channel.Channel)
-						m.channelModifyHeaders = m.createChannelModifyHeader(channel)
-						m.channelModifyInputs = m.createChannelModifyForm(channel)
-						m.selectedChannel = channel
-						m.current = "channelModify"
+						m.startChannelModify(m.list.SelectedItem().(channel.Channel))
 						return m, nil
 					case "tag":
 						tag := m.list.SelectedItem().(tag.Tag)
@@ -760,6 +935,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyMsg:
 			switch {
 			case key.Matches(msg, tagModifyKeyList["escKey"]):
+				m.tagEntryError = ""
 				m.current = "tag"
 				return m, nil
 
@@ -770,7 +946,20 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// If so, create it.
 				if s == "enter" {
 					if m.tagEntryFocus == (len(m.tagEntryInputs) + 2) {
+						parentId, err := m.validateTagParent(m.tagEntryInputs[4].Value())
+						if err != nil {
+							m.tagEntryError = err.Error()
+							return m, nil
+						}
+						visibility, err := validateTagVisibility(m.tagEntryInputs[5].Value())
+						if err != nil {
+							m.tagEntryError = err.Error()
+							return m, nil
+						}
+						m.tagEntryError = ""
+
 						if m.tagEntryOperation == tagEntryCreateOperationId {
+							history.Begin(fmt.Sprintf("create tag %q", m.tagEntryInputs[0].Value()))
 							var tag tag.Tag
 							err := tag.New()
 							utils.HandleError(err, "creating new tag")
@@ -782,9 +971,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 							utils.HandleError(err, "updating tag fgcolour")
 							err = tag.SetBgColour(m.tagEntryInputs[3].Value())
 							utils.HandleError(err, "updating tag bgColour")
+							err = tag.SetParentId(parentId)
+							utils.HandleError(err, "updating tag parent")
+							err = tag.SetVisibility(visibility)
+							utils.HandleError(err, "updating tag visibility")
 						} else {
 							tag := m.list.SelectedItem().(tag.Tag)
-							err := tag.SetName(m.tagEntryInputs[0].Value())
+							history.Begin(fmt.Sprintf("edit tag %q", tag.Name()))
+							// the old name keeps finding the tag
+							err := tag.Rename(m.tagEntryInputs[0].Value())
 							utils.HandleError(err, "updating tag name")
 							err = tag.SetDescription(m.tagEntryInputs[1].Value())
 							utils.HandleError(err, "updating tag description")
@@ -792,7 +987,13 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 							utils.HandleError(err, "updating tag fgcolour")
 							err = tag.SetBgColour(m.tagEntryInputs[3].Value())
 							utils.HandleError(err, "updating tag bgColour")
+							err = tag.SetParentId(parentId)
+							utils.HandleError(err, "updating tag parent")
+							err = tag.SetVisibility(visibility)
+							utils.HandleError(err, "updating tag visibility")
 						}
+						err = history.End()
+						utils.HandleError(err, "recording tag history")
 
 						m.tags.LoadEntriesFromDb()
 						m.list.SetItems(m.generateTagItems())
@@ -878,6 +1079,10 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						j = 3
 						// } else if i == 5 {
 						// 	j = 3
+					case 6:
+						j = 4
+					case 7:
+						j = 5
 					}
 					if i == m.tagEntryFocus && i != 3 && i != 5 {
 						// Set focused state
@@ -906,12 +1111,27 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.WindowSizeMsg:
 			h, v := appStyle.GetFrameSize()
 			m.list.SetSize(msg.Width-h, msg.Height-v)
+			m.channelModifyInputs[0].SetWidth(max(msg.Width-6, 20))
+
+		case notesEditedMsg:
+			if msg.err != nil {
+				m.channelModifyError = "Couldn't edit the notes: " + msg.err.Error()
+				return m, nil
+			}
+
+			m.channelModifyError = ""
+			m.channelModifyInputs[0].SetValue(msg.notes)
+			return m, nil
 
 		case tea.KeyMsg:
 			switch {
 			case key.Matches(msg, channelModifyKeyList["escKey"]):
 				m.current = "channel"
 				return m, nil
+			case key.Matches(msg, channelModifyKeyList["editorKey"]):
+				return m, editNotes(m.channelModifyInputs[0].Value())
+			case m.notesKeepKey(msg):
+				cmd = m.updateChannelModifyInput(msg)
 			case key.Matches(msg, channelModifyKeyList["enterKey"]):
 				var totalLength = len(m.channelModifyInputs) + 1
 
@@ -919,6 +1139,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// If so, create it.
 				if m.channelModifyFocus == totalLength {
 					channel := m.list.SelectedItem().(channel.Channel)
+					history.Begin(fmt.Sprintf("edit channel %q", channel.Name()))
 					err := channel.SetNotes(m.channelModifyInputs[0].Value())
 					utils.HandleError(err, "updating channel notes")
 					m.channelModifyFocus = 0
@@ -960,9 +1181,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 					err = channel.SetTags(submitIds)
 					utils.HandleError(err, "updating channel tags")
+					err = history.End()
+					utils.HandleError(err, "recording channel history")
 					m.selectedTagIds = nil
 					m.channels.LoadEntriesFromDb()
-					m.list.SetItems(m.generateChannelItems(untaggedFilter))
+					m.list.SetItems(m.generateChannelItems(untaggedFilter, unsubscribedFilter, tagFilter, m.parseChannelQuery()))
 					m.current = "channel"
 					return m, nil
 				} else {
@@ -1020,18 +1243,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// notes field
 				case 0:
 					cmds[0] = m.channelModifyInputs[0].Focus()
-					m.channelModifyInputs[0].PromptStyle = focusedButtonStyle
-					m.channelModifyInputs[0].TextStyle = focusedButtonStyle
 				// tags
 				case 1:
 					m.channelModifyInputs[0].Blur()
-					m.channelModifyInputs[0].PromptStyle = blurredButtonStyle
-					m.channelModifyInputs[0].TextStyle = blurredButtonStyle
 				// submit button
 				case 2:
 					m.channelModifyInputs[0].Blur()
-					m.channelModifyInputs[0].PromptStyle = blurredButtonStyle
-					m.channelModifyInputs[0].TextStyle = blurredButtonStyle
 				}
 
 				return m, tea.Batch(cmds...)
@@ -1204,110 +1421,35 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyMsg:
 			switch {
 			case key.Matches(msg, generatePageKeyList["escKey"]):
+				m.generatePageError = ""
 				m.current = m.previous
 				return m, nil
+			case key.Matches(msg, generatePageKeyList["profileKey"]):
+				m.selectGenerateProfile((m.generatePageProfile + 1) % len(m.settings.Generator.ProfileList()))
+				return m, nil
 			case key.Matches(msg, generatePageKeyList["enterKey"]):
 				var totalLength = len(m.generatePageInputs) + 2 - 1 // for clarity, the -1 is because everything is 0-started
 				// Did the user press enter while the submit button was focused?
 				// If so, create it.
 				if m.generatePageFocus == totalLength {
-					sortedTags := slices.Sorted(maps.Keys(m.tags.ByName()))
-					hiddenTags := make(map[int64]int, len(sortedTags))
-
-					for _, tagName := range sortedTags {
-						var found bool = false
-						selectedTag := m.tags.ByName()[tagName]
-
-						for _, selectedId := range m.generatePageSelectedTagIds {
-							selectedTagName := sortedTags[selectedId]
-							if tagName == selectedTagName {
-								found = true
-								break
-							}
-						}
-
-						if !found {
-							hiddenTags[selectedTag.Id()] = 1
-						}
-					}
-
-					genChannels := make([]channel.ExportChannel, 0, len(m.channels.ByName()))
-					for _, chanInfo := range m.channels.ByName() {
-						tmpChan := channel.ExportChannel{
-							Id:          chanInfo.Id(),
-							Name:        chanInfo.Name(),
-							Description: chanInfo.Description(),
-							Notes:       chanInfo.Notes(),
-						}
-						var tmpTags = make(map[string]tag.ExportTag)
-						var includeTag bool = true
-
-						for _, tagId := range chanInfo.Tags() {
-							// don't include the channels tagged with hidden tags
-							_, ok := hiddenTags[tagId]
-							if ok {
-								includeTag = false
-								break
-							}
-
-							tagInfo := m.tags.ById()[tagId]
-
-							tmpTag := tag.ExportTag{
-								Id:          tagInfo.Id(),
-								Name:        tagInfo.Name(),
-								Description: tagInfo.Description(),
-							}
-							tmpTags[tmpTag.Name] = tmpTag
-						}
-						if !includeTag {
-							continue
-						}
-						sortedTags := slices.Sorted(maps.Keys(tmpTags))
-						for _, tmpTag := range sortedTags {
-							tmpChan.Tags = append(tmpChan.Tags, tmpTags[tmpTag])
-						}
-
-						genChannels = append(genChannels, tmpChan)
-					}
-
-					var tmpTags = make(map[string]tag.ExportTag)
-					for _, tagInfo := range m.tags.ByName() {
-						var unmatched bool = true
-						for _, selectedId := range m.generatePageSelectedTagIds {
-							testTagId := sortedTags[selectedId]
-							if tagInfo.Name() == testTagId {
-								unmatched = false
-								break
-							}
-						}
-						if unmatched {
-							continue
-						}
-						tmpTag := tag.ExportTag{
-							Id:          tagInfo.Id(),
-							Name:        tagInfo.Name(),
-							Description: tagInfo.Description(),
-							FgColour:    tagInfo.FgColour(),
-							BgColour:    tagInfo.BgColour(),
-						}
-						tmpTags[tmpTag.Name] = tmpTag
-					}
-					genTags := make([]tag.ExportTag, 0, len(sortedTags))
+					p := m.generateProfile()
+					p.TemplateFile = m.generatePageInputs[0].Value()
+					p.OutputFile = m.generatePageInputs[1].Value()
+					p.Title = m.generatePageInputs[2].Value()
+					p.Query = m.generatePageInputs[3].Value()
 
-					sortedTags = slices.Sorted(maps.Keys(tmpTags))
-					for _, tmpTag := range sortedTags {
-						genTags = append(genTags, tmpTags[tmpTag])
+					gen, hiddenTags, err := generator.FromProfile(p, m.tags)
+					if err != nil {
+						m.generatePageError = err.Error()
+						return m, nil
 					}
+					m.generatePageError = ""
 
-					gen := generator.Generator{
-						Channels:     genChannels,
-						Tags:         genTags,
-						Title:        m.generatePageInputs[2].Value(),
-						OutputFile:   m.generatePageInputs[1].Value(),
-						TemplateFile: m.generatePageInputs[0].Value(),
-					}
-					gen.LoadTemplateFile()
-					gen.GenerateOutputFile()
+					gen.LoadEntries(m.channels, m.tags, hiddenTags)
+					err = gen.LoadTemplateFile()
+					utils.HandleError(err, "Unable to open template.")
+					err = gen.GenerateOutputFile()
+					utils.HandleError(err, "Unable to generate output file.")
 					m.lastOutputFile = m.generatePageInputs[1].Value()
 					m.current = "verifyGenerate"
 					return m, nil
@@ -1382,7 +1524,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					cmds[2] = m.generatePageInputs[2].Focus()
 					m.generatePageInputs[2].PromptStyle = focusedStyle
 					m.generatePageInputs[2].TextStyle = focusedStyle
-					// tags
+				// tags
+				// query
+				case 4:
+					cmds[3] = m.generatePageInputs[3].Focus()
+					m.generatePageInputs[3].PromptStyle = focusedStyle
+					m.generatePageInputs[3].TextStyle = focusedStyle
 					// submit
 				}
 
@@ -1409,21 +1556,13 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			case key.Matches(msg, generatePageKeyList["spaceKey"]):
 				if m.generatePageFocus == 3 {
-					var newTagIds []int
-					var found bool = false
-
-					for _, currTagId := range m.generatePageSelectedTagIds {
-						if currTagId == m.generatePageSelectedTagId {
-							found = true
-						} else {
-							newTagIds = append(newTagIds, currTagId)
+					sortedTags := slices.Sorted(maps.Keys(m.tags.ByName()))
+					if m.generatePageSelectedTagId < len(sortedTags) {
+						err := m.cycleTagVisibility(m.tags.ByName()[sortedTags[m.generatePageSelectedTagId]])
+						if err != nil {
+							m.generatePageError = err.Error()
 						}
 					}
-					if !found {
-						m.generatePageSelectedTagIds = append(m.generatePageSelectedTagIds, m.generatePageSelectedTagId)
-					} else {
-						m.generatePageSelectedTagIds = newTagIds
-					}
 				} else {
 					cmd = m.updateGeneratePageInput(msg)
 				}
@@ -1433,6 +1572,207 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+	case "channelBulk":
+		switch msg := msg.(type) {
+		case tea.WindowSizeMsg:
+			h, v := appStyle.GetFrameSize()
+			m.list.SetSize(msg.Width-h, msg.Height-v)
+
+		case tea.KeyMsg:
+			switch {
+			case key.Matches(msg, bulkKeyList["escKey"]):
+				m.current = "channel"
+				return m, nil
+
+			case key.Matches(msg, bulkKeyList["nextKey"], bulkKeyList["prevKey"]):
+				return m, m.moveBulkFocus(msg.String())
+
+			case key.Matches(msg, bulkKeyList["enterKey"]):
+				if m.bulkFocus == 0 {
+					return m, m.moveBulkFocus("down")
+				}
+				return m, m.applyBulk()
+			}
+		}
+
+		m.bulkTagInput, cmd = m.bulkTagInput.Update(msg)
+
+	case "channelDetail":
+		switch msg := msg.(type) {
+		case tea.WindowSizeMsg:
+			h, v := appStyle.GetFrameSize()
+			m.list.SetSize(msg.Width-h, msg.Height-v)
+			m.channelDetail.Width = max(msg.Width-4, 20)
+			m.channelDetail.Height = max(msg.Height-8, 5)
+			m.channelDetail.SetContent(m.channelDetailContent(m.selectedChannel, m.channelDetail.Width))
+
+		case tea.KeyMsg:
+			switch {
+			case key.Matches(msg, channelDetailKeyList["escKey"]):
+				m.current = "channel"
+				return m, nil
+
+			case key.Matches(msg, channelDetailKeyList["enterKey"]):
+				m.startChannelModify(m.selectedChannel)
+				return m, nil
+			}
+		}
+
+		m.channelDetail, cmd = m.channelDetail.Update(msg)
+
+	case "channelSearch":
+		switch msg := msg.(type) {
+		case tea.WindowSizeMsg:
+			h, v := appStyle.GetFrameSize()
+			m.list.SetSize(msg.Width-h, msg.Height-v)
+
+		case tea.KeyMsg:
+			switch {
+			case key.Matches(msg, channelSearchKeyList["escKey"]):
+				m.current = "channel"
+				return m, nil
+
+			case key.Matches(msg, channelSearchKeyList["nextKey"], channelSearchKeyList["prevKey"]):
+				m.moveChannelSearchFocus(msg.String())
+				return m, nil
+
+			case key.Matches(msg, channelSearchKeyList["enterKey"]):
+				m.openChannelSearchResult()
+				return m, nil
+			}
+		}
+
+		m.channelSearchInput, cmd = m.channelSearchInput.Update(msg)
+		m.runChannelSearch()
+
+	case "tagMerge":
+		switch msg := msg.(type) {
+		case tea.WindowSizeMsg:
+			h, v := appStyle.GetFrameSize()
+			m.list.SetSize(msg.Width-h, msg.Height-v)
+
+		case tea.KeyMsg:
+			switch {
+			case key.Matches(msg, tagMergeKeyList["escKey"]):
+				m.current = "tag"
+				return m, nil
+
+			case key.Matches(msg, tagMergeKeyList["nextKey"], tagMergeKeyList["prevKey"]):
+				return m, m.moveTagMergeFocus(msg.String(), 2)
+
+			case key.Matches(msg, tagMergeKeyList["enterKey"]):
+				switch m.tagMergeFocus {
+				case 0:
+					return m, m.moveTagMergeFocus("down", 2)
+				case 1:
+					m.current = "tag"
+					return m, nil
+				}
+				return m, m.applyTagMerge()
+			}
+		}
+
+		m.tagMergeInput, cmd = m.tagMergeInput.Update(msg)
+
+	case "tagSplit":
+		var last = len(m.tagSplitChannels) + 2
+		var onChannel = m.tagMergeFocus > 0 && m.tagMergeFocus <= len(m.tagSplitChannels)
+
+		switch msg := msg.(type) {
+		case tea.WindowSizeMsg:
+			h, v := appStyle.GetFrameSize()
+			m.list.SetSize(msg.Width-h, msg.Height-v)
+
+		case tea.KeyMsg:
+			switch {
+			case key.Matches(msg, tagMergeKeyList["escKey"]):
+				m.current = "tag"
+				return m, nil
+
+			case key.Matches(msg, tagMergeKeyList["nextKey"], tagMergeKeyList["prevKey"]):
+				return m, m.moveTagMergeFocus(msg.String(), last)
+
+			case onChannel && key.Matches(msg, tagMergeKeyList["spaceKey"], tagMergeKeyList["enterKey"]):
+				channelId := m.tagSplitChannels[m.tagMergeFocus-1]
+				m.tagSplitPicked[channelId] = !m.tagSplitPicked[channelId]
+				return m, nil
+
+			case key.Matches(msg, tagMergeKeyList["enterKey"]):
+				switch m.tagMergeFocus {
+				case 0:
+					return m, m.moveTagMergeFocus("down", last)
+				case last - 1:
+					m.current = "tag"
+					return m, nil
+				}
+				return m, m.applyTagSplit()
+			}
+		}
+
+		if m.tagMergeFocus == 0 {
+			m.tagMergeInput, cmd = m.tagMergeInput.Update(msg)
+		}
+
+	case "channelQuery":
+		switch msg := msg.(type) {
+		case tea.WindowSizeMsg:
+			h, v := appStyle.GetFrameSize()
+			m.list.SetSize(msg.Width-h, msg.Height-v)
+
+		case tea.KeyMsg:
+			switch {
+			case key.Matches(msg, channelQueryKeyList["escKey"]):
+				m.current = "channel"
+				return m, nil
+
+			case key.Matches(msg, channelQueryKeyList["enterKey"]):
+				m.applyChannelQuery()
+				return m, nil
+			}
+		}
+
+		m.channelQueryInput, cmd = m.channelQueryInput.Update(msg)
+
+	case "syncReview":
+		switch msg := msg.(type) {
+		case tea.WindowSizeMsg:
+			h, v := appStyle.GetFrameSize()
+			m.list.SetSize(msg.Width-h, msg.Height-v)
+
+		case tea.KeyMsg:
+			switch {
+			case key.Matches(msg, syncReviewKeyList["prevKey"]):
+				if m.syncReviewFocus > 0 {
+					m.syncReviewFocus--
+				}
+				return m, nil
+
+			case key.Matches(msg, syncReviewKeyList["nextKey"]):
+				if m.syncReviewFocus < len(m.syncChanges.Changes)-1 {
+					m.syncReviewFocus++
+				}
+				return m, nil
+
+			case key.Matches(msg, syncReviewKeyList["spaceKey"]):
+				m.syncChanges.Changes[m.syncReviewFocus].Accepted = !m.syncChanges.Changes[m.syncReviewFocus].Accepted
+				return m, nil
+
+			case key.Matches(msg, syncReviewKeyList["aKey"]):
+				m.syncChanges.AcceptAll()
+				m.applySyncChanges()
+				return m, nil
+
+			case key.Matches(msg, syncReviewKeyList["enterKey"]):
+				m.applySyncChanges()
+				return m, nil
+
+			case key.Matches(msg, syncReviewKeyList["escKey"]):
+				m.syncChanges = channel.Changeset{}
+				m.current = "channel"
+				return m, nil
+			}
+		}
+
 	case "verifyGenerate":
 		switch msg := msg.(type) {
 		case tea.WindowSizeMsg:
@@ -1497,8 +1837,19 @@ func (m Model) View() string {
 			m.tagEntryInputs[3].TextStyle = m.tagEntryInputs[3].TextStyle.Background(unsavedColour)
 		}
 		b.WriteString(fmt.Sprintf("%24s: %s %s \n", "background colour (hex)", m.tagEntryInputs[3].View(), buttonRef.Render("[ ColourPicker ]")))
+		if m.tagEntryInputs[4].Value() != m.tags.ById()[m.selectedTag.ParentId()].Name() {
+			m.tagEntryInputs[4].TextStyle = m.tagEntryInputs[4].TextStyle.Background(unsavedColour)
+		}
+		b.WriteString(fmt.Sprintf("%24s: %s\n", "parent tag (optional)", m.tagEntryInputs[4].View()))
+		if m.tagEntryInputs[5].Value() != m.selectedTag.Visibility() {
+			m.tagEntryInputs[5].TextStyle = m.tagEntryInputs[5].TextStyle.Background(unsavedColour)
+		}
+		b.WriteString(fmt.Sprintf("%24s: %s\n", "visibility", m.tagEntryInputs[5].View()))
+		if m.tagEntryError != "" {
+			b.WriteString(fmt.Sprintf("%24s  %s\n", "", errorStyle.Render(m.tagEntryError)))
+		}
 
-		if m.tagEntryFocus == 6 {
+		if m.tagEntryFocus == 8 {
 			buttonRef = &focusedButtonStyle
 		} else {
 			buttonRef = &blurredButtonStyle
@@ -1541,6 +1892,10 @@ func (m Model) View() string {
 		case 5:
 			b.WriteString(help.View(tagButtonKeyMap))
 		case 6:
+			b.WriteString(help.View(tagInputKeyMap))
+		case 7:
+			b.WriteString(help.View(tagInputKeyMap))
+		case 8:
 			b.WriteString(help.View(tagButtonKeyMap))
 		}
 
@@ -1553,11 +1908,25 @@ func (m Model) View() string {
 			b.WriteString(m.channelModifyHeaders[i])
 		}
 
-		if m.channelModifyInputs[0].Value() != m.selectedChannel.Notes() {
-			m.channelModifyInputs[0].TextStyle = m.channelModifyInputs[0].TextStyle.Background(unsavedColour)
+		notes := m.channelModifyInputs[0]
+		if notes.Value() != m.selectedChannel.Notes() {
+			for _, style := range []*textarea.Style{&notes.FocusedStyle, &notes.BlurredStyle} {
+				style.Prompt = style.Prompt.Background(unsavedColour)
+				style.Text = style.Text.Background(unsavedColour)
+				style.CursorLine = style.CursorLine.Background(unsavedColour)
+			}
+			// pick the changed styles up
+			if notes.Focused() {
+				notes.Focus()
+			} else {
+				notes.Blur()
+			}
 		}
-		b.WriteString(m.channelModifyInputs[0].View())
+		b.WriteString(notes.View())
 		b.WriteString("\n")
+		if m.channelModifyError != "" {
+			fmt.Fprintf(&b, "%s\n", errorStyle.Render(m.channelModifyError))
+		}
 
 		sortedTags := slices.Sorted(maps.Keys(m.tags.ByName()))
 
@@ -1753,12 +2122,25 @@ func (m Model) View() string {
 	case "generatePage":
 		var b strings.Builder
 
+		if profiles := m.settings.Generator.ProfileList(); len(profiles) > 1 {
+			b.WriteString(fmt.Sprintf("%24s: %s %s\n\n", "profile", m.generateProfile().Name, blurredStyle.Render(fmt.Sprintf("(%d of %d)", m.generatePageProfile+1, len(profiles)))))
+		}
+
 		b.WriteString(fmt.Sprintf("%24s: %s\n", "source template", m.generatePageInputs[0].View()))
 		b.WriteString(fmt.Sprintf("%24s: %s\n", "output file", m.generatePageInputs[1].View()))
+		if format, err := generator.FormatFor(m.generateProfile().Format, m.generatePageInputs[1].Value()); err == nil {
+			b.WriteString(fmt.Sprintf("%24s  %s\n", "", blurredStyle.Render("written as "+format)))
+		}
 		b.WriteString(fmt.Sprintf("%24s: %s\n", "page title", m.generatePageInputs[2].View()))
 
 		b.WriteRune('\n')
-		b.WriteString("Select what tags to include on the output. By default all tags are selected for display unless the tag is named 'hide' or 'hidden'.\n")
+		b.WriteString("Space changes a tag's visibility, which is saved with the tag. Public tags (boxed) are shown. Unlisted tags (in italics)\n")
+		b.WriteString("are left off, but their channels aren't. Private tags (struck out) and their channels are left out.\n")
+		if names, err := m.profileHiddenTags(); err != nil {
+			b.WriteString(errorStyle.Render(err.Error()) + "\n")
+		} else if len(names) != 0 {
+			b.WriteString(blurredStyle.Render("The profile also leaves out: "+strings.Join(names, ", ")) + "\n")
+		}
 
 		sortedTags := slices.Sorted(maps.Keys(m.tags.ByName()))
 
@@ -1769,21 +2151,16 @@ func (m Model) View() string {
 		var output string
 		var curCol = 0
 		for i, tagName := range sortedTags {
-			tag := m.tags.ByName()[tagName]
-			var style = tagDisplayStyle.Width(len(tagName)).Background(lipgloss.Color("#" + tag.BgColour())).Foreground(lipgloss.Color("#" + tag.FgColour())).Margin(1)
-
-			// previously selected
-			var selected = false
-			for _, testId := range m.generatePageSelectedTagIds {
-				if testId == i {
-					selected = true
-				}
-			}
-
-			if selected {
+			tagInfo := m.tags.ByName()[tagName]
+			var style = tagDisplayStyle.Width(len(tagName)).Background(lipgloss.Color("#" + tagInfo.BgColour())).Foreground(lipgloss.Color("#" + tagInfo.FgColour())).Margin(1)
+
+			switch tagInfo.Visibility() {
+			case tag.VisibilityPrivate:
+				style = style.Border(lipgloss.HiddenBorder(), true, true, true, true).Strikethrough(true)
+			case tag.VisibilityUnlisted:
+				style = style.Border(lipgloss.HiddenBorder(), true, true, true, true).Italic(true)
+			default:
 				style = style.Border(lipgloss.NormalBorder(), true, true, true, true)
-			} else {
-				style = style.Border(lipgloss.HiddenBorder(), true, true, true, true)
 			}
 
 			if m.generatePageFocus == 3 {
@@ -1810,8 +2187,14 @@ func (m Model) View() string {
 		} else {
 			buttonRef = &blurredButtonStyle
 		}
+		b.WriteString("\n\n")
+		b.WriteString(fmt.Sprintf("%24s: %s\n", "channel query", m.generatePageInputs[3].View()))
+		if m.generatePageError != "" {
+			b.WriteString(fmt.Sprintf("%24s  %s\n", "", errorStyle.Render(m.generatePageError)))
+		}
+
 		var button = buttonRef.Render("[ Generate ]")
-		fmt.Fprintf(&b, "\n\n%s\n\n", button)
+		fmt.Fprintf(&b, "\n%s\n\n", button)
 
 		b.WriteRune('\n')
 		b.WriteRune('\n')
@@ -1840,11 +2223,34 @@ func (m Model) View() string {
 		case 3:
 			b.WriteString(help.View(generatePageSelectKeyMap))
 		case 4:
+			b.WriteString(help.View(generatePageInputKeyMap))
+		case 5:
 			b.WriteString(help.View(generatePageButtonKeyMap))
 		}
 
 		out = b.String()
 
+	case "channelQuery":
+		out = m.channelQueryView()
+
+	case "channelBulk":
+		out = m.bulkView()
+
+	case "channelDetail":
+		out = m.channelDetailView()
+
+	case "channelSearch":
+		out = m.channelSearchView()
+
+	case "tagMerge":
+		out = m.tagMergeView()
+
+	case "tagSplit":
+		out = m.tagSplitView()
+
+	case "syncReview":
+		out = m.syncReviewView()
+
 	case "verifyGenerate":
 		var b strings.Builder
 		var sb strings.Builder
@@ -1870,34 +2276,23 @@ func (m Model) View() string {
 
 var m Model
 
-func StartTea(channels channel.Channels, tags tag.Tags, settings utils.Settings) {
+// StartTea runs the TUI. If syncChanges has any changes in it, it starts on a
+// page to review them before they're applied.
+func StartTea(channels channel.Channels, tags tag.Tags, settings utils.Settings, syncChanges channel.Changeset) {
 	listKeys := newListKeyMap()
 
 	m.channels = channels
 	m.tags = tags
 	m.settings = settings
+	m.bulkSelected = make(map[string]bool)
 
 	m.current = "channel"
-	m.list = list.New(m.generateChannelItems(untaggedFilter), channelListItemDelegate{}, 0, 0)
-	m.list.Title = "YSM - Channel View"
-	m.list.Styles.Title = titleStyle
-	m.list.AdditionalShortHelpKeys = func() []key.Binding {
-		return []key.Binding{
-			listKeys.tKey,
-			listKeys.pKey,
-			listKeys.enterKey,
-			listKeys.gKey,
-			listKeys.uKey,
-		}
-	}
-	m.list.AdditionalFullHelpKeys = func() []key.Binding {
-		return []key.Binding{
-			listKeys.tKey,
-			listKeys.pKey,
-			listKeys.enterKey,
-			listKeys.gKey,
-			listKeys.uKey,
-		}
+	m.setChannelList(0, 0)
+
+	if len(syncChanges.Changes) > 0 {
+		syncChanges.AcceptAll()
+		m.syncChanges = syncChanges
+		m.current = "syncReview"
 	}
 
 	m.listKeys = listKeys
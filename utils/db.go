@@ -25,56 +25,49 @@ import (
 
 var DbConn *sql.DB
 
-func InitDb(dbFile string) {
-	var sqlText string
-	var db *sql.DB // Database connection pool.
-
-	var err error
-	db, err = sql.Open("sqlite3", dbFile)
-	HandleError(err, "Unable to open db file")
-
-	sqlText = `
-		CREATE TABLE IF NOT EXISTS channels (
-			id      		TEXT PRIMARY KEY,
-			name     		TEXT NOT NULL UNIQUE,
-			description     TEXT,
-			notes			TEXT
-		);
-	`
-	_, err = db.Exec(sqlText)
-	HandleError(err, "Unable to create channels table")
-
-	sqlText = `
-		CREATE TABLE IF NOT EXISTS tags (
-			id         		INTEGER PRIMARY KEY AUTOINCREMENT,
-			name      		TEXT,
-			description     TEXT,
-			bgColour			TEXT,
-			fgColour			TEXT
-
-		);
-	`
-	_, err = db.Exec(sqlText)
-	HandleError(err, "Unable to create tags table")
-
-	sqlText = `
-		CREATE TABLE IF NOT EXISTS links (
-			channelId      	TEXT,
-			tagId     		INTEGER,
-			PRIMARY KEY (channelId, tagId),
-			FOREIGN KEY (channelId) REFERENCES channels(id) ON DELETE CASCADE,
-			FOREIGN KEY (tagId) REFERENCES tags(id) ON DELETE CASCADE 
-		);
-	`
-	_, err = db.Exec(sqlText)
-	HandleError(err, "Unable to create links table")
+// InitDb opens the db, creating it if needed, and migrates it to the latest
+// schema version.
+func InitDb(dbFile string, backupCopies int) {
+	err := OpenDb(dbFile, backupCopies)
+	HandleError(err, "Unable to open db")
+}
+
+// OpenDb is InitDb, returning an error rather than exiting if the db can't be
+// opened or upgraded.
+func OpenDb(dbFile string, backupCopies int) error {
+	existed, err := FileDirExists(dbFile)
+	if err != nil {
+		return fmt.Errorf("checking for existence of existing dbFile: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		return fmt.Errorf("unable to open db file: %w", err)
+	}
+
+	err = migrateDb(db, dbFile, backupCopies, existed)
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("unable to upgrade db: %w", err)
+	}
 
 	DbConn = db
+
+	return nil
 }
 
 func BackupDbFile(dbFile string, backupCopies int) {
+	err := BackupDb(dbFile, backupCopies)
+	HandleError(err, "Unable to back up the db")
+}
+
+// BackupDb is BackupDbFile, returning an error rather than exiting if the
+// backup can't be made.
+func BackupDb(dbFile string, backupCopies int) error {
 	result, err := FileDirExists(dbFile)
-	HandleError(err, "Checking for existence of existing dbFile.")
+	if err != nil {
+		return fmt.Errorf("checking for existence of existing dbFile: %w", err)
+	}
 	if result {
 		timeRec := time.Now()
 		timeStamp := timeRec.Format(time.DateOnly) + "-" + strings.ReplaceAll(timeRec.Format(time.TimeOnly), ":", "-")
@@ -82,7 +75,9 @@ func BackupDbFile(dbFile string, backupCopies int) {
 		// Make sure backups dir exists
 		backupPath := filepath.Clean(filepath.Dir(dbFile)+"/backups/") + string(filepath.Separator)
 		backupPathCheck, err := FileDirExists(backupPath)
-		HandleError(err, "Checking for existence of backup path.")
+		if err != nil {
+			return fmt.Errorf("checking for existence of backup path: %w", err)
+		}
 		if !backupPathCheck {
 			os.MkdirAll(backupPath, 0755)
 		}
@@ -90,19 +85,27 @@ func BackupDbFile(dbFile string, backupCopies int) {
 		// Save the file
 		backupName := backupPath + filepath.Base(dbFile) + "-backup-" + timeStamp
 		err = cp.Copy(dbFile, backupName)
-		HandleError(err, "Could not create backup of database file "+dbFile+" to "+backupName)
-		fmt.Println("Created backup copy of database to " + backupName)
+		if err != nil {
+			return fmt.Errorf("could not create backup of database file %s to %s: %w", dbFile, backupName, err)
+		}
+		fmt.Fprintln(os.Stderr, "Created backup copy of database to "+backupName)
 
 		// Clean up anything over backupCopies
 		entries, err := os.ReadDir(backupPath)
-		HandleError(err, "Could not open backups directory "+backupPath)
+		if err != nil {
+			return fmt.Errorf("could not open backups directory %s: %w", backupPath, err)
+		}
 
 		if len(entries) > backupCopies {
 			toDelete := backupPath + entries[0].Name()
 			err := os.Remove(toDelete)
-			HandleError(err, "Could not remove file "+toDelete)
+			if err != nil {
+				return fmt.Errorf("could not remove file %s: %w", toDelete, err)
+			}
 
-			fmt.Println("Removed old backup file: " + toDelete)
+			fmt.Fprintln(os.Stderr, "Removed old backup file: "+toDelete)
 		}
 	}
+
+	return nil
 }
@@ -191,24 +191,47 @@ func InstallApp() {
 }
 
 func VerifyInstallation() {
+	err := CheckInstallation()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// CheckInstallation returns an error saying to run --install if the settings
+// file or the template or output directories are missing.
+func CheckInstallation() error {
 	userScope := gap.NewScope(gap.User, "ysm")
 
 	configFile, err := userScope.ConfigPath("settings.json")
-	HandleError(err, "Could not determine user config path.")
+	if err != nil {
+		return fmt.Errorf("could not determine user config path: %w", err)
+	}
 	configFileCheck, err := FileDirExists(configFile)
-	HandleError(err, "Checking for existence of user config.")
+	if err != nil {
+		return fmt.Errorf("checking for existence of user config: %w", err)
+	}
 	templatePath, err := userScope.DataPath("templates")
-	HandleError(err, "Could not determine templates path.")
+	if err != nil {
+		return fmt.Errorf("could not determine templates path: %w", err)
+	}
 	templatePathCheck, err := FileDirExists(templatePath)
-	HandleError(err, "Checking for existence of templates data path.")
+	if err != nil {
+		return fmt.Errorf("checking for existence of templates data path: %w", err)
+	}
 	outputPath, err := userScope.DataPath("html")
-	HandleError(err, "Could not determine output data path.")
+	if err != nil {
+		return fmt.Errorf("could not determine output data path: %w", err)
+	}
 	outputPathCheck, err := FileDirExists(outputPath)
-	HandleError(err, "Checking for existence of output data path.")
+	if err != nil {
+		return fmt.Errorf("checking for existence of output data path: %w", err)
+	}
 	if !configFileCheck || !templatePathCheck || !outputPathCheck {
-		fmt.Println("Could not find required directories. Please run the program with the --install argument.")
-		os.Exit(1)
+		return errors.New("could not find required directories. Please run the program with the --install argument.")
 	}
+
+	return nil
 }
 
 func JsonEscape(i string) string {
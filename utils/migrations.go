@@ -0,0 +1,382 @@
+/*
+Joyrex YSM - Manager for Youtube Subscriptions
+Copyright (C) 2025 Eric Stacey <ejstacey@joyrex.net>
+
+This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+*/
+
+package utils
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// dbExecer is what migrations need from either a *sql.DB or a *sql.Tx.
+type dbExecer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+type migration struct {
+	version     int
+	description string
+	up          func(tx dbExecer) error
+}
+
+// migrations are run in order, each in its own transaction, on any db whose
+// schema_version is lower than their version. Never change or reorder a
+// migration once it's released, add a new one to the end instead.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create channels, tags and links tables",
+		up: func(tx dbExecer) error {
+			var sqlText = `
+				CREATE TABLE IF NOT EXISTS channels (
+					id      		TEXT PRIMARY KEY,
+					name     		TEXT NOT NULL UNIQUE,
+					description     TEXT,
+					notes			TEXT
+				);
+
+				CREATE TABLE IF NOT EXISTS tags (
+					id         		INTEGER PRIMARY KEY AUTOINCREMENT,
+					name      		TEXT,
+					description     TEXT,
+					bgColour			TEXT,
+					fgColour			TEXT
+				);
+
+				CREATE TABLE IF NOT EXISTS links (
+					channelId      	TEXT,
+					tagId     		INTEGER,
+					PRIMARY KEY (channelId, tagId),
+					FOREIGN KEY (channelId) REFERENCES channels(id) ON DELETE CASCADE,
+					FOREIGN KEY (tagId) REFERENCES tags(id) ON DELETE CASCADE
+				);
+			`
+			_, err := tx.Exec(sqlText)
+			return err
+		},
+	},
+	{
+		version:     2,
+		description: "archive unsubscribed channels instead of deleting them",
+		up: func(tx dbExecer) error {
+			// dbs from before migrations existed may already have the column
+			return addColumnIfMissing(tx, "channels", "unsubscribedAt", "TEXT")
+		},
+	},
+	{
+		version:     3,
+		description: "record sync state and page etags for incremental syncs",
+		up: func(tx dbExecer) error {
+			var sqlText = `
+				CREATE TABLE sync_state (
+					source			TEXT PRIMARY KEY,
+					lastSync		TEXT,
+					channelCount	INTEGER,
+					pageCount		INTEGER,
+					unchangedPages	INTEGER
+				);
+
+				CREATE TABLE sync_pages (
+					source			TEXT,
+					pageToken		TEXT,
+					etag			TEXT,
+					nextPageToken	TEXT,
+					totalResults	INTEGER,
+					items			TEXT,
+					PRIMARY KEY (source, pageToken)
+				);
+			`
+			_, err := tx.Exec(sqlText)
+			return err
+		},
+	},
+	{
+		version:     4,
+		description: "let tags have a parent tag",
+		up: func(tx dbExecer) error {
+			return addColumnIfMissing(tx, "tags", "parentId", "INTEGER REFERENCES tags(id) ON DELETE SET NULL")
+		},
+	},
+	{
+		version:     5,
+		description: "add auto-tagging rules and record where each tag link came from",
+		up: func(tx dbExecer) error {
+			var sqlText = `
+				CREATE TABLE rules (
+					id				INTEGER PRIMARY KEY AUTOINCREMENT,
+					tagId			INTEGER NOT NULL,
+					field			TEXT NOT NULL,
+					matchType		TEXT NOT NULL,
+					pattern			TEXT NOT NULL,
+					createdAt		TEXT,
+					FOREIGN KEY (tagId) REFERENCES tags(id) ON DELETE CASCADE
+				);
+
+				CREATE TABLE rule_exceptions (
+					channelId		TEXT,
+					tagId			INTEGER,
+					PRIMARY KEY (channelId, tagId),
+					FOREIGN KEY (channelId) REFERENCES channels(id) ON DELETE CASCADE,
+					FOREIGN KEY (tagId) REFERENCES tags(id) ON DELETE CASCADE
+				);
+			`
+			_, err := tx.Exec(sqlText)
+			if err != nil {
+				return err
+			}
+
+			// 'manual' for links made by hand, 'rule' for ones made by a rule
+			return addColumnIfMissing(tx, "links", "source", "TEXT NOT NULL DEFAULT 'manual'")
+		},
+	},
+	{
+		version:     6,
+		description: "keep archived channels apart from unsubscribed ones",
+		up: func(tx dbExecer) error {
+			// archived channels are unsubscribed as well, but a sync doesn't
+			// bring them back
+			return addColumnIfMissing(tx, "channels", "archived", "INTEGER NOT NULL DEFAULT 0")
+		},
+	},
+	{
+		version:     7,
+		description: "keep a history of edits so they can be undone",
+		up: func(tx dbExecer) error {
+			var sqlText = `
+				CREATE TABLE history (
+					id				INTEGER PRIMARY KEY AUTOINCREMENT,
+					at				TEXT NOT NULL,
+					description		TEXT NOT NULL,
+					changes			TEXT NOT NULL,
+					undone			INTEGER NOT NULL DEFAULT 0
+				);
+			`
+			_, err := tx.Exec(sqlText)
+			return err
+		},
+	},
+	{
+		version:     8,
+		description: "keep the old names of renamed and merged tags",
+		up: func(tx dbExecer) error {
+			var sqlText = `
+				CREATE TABLE tag_aliases (
+					name			TEXT PRIMARY KEY COLLATE NOCASE,
+					tagId			INTEGER NOT NULL,
+					FOREIGN KEY (tagId) REFERENCES tags(id) ON DELETE CASCADE
+				);
+			`
+			_, err := tx.Exec(sqlText)
+			return err
+		},
+	},
+	{
+		version:     9,
+		description: "add a full-text index of channel names, descriptions and notes",
+		up: func(tx dbExecer) error {
+			// the index keeps its own copy of the text, keyed by the channel
+			// id, since channels has no rowid that's stable over a vacuum
+			var sqlText = `
+				CREATE VIRTUAL TABLE channels_fts USING fts5 (
+					id UNINDEXED,
+					name,
+					description,
+					notes,
+					tokenize = 'unicode61 remove_diacritics 2'
+				);
+
+				CREATE TRIGGER channels_fts_insert AFTER INSERT ON channels BEGIN
+					INSERT INTO channels_fts (id, name, description, notes) VALUES (new.id, new.name, new.description, new.notes);
+				END;
+
+				CREATE TRIGGER channels_fts_delete AFTER DELETE ON channels BEGIN
+					DELETE FROM channels_fts WHERE id = old.id;
+				END;
+
+				CREATE TRIGGER channels_fts_update AFTER UPDATE OF id, name, description, notes ON channels BEGIN
+					DELETE FROM channels_fts WHERE id = old.id;
+					INSERT INTO channels_fts (id, name, description, notes) VALUES (new.id, new.name, new.description, new.notes);
+				END;
+
+				INSERT INTO channels_fts (id, name, description, notes) SELECT id, name, description, notes FROM channels;
+			`
+			_, err := tx.Exec(sqlText)
+			return err
+		},
+	},
+	{
+		version:     10,
+		description: "store channel details and stats looked up from youtube",
+		up: func(tx dbExecer) error {
+			// all null until the channel is enriched. subscriberCount stays
+			// null for channels that hide it
+			columns := []string{"avatarUrl TEXT", "subscriberCount INTEGER", "videoCount INTEGER", "country TEXT", "customUrl TEXT", "createdAt TEXT", "enrichedAt TEXT"}
+			for _, column := range columns {
+				name, definition, _ := strings.Cut(column, " ")
+				err := addColumnIfMissing(tx, "channels", name, definition)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	},
+	{
+		version:     11,
+		description: "store the newest upload in each channel's feed",
+		up: func(tx dbExecer) error {
+			// feedCheckedAt is set once the feed has been read, the rest stay
+			// null if the channel has no uploads or no feed
+			columns := []string{"latestVideoId TEXT", "latestVideoTitle TEXT", "latestVideoAt TEXT", "feedCheckedAt TEXT"}
+			for _, column := range columns {
+				name, definition, _ := strings.Cut(column, " ")
+				err := addColumnIfMissing(tx, "channels", name, definition)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	},
+	{
+		version:     12,
+		description: "record when channels were tagged",
+		up: func(tx dbExecer) error {
+			// links from before this stay null, since when they were made
+			// isn't known
+			err := addColumnIfMissing(tx, "links", "createdAt", "TEXT")
+			if err != nil {
+				return err
+			}
+
+			// a trigger rather than a default, so every place that makes
+			// links gets it. links copied from elsewhere keep their own
+			var sqlText = `
+				CREATE TRIGGER links_created_at AFTER INSERT ON links WHEN new.createdAt IS NULL BEGIN
+					UPDATE links SET createdAt = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE rowid = new.rowid;
+				END;
+			`
+			_, err = tx.Exec(sqlText)
+			return err
+		},
+	},
+	{
+		version:     13,
+		description: "let tags be public, private or unlisted",
+		up: func(tx dbExecer) error {
+			err := addColumnIfMissing(tx, "tags", "visibility", "TEXT NOT NULL DEFAULT 'public'")
+			if err != nil {
+				return err
+			}
+
+			// the generator used to leave out tags with these names
+			_, err = tx.Exec("UPDATE tags SET visibility = 'private' WHERE lower(ifnull(name, '')) IN ('', 'hide', 'hidden')")
+			return err
+		},
+	},
+}
+
+// SchemaVersion returns the version of the newest migration, which is the
+// version of a fully migrated db.
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrateDb brings the db up to the latest schema version. If there's
+// anything to do on a db that already existed, a backup is taken first.
+func migrateDb(db *sql.DB, dbFile string, backupCopies int, existed bool) error {
+	var sqlText = `
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version			INTEGER PRIMARY KEY,
+			description		TEXT,
+			appliedAt		TEXT
+		);
+	`
+	_, err := db.Exec(sqlText)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	err = db.QueryRow("select ifnull(max(version), 0) from schema_version").Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	if current > SchemaVersion() {
+		return fmt.Errorf("database is at schema version %d, but this version of ysm only knows up to %d. Please upgrade ysm", current, SchemaVersion())
+	}
+	if current == SchemaVersion() {
+		return nil
+	}
+
+	if existed {
+		err = BackupDb(dbFile, backupCopies)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "Upgrading database to schema version %d: %s\n", m.version, m.description)
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		err = m.up(tx)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("schema version %d (%s): %w", m.version, m.description, err)
+		}
+
+		_, err = tx.Exec("insert into schema_version (version, description, appliedAt) values (?, ?, ?)", m.version, m.description, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addColumnIfMissing adds a column to an existing table, for dbs that were
+// created before the column existed.
+func addColumnIfMissing(tx dbExecer, table string, column string, definition string) error {
+	var count int
+	err := tx.QueryRow("select count(*) from pragma_table_info(?) where name = ?", table, column).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
@@ -16,64 +16,243 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/adhocore/jsonc"
 	gap "github.com/muesli/go-app-paths"
 )
 
-type GeneratorSettings struct {
+// GeneratorProfile is one set of things to generate.
+type GeneratorProfile struct {
+	Name         string `json:"Name"`
 	Title        string `json:"Title"`
 	TemplateFile string `json:"TemplateFile"`
 	OutputFile   string `json:"OutputFile"`
+	Query        string `json:"Query"`
+	// Format is html, json, csv, markdown, atom or site. If it's empty the
+	// output file's extension decides.
+	Format string `json:"Format"`
+	// AssetDir is the css and javascript copied into a site.
+	AssetDir string `json:"AssetDir"`
+	// ShowTags, if set, are the only tags (with their sub-tags) that are
+	// shown. HideTags are left out as well. Private tags are always left
+	// out.
+	ShowTags []string `json:"ShowTags"`
+	HideTags []string `json:"HideTags"`
+}
+
+// GeneratorSettings is the default profile, plus any number of named ones
+// that take whatever they leave empty from it.
+type GeneratorSettings struct {
+	GeneratorProfile
+	Profiles []GeneratorProfile `json:"Profiles"`
+}
+
+// Profile returns the named profile, filled in from the defaults. "" and
+// "default" are the defaults themselves, unless a profile has that name.
+func (s GeneratorSettings) Profile(name string) (GeneratorProfile, bool) {
+	for _, p := range s.Profiles {
+		if p.Name == name {
+			return s.fill(p), true
+		}
+	}
+
+	if name == "" || name == s.defaultProfile().Name {
+		return s.defaultProfile(), true
+	}
+
+	return GeneratorProfile{}, false
+}
+
+// ProfileList returns the default profile followed by the named ones, filled
+// in from the defaults.
+func (s GeneratorSettings) ProfileList() []GeneratorProfile {
+	var profiles = []GeneratorProfile{s.defaultProfile()}
+	for _, p := range s.Profiles {
+		profiles = append(profiles, s.fill(p))
+	}
+
+	return profiles
+}
+
+func (s GeneratorSettings) defaultProfile() GeneratorProfile {
+	var p = s.GeneratorProfile
+	if p.Name == "" {
+		p.Name = "default"
+	}
+
+	return p
+}
+
+func (s GeneratorSettings) fill(p GeneratorProfile) GeneratorProfile {
+	if p.Title == "" {
+		p.Title = s.Title
+	}
+	if p.TemplateFile == "" {
+		p.TemplateFile = s.TemplateFile
+	}
+	if p.OutputFile == "" {
+		p.OutputFile = s.OutputFile
+	}
+	if p.Query == "" {
+		p.Query = s.Query
+	}
+	if p.Format == "" {
+		p.Format = s.Format
+	}
+	if p.AssetDir == "" {
+		p.AssetDir = s.AssetDir
+	}
+	if p.ShowTags == nil && p.HideTags == nil {
+		p.ShowTags = s.ShowTags
+		p.HideTags = s.HideTags
+	}
+
+	return p
+}
+
+// RefreshSetting is either true/false, or a number of hours (or a duration
+// string like "36h"), in which case a refresh only happens if the last one
+// was longer ago than that.
+type RefreshSetting struct {
+	Always bool
+	MaxAge time.Duration
+}
+
+func (r *RefreshSetting) UnmarshalJSON(input []byte) error {
+	var value any
+	err := json.Unmarshal(input, &value)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return nil
+	}
+
+	*r = RefreshSetting{}
+	switch v := value.(type) {
+	case bool:
+		r.Always = v
+	case float64:
+		r.MaxAge = time.Duration(v * float64(time.Hour))
+	case string:
+		r.MaxAge, err = time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("Refresh must be true, false, a number of hours or a duration like \"36h\": %w", err)
+		}
+	default:
+		return fmt.Errorf("Refresh must be true, false, a number of hours or a duration like \"36h\", not %s", input)
+	}
+
+	return nil
+}
+
+// Due says whether a refresh should happen, given when the last one was.
+func (r RefreshSetting) Due(lastSync time.Time) bool {
+	if r.Always {
+		return true
+	}
+	if r.MaxAge <= 0 {
+		return false
+	}
+	return time.Since(lastSync) > r.MaxAge
+}
+
+func (r RefreshSetting) String() string {
+	if r.MaxAge > 0 {
+		return "older than " + r.MaxAge.String()
+	}
+	return fmt.Sprintf("%t", r.Always)
 }
 
 type Settings struct {
-	Refresh      bool              `json:"Refresh"`
-	DbFile       string            `json:"DbFile"`
-	Generator    GeneratorSettings `json:"Generator"`
-	BackupCopies int               `json:"BackupCopies"`
+	Refresh          RefreshSetting    `json:"Refresh"`
+	DbFile           string            `json:"DbFile"`
+	Generator        GeneratorSettings `json:"Generator"`
+	BackupCopies     int               `json:"BackupCopies"`
+	MaxSubscriptions int               `json:"MaxSubscriptions"`
+	ReviewSync       bool              `json:"ReviewSync"`
+	Source           string            `json:"Source"`
+	SourceFile       string            `json:"SourceFile"`
 }
 
 // My stuff
 
 func LoadSettings() Settings {
+	settings, err := ReadSettings()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	return settings
+}
+
+// ReadSettings returns the defaults overridden by what's in settings.json. It
+// returns an error rather than exiting if that can't be read.
+func ReadSettings() (Settings, error) {
 	userScope := gap.NewScope(gap.User, "ysm")
 
 	var settings Settings
 	dbFile, err := userScope.DataPath("ysm.db")
-	HandleError(err, "Could not determine user data file!")
+	if err != nil {
+		return settings, fmt.Errorf("could not determine user data file: %w", err)
+	}
 	settings.DbFile = dbFile
 
-	settings.Refresh = true
+	settings.Refresh = RefreshSetting{Always: true}
 	settings.Generator.Title = "My Youtube Subscriptions"
 
 	outputFile, err := userScope.DataPath("html/index.html")
-	HandleError(err, "Could not determine user data path for output file!")
+	if err != nil {
+		return settings, fmt.Errorf("could not determine user data path for output file: %w", err)
+	}
 	settings.Generator.OutputFile = outputFile
 
 	templateFile, err := userScope.DataPath("templates/default.tmpl")
-	HandleError(err, "Could not determine system data path for template file!")
+	if err != nil {
+		return settings, fmt.Errorf("could not determine system data path for template file: %w", err)
+	}
 	settings.Generator.TemplateFile = templateFile
 
+	assetDir, err := userScope.DataPath("html")
+	if err != nil {
+		return settings, fmt.Errorf("could not determine user data path for html files: %w", err)
+	}
+	settings.Generator.AssetDir = assetDir
+
 	settings.BackupCopies = 7
 
+	settings.MaxSubscriptions = 10000
+
+	settings.ReviewSync = true
+
+	settings.Source = "youtube"
+
 	settingsFile, err := userScope.ConfigPath("settings.json")
-	HandleError(err, "Could not determine user config file!")
+	if err != nil {
+		return settings, fmt.Errorf("could not determine user config file: %w", err)
+	}
 	result, err := FileDirExists(settingsFile)
-	HandleError(err, "Checking for existence of user settings file.")
+	if err != nil {
+		return settings, fmt.Errorf("checking for existence of user settings file: %w", err)
+	}
 	if !result {
-		fmt.Println("Settings file " + settingsFile + " not found. Do you need to run --install?")
-		os.Exit(1)
+		return settings, fmt.Errorf("settings file %s not found. Do you need to run --install?", settingsFile)
 	}
 
-	fmt.Printf("Loading %s\n", settingsFile)
+	fmt.Fprintf(os.Stderr, "Loading %s\n", settingsFile)
 	input, err := os.ReadFile(settingsFile)
-	HandleError(err, "Unable to open settings.json")
+	if err != nil {
+		return settings, fmt.Errorf("unable to open settings.json: %w", err)
+	}
 
 	j := jsonc.New()
 	input = j.Strip(input)
 	err = json.Unmarshal(input, &settings)
-	HandleError(err, "Unable to read settings.json")
+	if err != nil {
+		return settings, fmt.Errorf("unable to read settings.json: %w", err)
+	}
 
-	return settings
+	return settings, nil
 }